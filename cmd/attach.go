@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"hama-shell/internal/session/model"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// attachCmd represents the attach command
+var attachCmd = &cobra.Command{
+	Use:   "attach <session-id>",
+	Short: "Attach the terminal to a running session",
+	Long: `Attach the current terminal to a session owned by the hama-shell daemon.
+
+Press the detach keys (default ctrl-p,ctrl-q, or $HAMA_SHELL_DETACH_KEYS) to
+detach again; the session keeps running and can be reattached from any terminal.
+
+Several terminals can attach to one session at once. Only one of them may type;
+the others attach with --read-only and watch the same output.
+
+Examples:
+  hs attach myapp.database.dev-1718000000
+  hs attach myapp.database.dev-1718000000 --read-only
+  hs attach myapp.database.dev-1718000000 --detach-keys ctrl-a,d`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		detachKeys, _ := cmd.Flags().GetString("detach-keys")
+		readOnly, _ := cmd.Flags().GetBool("read-only")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Attach through API layer
+		opts := model.AttachOptions{DetachKeys: detachKeys, ReadOnly: readOnly}
+		if err := sessionAPI.AttachSession(args[0], opts); err != nil {
+			log.Fatalf("Failed to attach: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+
+	attachCmd.Flags().String("detach-keys", "", "Key sequence that detaches from the session (default ctrl-p,ctrl-q)")
+	attachCmd.Flags().BoolP("read-only", "r", false, "Watch the session without sending input")
+}
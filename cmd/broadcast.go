@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"hama-shell/internal/session/model"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// broadcastCmd represents the broadcast command
+var broadcastCmd = &cobra.Command{
+	Use:   "broadcast --select <pattern>",
+	Short: "Type into several sessions at once",
+	Long: `Attach the terminal to every running session whose project.service.stage target
+matches a glob pattern. Everything typed goes to all of them, and their output is
+shown interleaved, each line prefixed with its target. Detach with the detach keys
+(ctrl-p,ctrl-q by default); the sessions keep running.
+
+Examples:
+  hs broadcast --select 'myapp.*.prod'
+  hs broadcast --select '*.database.*' --detach-keys ctrl-a,d`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		selector, _ := cmd.Flags().GetString("select")
+		detachKeys, _ := cmd.Flags().GetString("detach-keys")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Broadcast through API layer
+		opts := model.BroadcastOptions{Select: selector, DetachKeys: detachKeys}
+		if err := sessionAPI.BroadcastSessions(opts); err != nil {
+			log.Fatalf("Failed to broadcast: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(broadcastCmd)
+
+	broadcastCmd.Flags().StringP("select", "s", "", "Glob over project.service.stage targets, e.g. 'myapp.*.prod'")
+	broadcastCmd.Flags().String("detach-keys", "", "Key sequence that stops broadcasting (default ctrl-p,ctrl-q)")
+	_ = broadcastCmd.MarkFlagRequired("select")
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"hama-shell/internal/daemon/api"
+	"hama-shell/internal/daemon/model"
+
+	"github.com/spf13/cobra"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the hama-shell session daemon",
+	Long: `Run the background daemon that owns every hama-shell session.
+
+The daemon listens on a per-user Unix socket and keeps sessions alive after
+the command that started them exits. Other commands start it automatically
+when needed; running it directly keeps it in the foreground.
+
+Available subcommands:
+  start   - Start the daemon in the background
+  stop    - Stop the daemon and all of its sessions
+  status  - Show whether the daemon is running`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemonAPI := api.NewDaemonAPI()
+		return daemonAPI.RunDaemon(daemonOptions(cmd))
+	},
+}
+
+// daemonStartCmd represents the daemon start command
+var daemonStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the daemon in the background",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemonAPI := api.NewDaemonAPI()
+		return daemonAPI.StartDaemon(daemonOptions(cmd))
+	},
+}
+
+// daemonStopCmd represents the daemon stop command
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon and all of its sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemonAPI := api.NewDaemonAPI()
+		return daemonAPI.StopDaemon()
+	},
+}
+
+// daemonStatusCmd represents the daemon status command
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		daemonAPI := api.NewDaemonAPI()
+		return daemonAPI.ShowStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	daemonCmd.AddCommand(daemonStartCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+
+	daemonCmd.Flags().Int("scrollback-size", 0, "Bytes of output kept per session and replayed on attach (default 262144)")
+	daemonStartCmd.Flags().Int("scrollback-size", 0, "Bytes of output kept per session and replayed on attach (default 262144)")
+	daemonCmd.Flags().String("resize-policy", "", "Default window size for shared sessions: smallest, largest or latest (default smallest)")
+	daemonStartCmd.Flags().String("resize-policy", "", "Default window size for shared sessions: smallest, largest or latest (default smallest)")
+	daemonCmd.Flags().Duration("stop-grace-period", 0, "Time stopped sessions get after SIGTERM before SIGKILL (default 5s)")
+	daemonStartCmd.Flags().Duration("stop-grace-period", 0, "Time stopped sessions get after SIGTERM before SIGKILL (default 5s)")
+}
+
+// daemonOptions reads the daemon settings shared by daemon and daemon start
+func daemonOptions(cmd *cobra.Command) model.DaemonOptions {
+	scrollbackSize, _ := cmd.Flags().GetInt("scrollback-size")
+	resizePolicy, _ := cmd.Flags().GetString("resize-policy")
+	stopGracePeriod, _ := cmd.Flags().GetDuration("stop-grace-period")
+	return model.DaemonOptions{
+		ScrollbackSize:  scrollbackSize,
+		ResizePolicy:    resizePolicy,
+		StopGracePeriod: stopGracePeriod,
+	}
+}
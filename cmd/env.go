@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"hama-shell/internal/service/api"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env <session-id|target>",
+	Short: "Print a running session's ID and forwarded ports as variables",
+	Long: `Print variables describing a running session for scripts: HS_SESSION_ID, HS_TARGET,
+HS_PROJECT, HS_SERVICE, HS_STAGE and HS_STATUS, and for each local or dynamic forward
+HS_FORWARD_<NAME>_HOST, HS_FORWARD_<NAME>_PORT and HS_FORWARD_<NAME>_ADDR, where it listens.
+
+The argument is a session ID from "hs list" or a <project>.<service>.<stage> target
+with exactly one running session.
+
+Examples:
+  eval "$(hs env myapp.database.dev)"
+  hs env myapp.database.dev --format dotenv > .env
+  hs env myapp.database.dev --format fish | source
+  hs env myapp.database.dev-1718000000 --format json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		format, _ := cmd.Flags().GetString("format")
+
+		// Create service API
+		serviceAPI := api.NewServiceAPI()
+		defer serviceAPI.Shutdown()
+
+		// Print through API layer
+		if err := serviceAPI.ServiceEnv(args[0], format); err != nil {
+			log.Fatalf("Failed to print environment: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+
+	envCmd.Flags().String("format", "export", "Output format: export, dotenv, json or fish")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"hama-shell/internal/session/model"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// eventsCmd represents the events command
+var eventsCmd = &cobra.Command{
+	Use:   "events [session-id|target]",
+	Short: "Show session lifecycle events",
+	Long: `Show the lifecycle events the daemon recently published: sessions starting,
+becoming ready, resizing, gaining or losing clients and exiting.
+
+With --follow the command keeps running and prints new events as they happen.
+--output jsonl prints one JSON object per event for scripts and status bars.
+
+Examples:
+  hs events
+  hs events --follow
+  hs events myapp.api.dev --follow --output jsonl`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		follow, _ := cmd.Flags().GetBool("follow")
+		output, _ := cmd.Flags().GetString("output")
+
+		opts := model.EventOptions{Follow: follow, Output: output}
+		if len(args) > 0 {
+			opts.Session = args[0]
+		}
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Show events through API layer
+		if err := sessionAPI.ShowEvents(opts); err != nil {
+			log.Fatalf("Failed to show events: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+
+	eventsCmd.Flags().BoolP("follow", "f", false, "Keep printing new events as they happen")
+	eventsCmd.Flags().StringP("output", "o", "text", "Output format: text or jsonl")
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"hama-shell/internal/session/model"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// killCmd represents the kill command
+var killCmd = &cobra.Command{
+	Use:   "kill",
+	Short: "Stop running sessions in bulk",
+	Long: `Stop every running session whose target matches the given project, service
+and stage. Unset filters match everything, so --project alone stops a whole project.
+--all stops every running session the daemon owns.
+
+Examples:
+  hs kill --project myapp --stage prod
+  hs kill --project myapp --service database
+  hs kill --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		project, _ := cmd.Flags().GetString("project")
+		service, _ := cmd.Flags().GetString("service")
+		stage, _ := cmd.Flags().GetString("stage")
+		all, _ := cmd.Flags().GetBool("all")
+		grace, _ := cmd.Flags().GetDuration("grace")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Kill through API layer
+		filter := model.KillFilter{ProjectName: project, ServiceName: service, StageName: stage, All: all}
+		if err := sessionAPI.KillSessions(filter, grace); err != nil {
+			log.Fatalf("Failed to kill sessions: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(killCmd)
+
+	killCmd.Flags().StringP("project", "p", "", "Only stop sessions of this project")
+	killCmd.Flags().String("service", "", "Only stop sessions of this service")
+	killCmd.Flags().String("stage", "", "Only stop sessions of this stage")
+	killCmd.Flags().Bool("all", false, "Stop every running session")
+	killCmd.Flags().Duration("grace", 0, "Time to wait after SIGTERM before SIGKILL (default: each session's stop_grace_period)")
+	killCmd.MarkFlagsMutuallyExclusive("all", "project")
+	killCmd.MarkFlagsMutuallyExclusive("all", "service")
+	killCmd.MarkFlagsMutuallyExclusive("all", "stage")
+}
@@ -37,5 +37,5 @@ func init() {
 
 	// Add flags specific to list command
 	listCmd.Flags().BoolP("all", "a", false, "Show all sessions including stopped ones")
-	listCmd.Flags().StringP("status", "s", "", "Filter by status (running/stopped/failed)")
+	listCmd.Flags().StringP("status", "s", "", "Filter by status (running/starting/ready/unhealthy/restarting/stopped/failed)")
 }
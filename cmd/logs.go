@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <session-id>",
+	Short: "Show the output log of a session",
+	Long: `Show the transcript of a session's output. Logs are only kept for stages with
+"log: {enabled: true}" and live under ~/.hama-shell/logs/<target>/, rotated by size and age.
+
+Examples:
+  hs logs myapp.database.dev-1718000000
+  hs logs myapp.database.dev-1718000000 -f
+  hs logs myapp.database.dev-1718000000 --since 10m --timestamps`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		follow, _ := cmd.Flags().GetBool("follow")
+		since, _ := cmd.Flags().GetString("since")
+		timestamps, _ := cmd.Flags().GetBool("timestamps")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Show logs through API layer
+		if err := sessionAPI.ShowLogs(args[0], follow, since, timestamps); err != nil {
+			log.Fatalf("Failed to show logs: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep printing new output until the session ends")
+	logsCmd.Flags().String("since", "", "Only show output written after a duration ago (10m) or a time (2006-01-02 15:04:05)")
+	logsCmd.Flags().BoolP("timestamps", "t", false, "Prefix every line with the time it was written")
+}
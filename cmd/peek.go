@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// peekCmd represents the peek command
+var peekCmd = &cobra.Command{
+	Use:   "peek <session-id>",
+	Short: "Show what a session's terminal currently displays",
+	Long: `Print a snapshot of a session's screen without attaching to it. The daemon keeps
+a virtual terminal for every session, so full-screen programs such as top or vim
+are shown as they would appear, including the alternate screen and wide characters.
+
+Examples:
+  hs peek myapp.database.dev-1718000000
+  hs peek myapp.database.dev-1718000000 --ansi`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		ansi, _ := cmd.Flags().GetBool("ansi")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Peek through API layer
+		if err := sessionAPI.PeekSession(args[0], ansi); err != nil {
+			log.Fatalf("Failed to peek at session: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(peekCmd)
+
+	peekCmd.Flags().Bool("ansi", false, "Keep colors and text attributes in the snapshot")
+}
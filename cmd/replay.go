@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <file.cast>",
+	Short: "Play back a recorded session",
+	Long: `Play an asciicast v2 recording, such as one made with "hs service start --record",
+in the terminal.
+
+While playing: space pauses and resumes, the left and right arrows seek 5 seconds,
++ and - double or halve the speed, and q quits.
+
+Examples:
+  hs replay ~/.hama-shell/recordings/myapp.api.dev-1718000000.cast
+  hs replay session.cast --speed 2 --idle-limit 1s`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		idleLimit, _ := cmd.Flags().GetDuration("idle-limit")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Replay through API layer
+		if err := sessionAPI.ReplayRecording(args[0], speed, idleLimit); err != nil {
+			log.Fatalf("Failed to replay: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().Float64P("speed", "s", 1, "Playback speed multiplier")
+	replayCmd.Flags().Duration("idle-limit", 0, "Shorten pauses longer than this (e.g. 2s)")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"hama-shell/internal/service/api"
+	"hama-shell/internal/service/model"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// restartCmd represents the restart command
+var restartCmd = &cobra.Command{
+	Use:   "restart <project>.<service>.<stage>",
+	Short: "Stop a service's running sessions and start it again",
+	Long: `Stop every running session of a service, then start a fresh session and
+attach to it like "hs service start".
+
+Examples:
+  hs restart myapp.api.dev
+  hs restart myapp.api.dev --detach-keys ctrl-a,d`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Parse project.service.stage format
+		parts := strings.Split(args[0], ".")
+		if len(parts) != 3 {
+			log.Fatalf("Invalid service format. Use: <project>.<service>.<stage>")
+		}
+
+		// Get flags
+		detachKeys, _ := cmd.Flags().GetString("detach-keys")
+
+		// Create service API
+		serviceAPI := api.NewServiceAPI()
+
+		// Restart service through API layer
+		opts := model.StartOptions{DetachKeys: detachKeys}
+		exitCode, err := serviceAPI.RestartService(parts[0], parts[1], parts[2], opts)
+		serviceAPI.Shutdown()
+		if err != nil {
+			log.Fatalf("Failed to restart service: %v", err)
+		}
+
+		// Exit with the session's status so scripts can react to it
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restartCmd)
+
+	restartCmd.Flags().String("detach-keys", "", "Key sequence that detaches from the session (default ctrl-p,ctrl-q)")
+}
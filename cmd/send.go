@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"hama-shell/internal/session/model"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// sendCmd represents the send command
+var sendCmd = &cobra.Command{
+	Use:   "send <session-id> [text]",
+	Short: "Type text or keys into a running session",
+	Long: `Send input to a session owned by the daemon without attaching to it, the way
+tmux send-keys does. Text is sent literally; --keys adds named keys after it.
+
+Keys are comma-separated: single characters, C-<char> or ctrl-<char>, M-<key> for
+alt, and names such as Enter, Tab, Escape, Space, BSpace, Up, Down, Left, Right,
+Home, End, PageUp, PageDown, Insert, Delete, F1-F12 and Comma.
+
+Examples:
+  hs send myapp.database.dev-1718000000 "select 1;" --keys Enter
+  hs send myapp.database.dev-1718000000 --keys C-c
+  hs send myapp.database.dev-1718000000 --keys Up,Enter`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		keys, _ := cmd.Flags().GetString("keys")
+
+		opts := model.SendOptions{Keys: keys}
+		if len(args) == 2 {
+			opts.Text = args[1]
+		}
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Send through API layer
+		if err := sessionAPI.SendInput(args[0], opts); err != nil {
+			log.Fatalf("Failed to send input: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+
+	sendCmd.Flags().StringP("keys", "k", "", "Comma-separated named keys to send after the text, e.g. C-c,Enter")
+}
@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"hama-shell/internal/service/api"
+	"hama-shell/internal/service/model"
 	"log"
+	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -21,9 +23,14 @@ var serviceStartCmd = &cobra.Command{
 	Short: "Start a service from configuration",
 	Long: `Start a service defined in the configuration file.
 
+Press the detach keys (default ctrl-p,ctrl-q) to leave the session running
+in the background; reattach later with "hs attach <session-id>".
+
 Examples:
   hama-shell service start myproject.database.dev
-  hama-shell service start myproject.api.prod`,
+  hama-shell service start myproject.api.prod
+  hama-shell service start myproject.api.prod --detach-keys ctrl-a,d
+  hama-shell service start myproject.api.prod --record`,
 	Args: cobra.ExactArgs(1),
 	Run:  runServiceStart,
 }
@@ -36,28 +43,58 @@ var serviceListCmd = &cobra.Command{
 	Run:   runServiceList,
 }
 
+// serviceInfoCmd represents the service info command
+var serviceInfoCmd = &cobra.Command{
+	Use:   "info <project>.<service>.<stage>",
+	Short: "Show a service's configuration and running sessions",
+	Long: `Show how a service is configured and which of its sessions are running,
+with the port forwards each one holds and their open connections.
+
+Examples:
+  hama-shell service info myproject.database.dev`,
+	Args: cobra.ExactArgs(1),
+	Run:  runServiceInfo,
+}
+
 func init() {
 	rootCmd.AddCommand(serviceCmd)
 	serviceCmd.AddCommand(serviceStartCmd)
 	serviceCmd.AddCommand(serviceListCmd)
+	serviceCmd.AddCommand(serviceInfoCmd)
+
+	serviceStartCmd.Flags().String("detach-keys", "", "Key sequence that detaches from the session (default ctrl-p,ctrl-q)")
+	serviceStartCmd.Flags().Bool("record", false, "Record the terminal as an asciicast v2 file")
+	serviceStartCmd.Flags().String("record-file", "", "Where to write the recording (default ~/.hama-shell/recordings/<session-id>.cast)")
 }
 
 // runServiceStart starts a service using API layer
-func runServiceStart(_ *cobra.Command, args []string) {
+func runServiceStart(cmd *cobra.Command, args []string) {
 	// Parse project.service.stage format
 	parts := strings.Split(args[0], ".")
 	if len(parts) != 3 {
 		log.Fatalf("Invalid service format. Use: <project>.<service>.<stage>")
 	}
 
+	// Get flags
+	detachKeys, _ := cmd.Flags().GetString("detach-keys")
+	record, _ := cmd.Flags().GetBool("record")
+	recordFile, _ := cmd.Flags().GetString("record-file")
+
 	// Create service API
 	serviceAPI := api.NewServiceAPI()
-	defer serviceAPI.Shutdown()
 
 	// Start service through API layer
-	if err := serviceAPI.StartService(parts[0], parts[1], parts[2]); err != nil {
+	opts := model.StartOptions{DetachKeys: detachKeys, Record: record || recordFile != "", RecordFile: recordFile}
+	exitCode, err := serviceAPI.StartService(parts[0], parts[1], parts[2], opts)
+	serviceAPI.Shutdown()
+	if err != nil {
 		log.Fatalf("Failed to start service: %v", err)
 	}
+
+	// Exit with the session's status so scripts can react to it
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
 }
 
 // runServiceList lists all available services using API layer
@@ -71,3 +108,18 @@ func runServiceList(_ *cobra.Command, _ []string) {
 		log.Fatalf("Failed to list services: %v", err)
 	}
 }
+
+// runServiceInfo shows a service and its running sessions using API layer
+func runServiceInfo(_ *cobra.Command, args []string) {
+	parts := strings.Split(args[0], ".")
+	if len(parts) != 3 {
+		log.Fatalf("Invalid service format. Use: <project>.<service>.<stage>")
+	}
+
+	serviceAPI := api.NewServiceAPI()
+	defer serviceAPI.Shutdown()
+
+	if err := serviceAPI.ServiceInfo(parts[0], parts[1], parts[2]); err != nil {
+		log.Fatalf("Failed to show service: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"hama-shell/internal/session/api"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop <session-id|target>",
+	Short: "Stop a session, or every running session of a target",
+	Long: `Stop a session owned by the hama-shell daemon.
+
+The argument is either a session ID from "hs list" or a <project>.<service>.<stage>
+target, which stops every running session of that target. Each session's process
+group is sent SIGHUP and SIGTERM, given its grace period to exit and then sent SIGKILL.
+
+Examples:
+  hs stop myapp.database.dev-1718000000
+  hs stop myapp.database.dev
+  hs stop myapp.database.dev --grace 30s`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		grace, _ := cmd.Flags().GetDuration("grace")
+
+		// Create session API
+		sessionAPI := api.NewSessionAPI()
+
+		// Stop through API layer
+		if err := sessionAPI.StopSessions(args[0], grace); err != nil {
+			log.Fatalf("Failed to stop: %v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+
+	stopCmd.Flags().Duration("grace", 0, "Time to wait after SIGTERM before SIGKILL (default: the session's stop_grace_period)")
+}
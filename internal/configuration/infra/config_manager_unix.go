@@ -5,8 +5,10 @@ import (
 	"hama-shell/internal/configuration/model"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sync"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
@@ -37,7 +39,11 @@ func newViperConfigManager() ConfigManager {
 	home := os.Getenv("HOME")
 	filePath := filepath.Join(home, "hama-shell.yaml")
 
-	v := viper.New()
+	v := viper.NewWithOptions(viper.WithDecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		stageStepHook,
+	)))
 	v.SetConfigFile(filePath)
 	v.SetConfigType("yaml")
 
@@ -47,6 +53,14 @@ func newViperConfigManager() ConfigManager {
 	}
 }
 
+// stageStepHook decodes a plain string in a stage's steps as a run step
+func stageStepHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf(model.StageStep{}) {
+		return data, nil
+	}
+	return map[string]interface{}{"run": data}, nil
+}
+
 // initialize sets up the configuration manager
 func (cm *viperConfigManager) initialize() {
 	// Initialize with empty config if file doesn't exist
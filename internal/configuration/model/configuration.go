@@ -2,7 +2,139 @@ package model
 
 // Stage represents a stage configuration with commands
 type Stage struct {
-	Commands []string `yaml:"commands"`
+	Commands []string `yaml:"commands,omitempty"`
+	// Steps replace Commands with a startup that can also send keys, wait for output, sleep
+	// and mark checkpoints; a plain string is a run step
+	Steps        []StageStep `yaml:"steps,omitempty"`
+	ResizePolicy string      `yaml:"resize_policy,omitempty" mapstructure:"resize_policy"`
+	// StopGracePeriod is how long the session gets after SIGTERM before SIGKILL, e.g. "10s"
+	StopGracePeriod string `yaml:"stop_grace_period,omitempty" mapstructure:"stop_grace_period"`
+	// Log keeps a transcript of the session's output on disk
+	Log *StageLog `yaml:"log,omitempty"`
+	// Dispatch controls when each command is typed into the session
+	Dispatch *StageDispatch `yaml:"dispatch,omitempty"`
+	// Expect answers interactive prompts such as passwords and host-key confirmations
+	Expect []StageExpect `yaml:"expect,omitempty"`
+	// Restart is never, on-failure or always: whether the daemon starts the session again when it ends
+	Restart string `yaml:"restart,omitempty"`
+	// RestartMaxAttempts is how many restarts in a row are tried before giving up (default no limit)
+	RestartMaxAttempts int `yaml:"restart_max_attempts,omitempty" mapstructure:"restart_max_attempts"`
+	// RestartBackoff is the delay before the first restart, doubled for every further attempt, e.g. "1s"
+	RestartBackoff string `yaml:"restart_backoff,omitempty" mapstructure:"restart_backoff"`
+	// RestartMaxBackoff caps the delay between restarts, e.g. "1m"
+	RestartMaxBackoff string `yaml:"restart_max_backoff,omitempty" mapstructure:"restart_max_backoff"`
+	// Health declares probes that decide when a session is ready and whether it stays healthy
+	Health *StageHealth `yaml:"health,omitempty"`
+	// SSH connects the stage's session to a remote host natively instead of starting a local shell
+	SSH *StageSSH `yaml:"ssh,omitempty"`
+	// Forwards tunnel ports through the ssh connection
+	Forwards []StageForward `yaml:"forwards,omitempty"`
+}
+
+// StageForward is a port forward of an ssh stage
+type StageForward struct {
+	// Name identifies the forward in listings
+	Name string `yaml:"name"`
+	// Type is local (default, like ssh -L), remote (like ssh -R) or dynamic (a SOCKS proxy, like ssh -D)
+	Type string `yaml:"type,omitempty"`
+	// Local is the local [host:]port a local or dynamic forward listens on, or where a remote
+	// forward's connections go, e.g. "5432" or "localhost:3000"
+	Local string `yaml:"local,omitempty"`
+	// Remote is where a local forward's connections go from the ssh host, e.g. "db.internal:5432",
+	// or the [host:]port a remote forward listens on there
+	Remote string `yaml:"remote,omitempty"`
+}
+
+// StageSSH is the remote host an SSH stage logs in to
+type StageSSH struct {
+	Host string `yaml:"host"`
+	// Port defaults to 22
+	Port int `yaml:"port,omitempty"`
+	// User defaults to the local user
+	User string `yaml:"user,omitempty"`
+	// IdentityFile is a private key without a passphrase, e.g. "~/.ssh/deploy"; ssh-agent keys are tried too
+	IdentityFile string `yaml:"identity_file,omitempty" mapstructure:"identity_file"`
+	// Options are OpenSSH client options: StrictHostKeyChecking, UserKnownHostsFile, ConnectTimeout,
+	// ServerAliveInterval and ServerAliveCountMax
+	Options map[string]string `yaml:"options,omitempty"`
+	// Via lists the jump hosts to reach Host through, in order; each takes the same settings except via
+	Via []StageSSH `yaml:"via,omitempty"`
+}
+
+// StageHealth configures a stage's probes, which the daemon re-runs while the session lives
+type StageHealth struct {
+	Probes []StageProbe `yaml:"probes"`
+	// Interval is how often the probes are re-run, e.g. "10s"
+	Interval string `yaml:"interval,omitempty"`
+	// Timeout bounds each tcp or command probe, e.g. "5s"
+	Timeout string `yaml:"timeout,omitempty"`
+	// FailureThreshold is how many failed runs in a row make a ready session unhealthy (default 3)
+	FailureThreshold int `yaml:"failure_threshold,omitempty" mapstructure:"failure_threshold"`
+}
+
+// StageProbe is one check of a stage's health; exactly one of TCP, Command and Output is set
+type StageProbe struct {
+	// TCP is an address that must accept connections, e.g. "localhost:5432" or just "5432"
+	TCP string `yaml:"tcp,omitempty"`
+	// Command must exit 0, e.g. "pg_isready -h localhost"
+	Command string `yaml:"command,omitempty"`
+	// Output is a regular expression the session's output must have matched
+	Output string `yaml:"output,omitempty"`
+}
+
+// StageStep is one startup action of a stage: run, send and/or keys, wait_for, sleep or checkpoint
+type StageStep struct {
+	// Run types a command once the shell is ready for it
+	Run string `yaml:"run,omitempty"`
+	// Send types text straight away, without a newline or waiting for a prompt
+	Send string `yaml:"send,omitempty"`
+	// Keys are named keys typed after Send, e.g. "C-c" or "Down,Enter"
+	Keys string `yaml:"keys,omitempty"`
+	// WaitFor is a regular expression the output must match before the next step
+	WaitFor string `yaml:"wait_for,omitempty" mapstructure:"wait_for"`
+	// Sleep pauses for a duration, e.g. "2s"
+	Sleep string `yaml:"sleep,omitempty"`
+	// Checkpoint labels how far startup has got, shown by hs list
+	Checkpoint string `yaml:"checkpoint,omitempty"`
+	// Timeout overrides the dispatch step timeout for run and wait_for steps, e.g. "5m"
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// StageExpect pairs a prompt pattern with its response; exactly one response source is set
+type StageExpect struct {
+	// Pattern is a regular expression matched against the text before the cursor, e.g. 'password: $'
+	Pattern string `yaml:"pattern"`
+	// Response is typed as is
+	Response string `yaml:"response,omitempty"`
+	// ResponseEnv names an environment variable holding the response
+	ResponseEnv string `yaml:"response_env,omitempty" mapstructure:"response_env"`
+	// ResponseCommand prints the response, e.g. a secret store lookup or a one-time code
+	ResponseCommand string `yaml:"response_command,omitempty" mapstructure:"response_command"`
+	// MaxResponses limits how often the rule answers (default 3, -1 for no limit)
+	MaxResponses int `yaml:"max_responses,omitempty" mapstructure:"max_responses"`
+}
+
+// StageDispatch configures how a stage's commands wait for the shell
+type StageDispatch struct {
+	// Prompts are regular expressions matched against the text before the cursor, e.g. '\$ $'
+	Prompts []string `yaml:"prompts,omitempty"`
+	// QuietPeriod sends the next command once output has stopped this long, e.g. "2s", or "off"
+	QuietPeriod string `yaml:"quiet_period,omitempty" mapstructure:"quiet_period"`
+	// StepTimeout fails the session when the shell is not ready for a command in time, e.g. "1m"
+	StepTimeout string `yaml:"step_timeout,omitempty" mapstructure:"step_timeout"`
+}
+
+// StageLog configures the on-disk output log of a stage's sessions
+type StageLog struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxSizeMB rotates the log once it reaches this many megabytes
+	MaxSizeMB int `yaml:"max_size_mb,omitempty" mapstructure:"max_size_mb"`
+	// MaxAge rotates the log once it has been written for this long, e.g. "24h"
+	MaxAge string `yaml:"max_age,omitempty" mapstructure:"max_age"`
+	// MaxFiles is how many rotated logs are kept per session
+	MaxFiles int `yaml:"max_files,omitempty" mapstructure:"max_files"`
+	// MaxSessions is how many ended sessions of the stage keep their logs
+	MaxSessions int `yaml:"max_sessions,omitempty" mapstructure:"max_sessions"`
 }
 
 // Service represents a service configuration with stages
@@ -0,0 +1,210 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// DefaultDetachKeys is the key sequence that detaches the local terminal from a session
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// detachKeysEnv overrides the default detach key sequence
+const detachKeysEnv = "HAMA_SHELL_DETACH_KEYS"
+
+// AttachConfig holds configuration for attaching the local terminal to a session
+type AttachConfig struct {
+	// DetachKeys is the raw byte sequence that detaches instead of being sent to the session
+	DetachKeys []byte
+	// ReadOnly drops everything typed except the detach keys
+	ReadOnly bool
+	// Recorder, when set, records everything shown and every resize as asciicast
+	Recorder *terminal.Recorder
+}
+
+// AttachResult describes how the local terminal was released
+type AttachResult struct {
+	// Detached is true when the user detached and the session is still running
+	Detached bool
+	// Exit describes how the session ended; nil after a detach
+	Exit *terminal.ExitStatus
+}
+
+// ParseDetachKeys parses a detach key sequence, falling back to $HAMA_SHELL_DETACH_KEYS
+// and then DefaultDetachKeys when spec is empty
+func ParseDetachKeys(spec string) ([]byte, error) {
+	if spec == "" {
+		spec = os.Getenv(detachKeysEnv)
+	}
+	if spec == "" {
+		spec = DefaultDetachKeys
+	}
+
+	keys, err := terminal.ParseKeySequence(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid detach keys: %w", err)
+	}
+	return keys, nil
+}
+
+// AttachTerminal wires the process's terminal to an attached stream.
+// It returns when the session ends or the user detaches; the session keeps running after a detach.
+func AttachTerminal(stream Stream, config AttachConfig) (*AttachResult, error) {
+	fd := int(os.Stdin.Fd())
+
+	// Save original terminal state
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	var detached atomic.Bool
+	detach := func() {
+		if detached.CompareAndSwap(false, true) {
+			_ = stream.Send(Frame{Type: FrameDetach})
+			_ = stream.Close()
+		}
+	}
+
+	// Losing our own terminal detaches rather than killing the session
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		if _, ok := <-sigChan; ok {
+			detach()
+		}
+	}()
+
+	// Set terminal size and handle window size changes
+	sendSize(stream)
+	sigwinch := make(chan os.Signal, 1)
+	signal.Notify(sigwinch, syscall.SIGWINCH)
+	defer signal.Stop(sigwinch)
+	go func() {
+		for range sigwinch {
+			sendSize(stream)
+		}
+	}()
+
+	// Copy stdin to the session (user input -> shell)
+	go func() {
+		matcher := &detachMatcher{keys: config.DetachKeys}
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				data, matched := matcher.feed(buf[:n])
+				if len(data) > 0 && !config.ReadOnly {
+					if err := stream.Send(Frame{Type: FrameInput, Data: data}); err != nil {
+						return
+					}
+				}
+				if matched {
+					detach()
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Copy session output to stdout (shell output -> terminal)
+	out := &terminalOutput{w: os.Stdout, recorder: config.Recorder}
+	for {
+		frame, err := stream.Recv()
+		if err != nil {
+			if detached.Load() {
+				return &AttachResult{Detached: true}, nil
+			}
+			if err == io.EOF {
+				return &AttachResult{}, nil
+			}
+			return nil, fmt.Errorf("lost connection to daemon: %w", err)
+		}
+
+		switch frame.Type {
+		case FrameOutput:
+			out.write(frame.Data)
+		case FrameResize:
+			out.resize(frame.Rows, frame.Cols)
+		case FrameExit:
+			return &AttachResult{Exit: frame.Exit}, nil
+		case FrameError:
+			return nil, errors.New(frame.Error)
+		}
+	}
+}
+
+// sendSize forwards the local terminal size to the session
+func sendSize(stream Stream) {
+	if size, err := pty.GetsizeFull(os.Stdin); err == nil {
+		_ = stream.Send(Frame{Type: FrameResize, Rows: size.Rows, Cols: size.Cols})
+	}
+}
+
+// detachMatcher recognises the detach sequence across reads, holding back a partial match
+type detachMatcher struct {
+	keys    []byte
+	matched int
+	// fallback[i] is the length of the longest proper prefix of keys[:i+1] that is also its suffix
+	fallback []int
+}
+
+// feed returns the bytes to forward to the session and whether the detach sequence completed
+func (m *detachMatcher) feed(data []byte) ([]byte, bool) {
+	if len(m.keys) == 0 {
+		return data, false
+	}
+	if m.fallback == nil {
+		m.fallback = detachFallback(m.keys)
+	}
+
+	out := make([]byte, 0, len(data)+m.matched)
+	for _, b := range data {
+		// The held-back bytes are always keys[:matched]; on a mismatch release the oldest of
+		// them, keeping the longest tail that still starts the sequence
+		for m.matched > 0 && b != m.keys[m.matched] {
+			keep := m.fallback[m.matched-1]
+			out = append(out, m.keys[:m.matched-keep]...)
+			m.matched = keep
+		}
+		if b != m.keys[m.matched] {
+			out = append(out, b)
+			continue
+		}
+		m.matched++
+		if m.matched == len(m.keys) {
+			m.matched = 0
+			return out, true
+		}
+	}
+	return out, false
+}
+
+// detachFallback builds the Knuth-Morris-Pratt failure table of the detach sequence
+func detachFallback(keys []byte) []int {
+	fallback := make([]int, len(keys))
+	for i, k := 1, 0; i < len(keys); i++ {
+		for k > 0 && keys[i] != keys[k] {
+			k = fallback[k-1]
+		}
+		if keys[i] == keys[k] {
+			k++
+		}
+		fallback[i] = k
+	}
+	return fallback
+}
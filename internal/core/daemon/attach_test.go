@@ -0,0 +1,40 @@
+package daemon
+
+import "testing"
+
+func TestDetachMatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		reads   []string
+		out     string
+		matched bool
+	}{
+		{"plain input", "\x10\x11", []string{"ls\r"}, "ls\r", false},
+		{"sequence", "\x10\x11", []string{"ab\x10\x11"}, "ab", true},
+		{"split across reads", "\x10\x11", []string{"a\x10", "\x11"}, "a", true},
+		{"false start", "\x10\x11", []string{"\x10x"}, "\x10x", false},
+		{"repeated prefix", "aab", []string{"aaab"}, "a", true},
+		{"repeated key", "\x10\x10\x11", []string{"\x10\x10\x10\x11"}, "\x10", true},
+		{"overlapping", "abac", []string{"ababac"}, "ab", true},
+		{"released in order", "aab", []string{"aa", "ax"}, "aaax", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &detachMatcher{keys: []byte(tt.keys)}
+			var out []byte
+			matched := false
+			for _, read := range tt.reads {
+				data, done := m.feed([]byte(read))
+				out = append(out, data...)
+				if done {
+					matched = true
+					break
+				}
+			}
+			if string(out) != tt.out || matched != tt.matched {
+				t.Errorf("got %q, %v; want %q, %v", out, matched, tt.out, tt.matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// BroadcastTarget is one session taking part in a broadcast
+type BroadcastTarget struct {
+	// Name prefixes every line of the session's output
+	Name string
+	// Stream is a read-only attached stream carrying the session's output
+	Stream Stream
+	// Prompt is the line the session's cursor was on when the broadcast started
+	Prompt string
+	// Input is a stream from OpenInput typing into the session
+	Input Stream
+}
+
+// BroadcastConfig holds configuration for broadcasting the local terminal
+type BroadcastConfig struct {
+	// DetachKeys is the raw byte sequence that ends the broadcast instead of being sent
+	DetachKeys []byte
+}
+
+// BroadcastResult describes how a broadcast ended
+type BroadcastResult struct {
+	// Detached is true when the user left while sessions were still running
+	Detached bool
+}
+
+// broadcastFrame is a frame received from one of the broadcast's streams
+type broadcastFrame struct {
+	index int
+	frame Frame
+	err   error
+}
+
+// broadcastInputQueue is how many typed chunks a session may fall behind by before it is
+// dropped from the broadcast, so one hung session cannot hold up the others
+const broadcastInputQueue = 256
+
+// broadcastFlushTimeout bounds delivering input still queued when the user detaches
+const broadcastFlushTimeout = time.Second
+
+// prefixColors are the SGR foreground colors cycled through for target prefixes
+var prefixColors = []int{32, 33, 34, 35, 36, 92, 93, 94, 95, 96}
+
+// BroadcastTerminal sends everything typed on the process's terminal to every target and shows
+// their output interleaved, one prefixed line at a time. It returns when the user detaches or
+// every session has ended.
+func BroadcastTerminal(targets []BroadcastTarget, config BroadcastConfig) (*BroadcastResult, error) {
+	fd := int(os.Stdin.Fd())
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	display := newBroadcastDisplay(os.Stdout, targets)
+	display.resize(fd)
+	display.redraw(nil)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	frames := make(chan broadcastFrame, 64)
+	for i, target := range targets {
+		go func(i int, stream Stream) {
+			for {
+				frame, err := stream.Recv()
+				select {
+				case frames <- broadcastFrame{index: i, frame: frame, err: err}:
+				case <-done:
+					return
+				}
+				if err != nil || frame.Type == FrameExit || frame.Type == FrameError {
+					return
+				}
+			}
+		}(i, target.Stream)
+	}
+
+	// Every target has its own input writer and queue, so a slow session only delays itself;
+	// writers report the first failure, and the daemon's error frames, on inputFailed
+	inputFailed := make(chan broadcastFrame, 2*len(targets))
+	queues := make([]chan []byte, len(targets))
+	flushed := make([]chan struct{}, len(targets))
+	for i, target := range targets {
+		queues[i] = make(chan []byte, broadcastInputQueue)
+		flushed[i] = make(chan struct{})
+		go func(i int, stream Stream, queue chan []byte) {
+			defer close(flushed[i])
+			for data := range queue {
+				if err := stream.Send(Frame{Type: FrameInput, Data: data}); err != nil {
+					inputFailed <- broadcastFrame{index: i, err: err}
+					return
+				}
+			}
+		}(i, target.Input, queues[i])
+		go func(i int, stream Stream) {
+			frame, err := stream.Recv()
+			if err == nil && frame.Type == FrameError {
+				inputFailed <- broadcastFrame{index: i, err: errors.New(frame.Error)}
+			}
+		}(i, target.Input)
+	}
+
+	// Typed input is read on its own goroutine and handed over so sends stay ordered
+	input := make(chan []byte, 16)
+	detach := make(chan struct{})
+	go func() {
+		matcher := &detachMatcher{keys: config.DetachKeys}
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				data, matched := matcher.feed(buf[:n])
+				if len(data) > 0 {
+					select {
+					case input <- data:
+					case <-done:
+						return
+					}
+				}
+				if matched {
+					close(detach)
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Losing our own terminal ends the broadcast; the sessions keep running
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	sigwinch := make(chan os.Signal, 1)
+	signal.Notify(sigwinch, syscall.SIGWINCH)
+	defer signal.Stop(sigwinch)
+
+	active := len(targets)
+	ended := make([]bool, len(targets))
+	// leave takes a target out of the broadcast, stopping its input writer
+	leave := func(i int, message string) {
+		ended[i] = true
+		active--
+		close(queues[i])
+		_ = targets[i].Stream.Close()
+		_ = targets[i].Input.Close()
+		display.notice(i, message)
+	}
+	// closeStreams lets the input writers deliver what is still queued before closing everything
+	closeStreams := func() {
+		for i := range targets {
+			if !ended[i] {
+				close(queues[i])
+			}
+		}
+		deadline := time.After(broadcastFlushTimeout)
+		for i := range targets {
+			select {
+			case <-flushed[i]:
+			case <-deadline:
+			}
+		}
+		for _, target := range targets {
+			_ = target.Stream.Send(Frame{Type: FrameDetach})
+			_ = target.Stream.Close()
+			_ = target.Input.Close()
+		}
+	}
+	for active > 0 {
+		select {
+		case <-detach:
+			closeStreams()
+			display.finish()
+			return &BroadcastResult{Detached: true}, nil
+
+		case <-sigChan:
+			closeStreams()
+			display.finish()
+			return &BroadcastResult{Detached: true}, nil
+
+		case <-sigwinch:
+			display.resize(fd)
+			display.redraw(nil)
+
+		case data := <-input:
+			for i := range targets {
+				if ended[i] {
+					continue
+				}
+				select {
+				case queues[i] <- data:
+				default:
+					leave(i, "not keeping up with input, leaving the broadcast")
+				}
+			}
+
+		case f := <-inputFailed:
+			if !ended[f.index] {
+				leave(f.index, fmt.Sprintf("input failed, leaving the broadcast: %v", f.err))
+			}
+
+		case f := <-frames:
+			if ended[f.index] {
+				continue
+			}
+			switch {
+			case f.err != nil:
+				if f.err != io.EOF {
+					leave(f.index, fmt.Sprintf("lost connection to daemon: %v", f.err))
+				} else {
+					leave(f.index, "session ended")
+				}
+			case f.frame.Type == FrameOutput:
+				display.output(f.index, f.frame.Data)
+			case f.frame.Type == FrameError:
+				leave(f.index, f.frame.Error)
+			case f.frame.Type == FrameExit:
+				message := "session ended"
+				if exit := f.frame.Exit; exit != nil {
+					message = fmt.Sprintf("session %s with status %d", exit.Reason, exit.Code)
+				}
+				leave(f.index, message)
+			}
+		}
+	}
+
+	display.finish()
+	return &BroadcastResult{}, nil
+}
+
+// broadcastDisplay prints finished lines from every session as they complete and keeps each
+// session's unfinished line, usually its prompt, redrawn below them
+type broadcastDisplay struct {
+	w     io.Writer
+	lines []*lineAssembler
+	names []string
+	live  int
+	width int
+}
+
+// newBroadcastDisplay creates a display with aligned, colored prefixes for the targets
+func newBroadcastDisplay(w io.Writer, targets []BroadcastTarget) *broadcastDisplay {
+	nameWidth := 0
+	for _, target := range targets {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(target.Name))
+	}
+
+	d := &broadcastDisplay{w: w, width: 80}
+	for i, target := range targets {
+		color := prefixColors[i%len(prefixColors)]
+		padding := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(target.Name))
+		d.names = append(d.names, fmt.Sprintf("\x1b[1;%dm%s\x1b[0m%s | ", color, target.Name, padding))
+		d.lines = append(d.lines, &lineAssembler{partial: []rune(target.Prompt)})
+	}
+	return d
+}
+
+// resize picks up the local terminal width so live lines never wrap
+func (d *broadcastDisplay) resize(fd int) {
+	if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+		d.width = width
+	}
+}
+
+// output adds a chunk of a session's output
+func (d *broadcastDisplay) output(i int, data []byte) {
+	finished := d.lines[i].feed(data)
+	prefixed := make([]string, len(finished))
+	for j, line := range finished {
+		prefixed[j] = d.names[i] + line
+	}
+	d.redraw(prefixed)
+}
+
+// notice prints a message about a session and drops its unfinished line
+func (d *broadcastDisplay) notice(i int, message string) {
+	d.lines[i].partial = nil
+	d.redraw([]string{d.names[i] + "\x1b[2m" + message + "\x1b[0m"})
+}
+
+// redraw erases the live lines, prints newly finished ones and draws the live lines again
+func (d *broadcastDisplay) redraw(finished []string) {
+	var b strings.Builder
+	b.WriteString("\r")
+	if d.live > 1 {
+		fmt.Fprintf(&b, "\x1b[%dA", d.live-1)
+	}
+	b.WriteString("\x1b[J")
+
+	for _, line := range finished {
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+
+	d.live = 0
+	for i, line := range d.lines {
+		if len(line.partial) == 0 {
+			continue
+		}
+		if d.live > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(d.names[i])
+		b.WriteString(d.fit(line.partial, d.width-utf8.RuneCountInString(stripSGR(d.names[i]))-1))
+		d.live++
+	}
+
+	_, _ = io.WriteString(d.w, b.String())
+}
+
+// fit keeps the end of a live line, where the cursor is, within the available columns
+func (d *broadcastDisplay) fit(line []rune, columns int) string {
+	if columns < 1 {
+		return ""
+	}
+	if len(line) <= columns {
+		return string(line)
+	}
+	return "…" + string(line[len(line)-columns+1:])
+}
+
+// finish leaves the cursor below the live lines
+func (d *broadcastDisplay) finish() {
+	if d.live > 0 {
+		_, _ = io.WriteString(d.w, "\r\n")
+		d.live = 0
+	}
+}
+
+// stripSGR removes color sequences to measure a prefix
+func stripSGR(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// lineAssembler turns terminal output into plain lines, dropping escape sequences and
+// applying carriage returns and backspaces to the line being built
+type lineAssembler struct {
+	filter   terminal.EscapeFilter
+	partial  []rune
+	pending  []byte
+	returned bool
+}
+
+// feed adds output and returns the lines it finished
+func (l *lineAssembler) feed(data []byte) []string {
+	var finished []string
+
+	data = append(l.pending, data...)
+	l.pending = nil
+	for len(data) > 0 {
+		b := data[0]
+
+		// A carriage return not followed by a newline redraws the line from its start
+		if l.returned && !l.filter.InEscape() {
+			l.returned = false
+			if b != '\n' {
+				l.partial = nil
+			}
+		}
+
+		if !l.filter.InEscape() && b >= 0x80 {
+			if !utf8.FullRune(data) {
+				l.pending = append([]byte(nil), data...)
+				break
+			}
+			r, size := utf8.DecodeRune(data)
+			l.partial = append(l.partial, r)
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+		if !l.filter.Text(b) {
+			continue
+		}
+
+		switch b {
+		case '\n':
+			finished = append(finished, string(l.partial))
+			l.partial = nil
+		case '\r':
+			l.returned = true
+		case '\b', 0x7f:
+			if len(l.partial) > 0 {
+				l.partial = l.partial[:len(l.partial)-1]
+			}
+		case '\t':
+			l.partial = append(l.partial, ' ')
+		default:
+			if b >= 0x20 {
+				l.partial = append(l.partial, rune(b))
+			}
+		}
+	}
+
+	return finished
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLineAssembler(t *testing.T) {
+	tests := []struct {
+		name        string
+		writes      []string
+		wantLines   []string
+		wantPartial string
+	}{
+		{name: "lines", writes: []string{"one\r\ntwo\r\nthr"}, wantLines: []string{"one", "two"}, wantPartial: "thr"},
+		{name: "escape sequences are dropped", writes: []string{"\x1b[32mok\x1b[0m\r\n\x1b]0;title\x07$ "}, wantLines: []string{"ok"}, wantPartial: "$ "},
+		{name: "carriage return redraws the line", writes: []string{"10%\r50%\r100%\r\n"}, wantLines: []string{"100%"}},
+		{name: "carriage return before an erase", writes: []string{"old\r\x1b[Knew"}, wantPartial: "new"},
+		{name: "backspace removes a character", writes: []string{"ab\bc"}, wantPartial: "ac"},
+		{name: "split escape sequence and rune", writes: []string{"a\x1b[3", "1m\xe5\xaf", "\x86\r", "\n"}, wantLines: []string{"a密"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l lineAssembler
+			var lines []string
+			for _, write := range tt.writes {
+				lines = append(lines, l.feed([]byte(write))...)
+			}
+			if !reflect.DeepEqual(lines, tt.wantLines) {
+				t.Errorf("lines = %q, want %q", lines, tt.wantLines)
+			}
+			if string(l.partial) != tt.wantPartial {
+				t.Errorf("partial = %q, want %q", string(l.partial), tt.wantPartial)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"errors"
+	"time"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// ErrNotRunning is returned when no daemon is listening on the socket
+var ErrNotRunning = errors.New("hama-shell daemon is not running")
+
+// Client talks to a running daemon over its Unix socket
+type Client interface {
+	// Ping checks that the daemon is reachable and returns its process ID
+	Ping() (int, error)
+
+	// CreateSession starts a new session inside the daemon
+	CreateSession(config terminal.SessionConfig) (*SessionInfo, error)
+
+	// ListSessions returns every session owned by the daemon
+	ListSessions() ([]SessionInfo, error)
+
+	// Attach opens a stream to a running session
+	Attach(sessionID string, opts AttachOptions) (Stream, error)
+
+	// StopSession stops a session, waiting up to grace (zero uses the session's) before SIGKILL
+	StopSession(sessionID string, grace time.Duration) (*terminal.StopResult, error)
+
+	// Events opens a stream of session lifecycle events, starting with the recent history.
+	// Without follow the stream ends after the history.
+	Events(follow bool) (EventStream, error)
+
+	// Peek returns what a session's terminal currently shows, as plain text or with ANSI attributes
+	Peek(sessionID string, ansi bool) (*terminal.ScreenSnapshot, error)
+
+	// SendInput types data into a running session without attaching to it
+	SendInput(sessionID string, data []byte) error
+
+	// OpenInput opens a stream that types each input frame sent on it into a running session,
+	// for clients that send often; the daemon ends it with an error frame when a write fails
+	OpenInput(sessionID string) (Stream, error)
+
+	// Shutdown asks the daemon to terminate all sessions and exit
+	Shutdown() error
+
+	// GetSocketPath returns the socket the client connects to
+	GetSocketPath() string
+}
+
+// Stream is an open connection to an attached session
+type Stream interface {
+	// Send writes a frame to the session
+	Send(frame Frame) error
+
+	// Recv reads the next frame from the session
+	Recv() (Frame, error)
+
+	// Close closes the stream without notifying the session
+	Close() error
+
+	// GetSession returns the session the stream is attached to
+	GetSession() SessionInfo
+}
+
+// EventStream is an open connection receiving session lifecycle events
+type EventStream interface {
+	// Recv reads the next event; it returns io.EOF once the stream ends
+	Recv() (*terminal.Event, error)
+
+	// Close closes the stream
+	Close() error
+}
+
+// AttachOptions holds options for attaching to a session
+type AttachOptions struct {
+	// ReadOnly attaches as an observer that cannot send input
+	ReadOnly bool
+	// SkipScrollback starts at live output instead of replaying the session's recent output
+	SkipScrollback bool
+}
+
+// ClientConfig holds configuration for the daemon client
+type ClientConfig struct {
+	SocketPath string
+	Timeout    time.Duration
+}
@@ -0,0 +1,251 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"time"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// daemonClient implements Client interface
+type daemonClient struct {
+	socketPath string
+	timeout    time.Duration
+}
+
+// daemonStream implements Stream interface
+type daemonStream struct {
+	fc      *frameConn
+	session SessionInfo
+}
+
+// daemonEventStream implements EventStream interface
+type daemonEventStream struct {
+	fc *frameConn
+}
+
+// NewClient creates a new daemon client for the default socket
+func NewClient() Client {
+	return NewClientWithConfig(ClientConfig{})
+}
+
+// NewClientWithConfig creates a new daemon client with configuration
+func NewClientWithConfig(config ClientConfig) Client {
+	if config.SocketPath == "" {
+		config.SocketPath = DefaultSocketPath()
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	return &daemonClient{
+		socketPath: config.SocketPath,
+		timeout:    config.Timeout,
+	}
+}
+
+// Ping checks that the daemon is reachable and returns its process ID
+func (dc *daemonClient) Ping() (int, error) {
+	resp, err := dc.call(Request{Op: OpPing})
+	if err != nil {
+		return 0, err
+	}
+	return resp.PID, nil
+}
+
+// CreateSession starts a new session inside the daemon
+func (dc *daemonClient) CreateSession(config terminal.SessionConfig) (*SessionInfo, error) {
+	// An SSH session connects before the daemon answers, which each jump host and the target
+	// bound by their own connect timeout, so only the dial is bounded; giving up earlier would
+	// leave the session the daemon goes on to create without a client
+	resp, err := dc.callWithTimeout(Request{Op: OpCreateSession, Session: &config}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Session, nil
+}
+
+// ListSessions returns every session owned by the daemon
+func (dc *daemonClient) ListSessions() ([]SessionInfo, error) {
+	resp, err := dc.call(Request{Op: OpListSessions})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// Attach opens a stream to a running session
+func (dc *daemonClient) Attach(sessionID string, opts AttachOptions) (Stream, error) {
+	fc, resp, err := dc.open(Request{Op: OpAttach, SessionID: sessionID, ReadOnly: opts.ReadOnly, SkipScrollback: opts.SkipScrollback})
+	if err != nil {
+		return nil, err
+	}
+
+	// Attached streams live as long as the session, so drop the request deadline
+	_ = fc.conn.SetDeadline(time.Time{})
+
+	return &daemonStream{fc: fc, session: *resp.Session}, nil
+}
+
+// StopSession stops a session, waiting up to grace (zero uses the session's) before SIGKILL
+func (dc *daemonClient) StopSession(sessionID string, grace time.Duration) (*terminal.StopResult, error) {
+	// The daemon answers once the session has ended, which can take the whole grace period
+	// and more when the session's own grace period applies, so only the dial is bounded
+	resp, err := dc.callWithTimeout(Request{Op: OpStopSession, SessionID: sessionID, Grace: grace}, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Stop, nil
+}
+
+// Events opens a stream of session lifecycle events, starting with the recent history
+func (dc *daemonClient) Events(follow bool) (EventStream, error) {
+	fc, _, err := dc.open(Request{Op: OpEvents, Follow: follow})
+	if err != nil {
+		return nil, err
+	}
+
+	// Followed streams stay open indefinitely, so drop the request deadline
+	_ = fc.conn.SetDeadline(time.Time{})
+
+	return &daemonEventStream{fc: fc}, nil
+}
+
+// Peek returns what a session's terminal currently shows
+func (dc *daemonClient) Peek(sessionID string, ansi bool) (*terminal.ScreenSnapshot, error) {
+	resp, err := dc.call(Request{Op: OpPeek, SessionID: sessionID, ANSI: ansi})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Screen, nil
+}
+
+// SendInput types data into a running session without attaching to it
+func (dc *daemonClient) SendInput(sessionID string, data []byte) error {
+	_, err := dc.call(Request{Op: OpSendInput, SessionID: sessionID, Input: data})
+	return err
+}
+
+// OpenInput opens a stream that types each input frame sent on it into a running session
+func (dc *daemonClient) OpenInput(sessionID string) (Stream, error) {
+	fc, resp, err := dc.open(Request{Op: OpInput, SessionID: sessionID})
+	if err != nil {
+		return nil, err
+	}
+
+	// Input streams stay open as long as the client types, so drop the request deadline
+	_ = fc.conn.SetDeadline(time.Time{})
+
+	return &daemonStream{fc: fc, session: *resp.Session}, nil
+}
+
+// Shutdown asks the daemon to terminate all sessions and exit
+func (dc *daemonClient) Shutdown() error {
+	_, err := dc.call(Request{Op: OpShutdown})
+	return err
+}
+
+// GetSocketPath returns the socket the client connects to
+func (dc *daemonClient) GetSocketPath() string {
+	return dc.socketPath
+}
+
+// call sends a single request and waits for its response
+func (dc *daemonClient) call(req Request) (*Response, error) {
+	return dc.callWithTimeout(req, dc.timeout)
+}
+
+// callWithTimeout is call with its own deadline for the exchange; zero means no deadline
+func (dc *daemonClient) callWithTimeout(req Request, timeout time.Duration) (*Response, error) {
+	fc, resp, err := dc.openWithTimeout(req, timeout)
+	if err != nil {
+		return nil, err
+	}
+	fc.Close()
+	return resp, nil
+}
+
+// open dials the daemon, sends req and reads the response, leaving the connection open
+func (dc *daemonClient) open(req Request) (*frameConn, *Response, error) {
+	return dc.openWithTimeout(req, dc.timeout)
+}
+
+// openWithTimeout is open with its own deadline for the exchange; zero means no deadline
+func (dc *daemonClient) openWithTimeout(req Request, timeout time.Duration) (*frameConn, *Response, error) {
+	// A missing directory just means no daemon; anything else must be ours alone
+	if err := checkSocketDir(filepath.Dir(dc.socketPath)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+		}
+		return nil, nil, fmt.Errorf("refusing to connect: %w", err)
+	}
+	conn, err := net.DialTimeout("unix", dc.socketPath, dc.timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	if timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	fc := newFrameConn(conn)
+	if err := fc.send(req); err != nil {
+		fc.Close()
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	var resp Response
+	if err := fc.recv(&resp); err != nil {
+		fc.Close()
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		fc.Close()
+		return nil, nil, errors.New(resp.Error)
+	}
+
+	return fc, &resp, nil
+}
+
+// Send writes a frame to the session
+func (s *daemonStream) Send(frame Frame) error {
+	return s.fc.send(frame)
+}
+
+// Recv reads the next frame from the session
+func (s *daemonStream) Recv() (Frame, error) {
+	var frame Frame
+	err := s.fc.recv(&frame)
+	return frame, err
+}
+
+// Close closes the stream without notifying the session
+func (s *daemonStream) Close() error {
+	return s.fc.Close()
+}
+
+// GetSession returns the session the stream is attached to
+func (s *daemonStream) GetSession() SessionInfo {
+	return s.session
+}
+
+// Recv reads the next event; it returns io.EOF once the stream ends
+func (s *daemonEventStream) Recv() (*terminal.Event, error) {
+	for {
+		var frame Frame
+		if err := s.fc.recv(&frame); err != nil {
+			return nil, err
+		}
+		if frame.Type == FrameEvent && frame.Event != nil {
+			return frame.Event, nil
+		}
+	}
+}
+
+// Close closes the stream
+func (s *daemonEventStream) Close() error {
+	return s.fc.Close()
+}
@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+)
+
+// frameConn exchanges JSON messages over a socket connection
+type frameConn struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+	mu   sync.Mutex
+}
+
+// newFrameConn wraps a socket connection
+func newFrameConn(conn net.Conn) *frameConn {
+	return &frameConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+// send writes one message; safe for concurrent use
+func (fc *frameConn) send(v interface{}) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.enc.Encode(v)
+}
+
+// sendWith runs prepare while holding the write lock and sends the message it returns,
+// so nothing else can be written to the connection before it
+func (fc *frameConn) sendWith(prepare func() interface{}) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.enc.Encode(prepare())
+}
+
+// recv reads one message
+func (fc *frameConn) recv(v interface{}) error {
+	return fc.dec.Decode(v)
+}
+
+// Close closes the underlying connection
+func (fc *frameConn) Close() error {
+	return fc.conn.Close()
+}
+
+// frameWriter turns PTY output into output frames
+type frameWriter struct {
+	fc *frameConn
+}
+
+// Write sends p to the client as a single output frame
+func (fw *frameWriter) Write(p []byte) (int, error) {
+	if err := fw.fc.send(Frame{Type: FrameOutput, Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,29 @@
+package daemon
+
+import (
+	"io"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// terminalOutput is the single path session output takes to the local terminal,
+// shared by live attaches and replays and optionally recorded on the way
+type terminalOutput struct {
+	w        io.Writer
+	recorder *terminal.Recorder
+}
+
+// write shows session output and records it
+func (o *terminalOutput) write(data []byte) {
+	_, _ = o.w.Write(data)
+	if o.recorder != nil {
+		o.recorder.Output(data)
+	}
+}
+
+// resize records a change of session size; a local terminal cannot follow it
+func (o *terminalOutput) resize(rows, cols uint16) {
+	if o.recorder != nil {
+		o.recorder.Resize(rows, cols)
+	}
+}
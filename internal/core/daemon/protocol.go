@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"time"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// Operations understood by the daemon
+const (
+	OpPing          = "ping"
+	OpCreateSession = "create"
+	OpListSessions  = "list"
+	OpAttach        = "attach"
+	OpStopSession   = "stop"
+	OpEvents        = "events"
+	OpPeek          = "peek"
+	OpSendInput     = "send"
+	OpInput         = "input"
+	OpShutdown      = "shutdown"
+)
+
+// Frame types exchanged on an attached stream
+const (
+	FrameInput  = "input"
+	FrameOutput = "output"
+	FrameResize = "resize"
+	FrameDetach = "detach"
+	FrameExit   = "exit"
+	FrameEvent  = "event"
+	FrameError  = "error"
+)
+
+// Request is the first message a client sends on a new connection
+type Request struct {
+	Op        string                  `json:"op"`
+	SessionID string                  `json:"session_id,omitempty"`
+	Session   *terminal.SessionConfig `json:"session,omitempty"`
+	ReadOnly  bool                    `json:"read_only,omitempty"`
+	// SkipScrollback attaches without replaying the session's recent output
+	SkipScrollback bool `json:"skip_scrollback,omitempty"`
+	// Grace overrides the session's stop grace period for stop requests
+	Grace time.Duration `json:"grace,omitempty"`
+	// Follow keeps an events stream open for new events after the recent history
+	Follow bool `json:"follow,omitempty"`
+	// ANSI renders peeked screens with their colors and attributes
+	ANSI bool `json:"ansi,omitempty"`
+	// Input is written to the session's terminal by send requests
+	Input []byte `json:"input,omitempty"`
+}
+
+// Response answers a Request
+type Response struct {
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	PID      int           `json:"pid,omitempty"`
+	Session  *SessionInfo  `json:"session,omitempty"`
+	Sessions []SessionInfo `json:"sessions,omitempty"`
+	// Stop is set on responses to stop requests
+	Stop *terminal.StopResult `json:"stop,omitempty"`
+	// Screen is set on responses to peek requests
+	Screen *terminal.ScreenSnapshot `json:"screen,omitempty"`
+}
+
+// Frame is a single message on an attached stream
+type Frame struct {
+	Type string `json:"type"`
+	Data []byte `json:"data,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	// Exit is set on exit frames
+	Exit *terminal.ExitStatus `json:"exit,omitempty"`
+	// Event is set on event frames
+	Event *terminal.Event `json:"event,omitempty"`
+	// Error is set on error frames, which end the stream
+	Error string `json:"error,omitempty"`
+}
+
+// SessionInfo describes a session owned by the daemon
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	Target    string    `json:"target,omitempty"`
+	Command   string    `json:"command,omitempty"`
+	PID       int       `json:"pid"`
+	Running   bool      `json:"running"`
+	StartTime time.Time `json:"start_time"`
+	Clients   int       `json:"clients"`
+	// Checkpoint is the last checkpoint step the session's startup passed
+	Checkpoint string `json:"checkpoint,omitempty"`
+	// Health is starting, ready or unhealthy while the session runs; HealthError is the failing probe
+	Health      string `json:"health,omitempty"`
+	HealthError string `json:"health_error,omitempty"`
+	// Restarts is how often the session's restart policy has started its process again, and
+	// Restarting is set while it waits to do so
+	Restarts   int  `json:"restarts,omitempty"`
+	Restarting bool `json:"restarting,omitempty"`
+	// Forwards are the session's port forwards and their open connections
+	Forwards []terminal.ForwardInfo `json:"forwards,omitempty"`
+	// Exit is set once the session has ended
+	Exit *terminal.ExitStatus `json:"exit,omitempty"`
+}
+
+// newSessionInfo builds the wire representation of a terminal session
+func newSessionInfo(session terminal.Session) SessionInfo {
+	info := session.GetInfo()
+
+	sessionInfo := SessionInfo{
+		ID:        session.GetID(),
+		PID:       session.GetPID(),
+		StartTime: session.GetStartTime(),
+		Exit:      session.GetExitStatus(),
+	}
+	if target, ok := info["target"].(string); ok {
+		sessionInfo.Target = target
+	}
+	if command, ok := info["command"].(string); ok {
+		sessionInfo.Command = command
+	}
+	if running, ok := info["running"].(bool); ok {
+		sessionInfo.Running = running
+	}
+	if clients, ok := info["clients"].(int); ok {
+		sessionInfo.Clients = clients
+	}
+	if checkpoint, ok := info["checkpoint"].(string); ok {
+		sessionInfo.Checkpoint = checkpoint
+	}
+	if health, ok := info["health"].(string); ok {
+		sessionInfo.Health = health
+	}
+	if healthError, ok := info["health_error"].(string); ok {
+		sessionInfo.HealthError = healthError
+	}
+	if restarts, ok := info["restarts"].(int); ok {
+		sessionInfo.Restarts = restarts
+	}
+	if restarting, ok := info["restarting"].(bool); ok {
+		sessionInfo.Restarting = restarting
+	}
+	if forwards, ok := info["forwards"].([]terminal.ForwardInfo); ok {
+		sessionInfo.Forwards = forwards
+	}
+
+	return sessionInfo
+}
@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/term"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// replaySeekStep is how far the arrow keys move a replay
+const replaySeekStep = 5 * time.Second
+
+// ReplayConfig holds playback settings for a recording
+type ReplayConfig struct {
+	// Speed multiplies playback speed; zero plays at recorded speed
+	Speed float64
+	// IdleLimit caps pauses in the recording; zero keeps them as recorded
+	IdleLimit time.Duration
+}
+
+// replayAction is a playback control read from the keyboard
+type replayAction int
+
+// Playback controls
+const (
+	actionPause replayAction = iota
+	actionSeekForward
+	actionSeekBack
+	actionFaster
+	actionSlower
+	actionQuit
+)
+
+// ReplayTerminal plays a recording on the local terminal through the same output path as a live attach.
+// When stdin is a terminal, space pauses, the left and right arrows seek, + and - change speed and q quits.
+func ReplayTerminal(cast *terminal.Cast, config ReplayConfig) error {
+	if config.Speed <= 0 {
+		config.Speed = 1
+	}
+
+	player := &replayPlayer{
+		events: compressIdle(cast.Events, config.IdleLimit),
+		out:    &terminalOutput{w: os.Stdout},
+		speed:  config.Speed,
+	}
+
+	var actions <-chan replayAction
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		oldState, err := term.MakeRaw(fd)
+		if err == nil {
+			defer term.Restore(fd, oldState)
+			actions = readReplayActions()
+		}
+	}
+
+	player.run(actions)
+	return nil
+}
+
+// replayPlayer tracks the playback position in a recording
+type replayPlayer struct {
+	events []terminal.CastEvent
+	out    *terminalOutput
+	speed  float64
+	paused bool
+	// pos is the recording time shown so far; next is the first event not yet shown
+	pos  time.Duration
+	next int
+}
+
+// run plays events in real time, scaled by speed, until the end or a quit
+func (p *replayPlayer) run(actions <-chan replayAction) {
+	for p.next < len(p.events) {
+		var timer <-chan time.Time
+		waitStart := time.Now()
+		if !p.paused {
+			wait := time.Duration(float64(p.events[p.next].Time-p.pos) / p.speed)
+			timer = time.After(wait)
+		}
+
+		select {
+		case <-timer:
+			p.show(p.events[p.next])
+			p.pos = p.events[p.next].Time
+			p.next++
+		case action, ok := <-actions:
+			if !ok {
+				actions = nil
+				continue
+			}
+			if !p.paused {
+				// Keep the time already waited towards the next event
+				elapsed := time.Duration(float64(time.Since(waitStart)) * p.speed)
+				p.pos = min(p.pos+elapsed, p.events[p.next].Time)
+			}
+			if p.handle(action) {
+				return
+			}
+		}
+	}
+}
+
+// handle applies a playback control and returns true to stop playing
+func (p *replayPlayer) handle(action replayAction) bool {
+	switch action {
+	case actionPause:
+		p.paused = !p.paused
+	case actionSeekForward:
+		p.seek(p.pos + replaySeekStep)
+	case actionSeekBack:
+		p.seek(p.pos - replaySeekStep)
+	case actionFaster:
+		p.speed *= 2
+	case actionSlower:
+		p.speed /= 2
+	case actionQuit:
+		return true
+	}
+	return false
+}
+
+// seek jumps to a recording time. Going back redraws from the start, since terminal
+// output cannot be undone.
+func (p *replayPlayer) seek(target time.Duration) {
+	if target < 0 {
+		target = 0
+	}
+	if target < p.pos {
+		p.out.write([]byte("\x1bc"))
+		p.next = 0
+	}
+
+	for p.next < len(p.events) && p.events[p.next].Time <= target {
+		p.show(p.events[p.next])
+		p.next++
+	}
+	p.pos = target
+}
+
+// show sends one recorded event to the terminal
+func (p *replayPlayer) show(event terminal.CastEvent) {
+	if event.Type == terminal.CastOutput {
+		p.out.write([]byte(event.Data))
+	}
+}
+
+// compressIdle shortens every pause longer than limit to limit
+func compressIdle(events []terminal.CastEvent, limit time.Duration) []terminal.CastEvent {
+	if limit <= 0 {
+		return events
+	}
+
+	result := make([]terminal.CastEvent, len(events))
+	var shift, last time.Duration
+	for i, event := range events {
+		if gap := event.Time - last; gap > limit {
+			shift += gap - limit
+		}
+		last = event.Time
+		event.Time -= shift
+		result[i] = event
+	}
+	return result
+}
+
+// readReplayActions turns keystrokes into playback controls
+func readReplayActions() <-chan replayAction {
+	actions := make(chan replayAction, 16)
+	go func() {
+		defer close(actions)
+
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			for i := 0; i < n; i++ {
+				switch buf[i] {
+				case ' ':
+					actions <- actionPause
+				case '+', '=':
+					actions <- actionFaster
+				case '-':
+					actions <- actionSlower
+				case 'q', 0x03:
+					actions <- actionQuit
+				case 0x1b:
+					// Arrow keys arrive as ESC [ C and ESC [ D
+					if i+2 < n && buf[i+1] == '[' {
+						switch buf[i+2] {
+						case 'C':
+							actions <- actionSeekForward
+						case 'D':
+							actions <- actionSeekBack
+						}
+						i += 2
+					}
+				}
+			}
+		}
+	}()
+	return actions
+}
@@ -0,0 +1,21 @@
+package daemon
+
+import "hama-shell/internal/core/terminal"
+
+// Server owns the terminal server and serves clients over a Unix socket
+type Server interface {
+	// Serve accepts client connections until the server is shut down
+	Serve() error
+
+	// Shutdown stops accepting clients and terminates all sessions
+	Shutdown() error
+
+	// GetSocketPath returns the socket the server listens on
+	GetSocketPath() string
+}
+
+// ServerConfig holds configuration for the daemon server
+type ServerConfig struct {
+	SocketPath string
+	Terminal   terminal.ServerConfig
+}
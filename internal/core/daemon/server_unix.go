@@ -0,0 +1,388 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hama-shell/internal/core/terminal"
+)
+
+// daemonServer implements Server interface
+type daemonServer struct {
+	socketPath string
+	terminal   terminal.Server
+	listener   net.Listener
+	done       chan struct{}
+	stopped    chan struct{}
+	once       sync.Once
+	clientSeq  atomic.Uint64
+}
+
+// NewServer creates a new daemon server listening on the default socket
+func NewServer() Server {
+	return NewServerWithConfig(ServerConfig{})
+}
+
+// NewServerWithConfig creates a new daemon server with configuration
+func NewServerWithConfig(config ServerConfig) Server {
+	if config.SocketPath == "" {
+		config.SocketPath = DefaultSocketPath()
+	}
+	if config.Terminal.LogDir == "" {
+		config.Terminal.LogDir = SessionLogDir()
+	}
+
+	return &daemonServer{
+		socketPath: config.SocketPath,
+		terminal:   terminal.NewTerminalServerWithConfig(config.Terminal),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Serve accepts client connections until the server is shut down
+func (ds *daemonServer) Serve() error {
+	if err := ds.listen(); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := ds.listener.Accept()
+		if err != nil {
+			select {
+			case <-ds.done:
+				// Let sessions finish their graceful stop before the process exits
+				<-ds.stopped
+				return nil
+			default:
+				return fmt.Errorf("failed to accept connection: %w", err)
+			}
+		}
+		go ds.handleConn(newFrameConn(conn))
+	}
+}
+
+// Shutdown stops accepting clients and terminates all sessions
+func (ds *daemonServer) Shutdown() error {
+	var err error
+	ds.once.Do(func() {
+		close(ds.done)
+		if ds.listener != nil {
+			_ = ds.listener.Close()
+		}
+		err = ds.terminal.Shutdown()
+		_ = os.Remove(ds.socketPath)
+		close(ds.stopped)
+	})
+	return err
+}
+
+// GetSocketPath returns the socket the server listens on
+func (ds *daemonServer) GetSocketPath() string {
+	return ds.socketPath
+}
+
+// listen creates the per-user socket, replacing a stale one left by a dead daemon
+func (ds *daemonServer) listen() error {
+	if err := os.MkdirAll(filepath.Dir(ds.socketPath), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := checkSocketDir(filepath.Dir(ds.socketPath)); err != nil {
+		return fmt.Errorf("refusing to listen: %w", err)
+	}
+
+	if _, err := os.Stat(ds.socketPath); err == nil {
+		if conn, err := net.DialTimeout("unix", ds.socketPath, time.Second); err == nil {
+			conn.Close()
+			return fmt.Errorf("daemon already running at %s", ds.socketPath)
+		}
+		if err := os.Remove(ds.socketPath); err != nil {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", ds.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ds.socketPath, err)
+	}
+	if err := os.Chmod(ds.socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	ds.listener = listener
+	return nil
+}
+
+// clientDropTimeout bounds telling a client that fell behind why it is being disconnected
+const clientDropTimeout = 5 * time.Second
+
+// handleConn reads the request on a new connection and dispatches it
+func (ds *daemonServer) handleConn(fc *frameConn) {
+	defer fc.Close()
+
+	var req Request
+	if err := fc.recv(&req); err != nil {
+		return
+	}
+
+	switch req.Op {
+	case OpPing:
+		_ = fc.send(Response{OK: true, PID: os.Getpid()})
+	case OpCreateSession:
+		ds.handleCreateSession(fc, req)
+	case OpListSessions:
+		ds.handleListSessions(fc)
+	case OpAttach:
+		ds.handleAttach(fc, req)
+	case OpStopSession:
+		ds.handleStopSession(fc, req)
+	case OpEvents:
+		ds.handleEvents(fc, req)
+	case OpPeek:
+		ds.handlePeek(fc, req)
+	case OpSendInput:
+		ds.handleSendInput(fc, req)
+	case OpInput:
+		ds.handleInput(fc, req)
+	case OpShutdown:
+		_ = fc.send(Response{OK: true})
+		go ds.Shutdown()
+	default:
+		_ = fc.send(errorResponse(fmt.Errorf("unknown operation %q", req.Op)))
+	}
+}
+
+// handleCreateSession starts a new session in the terminal server
+func (ds *daemonServer) handleCreateSession(fc *frameConn, req Request) {
+	if req.Session == nil {
+		_ = fc.send(errorResponse(fmt.Errorf("missing session configuration")))
+		return
+	}
+
+	session, err := ds.terminal.CreateSessionWithConfig(*req.Session)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+
+	info := newSessionInfo(session)
+	_ = fc.send(Response{OK: true, Session: &info})
+}
+
+// handleListSessions returns every session owned by the daemon
+func (ds *daemonServer) handleListSessions(fc *frameConn) {
+	sessions := ds.terminal.ListSessions()
+
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, newSessionInfo(session))
+	}
+
+	_ = fc.send(Response{OK: true, Sessions: result})
+}
+
+// handleStopSession stops a session's process group and reports which step ended it
+func (ds *daemonServer) handleStopSession(fc *frameConn, req Request) {
+	result, err := ds.terminal.StopSession(req.SessionID, req.Grace)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+
+	_ = fc.send(Response{OK: true, Stop: result})
+}
+
+// handlePeek sends what a session's terminal currently shows
+func (ds *daemonServer) handlePeek(fc *frameConn, req Request) {
+	session, err := ds.terminal.GetSession(req.SessionID)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+
+	_ = fc.send(Response{OK: true, Screen: session.GetScreen(req.ANSI)})
+}
+
+// handleSendInput types input into a running session as if an attached client had
+func (ds *daemonServer) handleSendInput(fc *frameConn, req Request) {
+	session, err := ds.terminal.GetSession(req.SessionID)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+	if !session.IsRunning() {
+		_ = fc.send(errorResponse(fmt.Errorf("session %s is not running", req.SessionID)))
+		return
+	}
+
+	if err := session.WriteInput(req.Input); err != nil {
+		_ = fc.send(errorResponse(fmt.Errorf("failed to write to session %s: %w", req.SessionID, err)))
+		return
+	}
+	_ = fc.send(Response{OK: true})
+}
+
+// handleInput types every input frame the client sends into a running session until the client
+// closes the stream or a write fails, which is reported in an error frame
+func (ds *daemonServer) handleInput(fc *frameConn, req Request) {
+	session, err := ds.terminal.GetSession(req.SessionID)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+	if !session.IsRunning() {
+		_ = fc.send(errorResponse(fmt.Errorf("session %s is not running", req.SessionID)))
+		return
+	}
+
+	info := newSessionInfo(session)
+	if err := fc.send(Response{OK: true, Session: &info}); err != nil {
+		return
+	}
+	for {
+		var frame Frame
+		if err := fc.recv(&frame); err != nil {
+			return
+		}
+		if frame.Type != FrameInput {
+			continue
+		}
+		if err := session.WriteInput(frame.Data); err != nil {
+			_ = fc.send(Frame{Type: FrameError, Error: fmt.Sprintf("failed to write to session %s: %v", req.SessionID, err)})
+			return
+		}
+	}
+}
+
+// handleEvents sends the recent lifecycle events and, when following, every new one
+// until the client disconnects or the daemon shuts down
+func (ds *daemonServer) handleEvents(fc *frameConn, req Request) {
+	history, events, unsubscribe := ds.terminal.Events().Subscribe()
+	defer unsubscribe()
+
+	if err := fc.send(Response{OK: true}); err != nil {
+		return
+	}
+	for i := range history {
+		if err := fc.send(Frame{Type: FrameEvent, Event: &history[i]}); err != nil {
+			return
+		}
+	}
+	if !req.Follow {
+		return
+	}
+
+	// The client never writes on an events stream, so a read only returns once it goes away
+	gone := make(chan struct{})
+	go func() {
+		var frame Frame
+		_ = fc.recv(&frame)
+		close(gone)
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Fell too far behind; closing tells the client to reconnect
+				return
+			}
+			if err := fc.send(Frame{Type: FrameEvent, Event: &event}); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		case <-ds.done:
+			return
+		}
+	}
+}
+
+// handleAttach streams a session's output to the client and its input back to the session
+func (ds *daemonServer) handleAttach(fc *frameConn, req Request) {
+	session, err := ds.terminal.GetSession(req.SessionID)
+	if err != nil {
+		_ = fc.send(errorResponse(err))
+		return
+	}
+	if session.GetExitStatus() != nil {
+		_ = fc.send(errorResponse(fmt.Errorf("session %s has ended", req.SessionID)))
+		return
+	}
+
+	client := terminal.NewTerminalClient(ds.terminal, terminal.ClientConfig{
+		SessionID:      session.GetID(),
+		ClientID:       fmt.Sprintf("client-%d", ds.clientSeq.Add(1)),
+		ReadOnly:       req.ReadOnly,
+		Output:         &frameWriter{fc: fc},
+		SkipScrollback: req.SkipScrollback,
+		OnResize: func(rows, cols uint16) {
+			_ = fc.send(Frame{Type: FrameResize, Rows: rows, Cols: cols})
+		},
+		// Closing the connection ends the read loop below, so its input stops reaching the session.
+		// The deadline covers a write already stuck on the client as well as the error frame.
+		OnDrop: func(reason string) {
+			_ = fc.conn.SetWriteDeadline(time.Now().Add(clientDropTimeout))
+			_ = fc.send(Frame{Type: FrameError, Error: "detached: " + reason})
+			_ = fc.Close()
+		},
+	})
+
+	// Connect while holding the connection so the response precedes any replayed output
+	connected := false
+	err = fc.sendWith(func() interface{} {
+		if err := client.Connect(); err != nil {
+			return errorResponse(err)
+		}
+		connected = true
+		info := newSessionInfo(session)
+		return Response{OK: true, Session: &info}
+	})
+	if !connected {
+		return
+	}
+	defer client.Disconnect()
+	if err != nil {
+		return
+	}
+
+	// Read client frames until it detaches or goes away
+	detached := make(chan struct{})
+	go func() {
+		defer close(detached)
+		for {
+			var frame Frame
+			if err := fc.recv(&frame); err != nil {
+				return
+			}
+			switch frame.Type {
+			case FrameInput:
+				_ = client.SendInput(frame.Data)
+			case FrameResize:
+				_ = client.ResizeTerminal(frame.Rows, frame.Cols)
+			case FrameDetach:
+				return
+			}
+		}
+	}()
+
+	// Wait for session to finish
+	select {
+	case <-detached:
+	case <-session.Done():
+		// Deliver the remaining output before announcing the exit
+		_ = client.Disconnect()
+		_ = fc.send(Frame{Type: FrameExit, Exit: session.GetExitStatus()})
+	}
+}
+
+// errorResponse wraps an error in a failed Response
+func errorResponse(err error) Response {
+	return Response{OK: false, Error: err.Error()}
+}
@@ -0,0 +1,31 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// socketEnv overrides the default socket location
+const socketEnv = "HAMA_SHELL_SOCKET"
+
+// DefaultSocketPath returns the per-user Unix socket the daemon listens on
+func DefaultSocketPath() string {
+	if path := os.Getenv(socketEnv); path != "" {
+		return path
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "hama-shell", "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("hama-shell-%d", os.Getuid()), "daemon.sock")
+}
+
+// StateDir returns the directory holding daemon state such as its log file
+func StateDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".hama-shell")
+}
+
+// SessionLogDir returns the root of session output logs, one directory per target
+func SessionLogDir() string {
+	return filepath.Join(StateDir(), "logs")
+}
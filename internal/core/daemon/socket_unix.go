@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkSocketDir refuses a socket directory another user could have planted or can write to:
+// it must be a real directory owned by us with mode 0700, or clients would hand their
+// environment and expect secrets to whoever listens there
+func checkSocketDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("socket directory %s is a symlink", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("socket directory %s is not a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("socket directory %s is owned by uid %d, not %d", dir, stat.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("socket directory %s has mode %#o, want 0700", dir, perm)
+	}
+	return nil
+}
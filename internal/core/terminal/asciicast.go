@@ -0,0 +1,196 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Asciicast v2 event codes
+const (
+	CastOutput = "o"
+	CastInput  = "i"
+	CastResize = "r"
+	CastMarker = "m"
+)
+
+// CastHeader is the first line of an asciicast v2 recording
+type CastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// CastEvent is one timed event of a recording
+type CastEvent struct {
+	// Time is the offset from the start of the recording
+	Time time.Duration
+	Type string
+	Data string
+}
+
+// Cast is a recording loaded into memory
+type Cast struct {
+	Header CastHeader
+	Events []CastEvent
+}
+
+// Duration returns the time of the last event
+func (c *Cast) Duration() time.Duration {
+	if len(c.Events) == 0 {
+		return 0
+	}
+	return c.Events[len(c.Events)-1].Time
+}
+
+// Recorder writes terminal output and resizes as an asciicast v2 recording
+type Recorder struct {
+	mu      sync.Mutex
+	w       *bufio.Writer
+	start   time.Time
+	pending []byte
+	err     error
+}
+
+// NewRecorder writes the recording header and starts the clock for subsequent events
+func NewRecorder(w io.Writer, header CastHeader) (*Recorder, error) {
+	header.Version = 2
+	if header.Timestamp == 0 {
+		header.Timestamp = time.Now().Unix()
+	}
+
+	r := &Recorder{w: bufio.NewWriter(w), start: time.Now()}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode recording header: %w", err)
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+	return r, nil
+}
+
+// Output records data written to the terminal. A UTF-8 character split across two
+// writes is held back until it is complete, as events must be valid strings.
+func (r *Recorder) Output(data []byte) {
+	r.mu.Lock()
+	data = append(r.pending, data...)
+	cut := incompleteUTF8Suffix(data)
+	r.pending = append([]byte(nil), data[len(data)-cut:]...)
+	r.mu.Unlock()
+
+	if len(data) > cut {
+		r.record(CastOutput, string(data[:len(data)-cut]))
+	}
+}
+
+// Resize records a change of terminal size
+func (r *Recorder) Resize(rows, cols uint16) {
+	r.record(CastResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close flushes the recording and returns the first error it hit
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// record appends one event; output is lost only once the writer has failed
+func (r *Recorder) record(code, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	elapsed := time.Since(r.start).Seconds()
+	line, err := json.Marshal([]interface{}{json.Number(strconv.FormatFloat(elapsed, 'f', 6, 64)), code, data})
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	if err == nil && code == CastOutput {
+		// Keep the file useful if we are killed mid-session
+		err = r.w.Flush()
+	}
+	r.err = err
+}
+
+// incompleteUTF8Suffix returns how many bytes at the end of data start a UTF-8 character
+// that is not complete yet
+func incompleteUTF8Suffix(data []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if b < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// ReadCast loads an asciicast v2 recording
+func ReadCast(rd io.Reader) (*Cast, error) {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		return nil, fmt.Errorf("recording is empty")
+	}
+
+	cast := &Cast{}
+	if err := json.Unmarshal(scanner.Bytes(), &cast.Header); err != nil {
+		return nil, fmt.Errorf("invalid recording header: %w", err)
+	}
+	if cast.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", cast.Header.Version)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var fields []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &fields); err != nil || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid event on line %d", line)
+		}
+		seconds, ok1 := fields[0].(float64)
+		code, ok2 := fields[1].(string)
+		data, ok3 := fields[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("invalid event on line %d", line)
+		}
+
+		cast.Events = append(cast.Events, CastEvent{
+			Time: time.Duration(seconds * float64(time.Second)),
+			Type: code,
+			Data: data,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return cast, nil
+}
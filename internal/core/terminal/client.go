@@ -1,6 +1,9 @@
 package terminal
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // Client manages connection to a terminal server session
 type Client interface {
@@ -33,6 +36,9 @@ type Client interface {
 
 	// GetClientID returns this client's ID
 	GetClientID() string
+
+	// IsReadOnly returns true if the client only watches the session
+	IsReadOnly() bool
 }
 
 // ClientConfig holds configuration for terminal client
@@ -40,4 +46,15 @@ type ClientConfig struct {
 	SessionID string
 	ClientID  string
 	Timeout   time.Duration
+	// Output receives the session's scrollback and then its live PTY output while the client is connected
+	Output io.Writer
+	// OnResize is told the session's terminal size on connect and whenever it changes
+	OnResize func(rows, cols uint16)
+	// OnDrop is told why the session stopped sending output to a client that fell too far behind;
+	// it runs on its own goroutine and should end the client's connection
+	OnDrop func(reason string)
+	// ReadOnly clients watch the session but cannot send input
+	ReadOnly bool
+	// SkipScrollback starts the client at live output instead of replaying the scrollback
+	SkipScrollback bool
 }
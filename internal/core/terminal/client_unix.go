@@ -11,6 +11,8 @@ type terminalClient struct {
 	sessionID  string
 	server     Server
 	clientID   string
+	config     ClientConfig
+	session    Session
 	ctx        context.Context
 	cancel     context.CancelFunc
 	isAttached bool
@@ -31,6 +33,7 @@ func NewTerminalClient(server Server, config ClientConfig) Client {
 		sessionID: config.SessionID,
 		server:    server,
 		clientID:  config.ClientID,
+		config:    config,
 		ctx:       ctx,
 		cancel:    cancel,
 	}
@@ -43,11 +46,17 @@ func (tc *terminalClient) Connect() error {
 	}
 
 	// Check if session exists
-	_, err := tc.server.GetSession(tc.sessionID)
+	session, err := tc.server.GetSession(tc.sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to find session: %w", err)
 	}
 
+	// Replay the scrollback, then start receiving live PTY output
+	if err := session.AddClient(tc.config); err != nil {
+		return err
+	}
+
+	tc.session = session
 	tc.isAttached = true
 	return nil
 }
@@ -58,6 +67,9 @@ func (tc *terminalClient) Disconnect() error {
 		return nil
 	}
 
+	// Stop receiving PTY output
+	tc.session.RemoveClient(tc.clientID)
+
 	// Cancel context
 	tc.cancel()
 	tc.isAttached = false
@@ -71,8 +83,12 @@ func (tc *terminalClient) Wait() error {
 		return fmt.Errorf("client not connected")
 	}
 
-	// Wait for context to be done (timeout or cancellation)
-	<-tc.ctx.Done()
+	// Wait for the session to end or the context to be done (timeout or cancellation)
+	select {
+	case <-tc.session.Done():
+		return tc.session.Wait()
+	case <-tc.ctx.Done():
+	}
 
 	// Check if it was timeout or normal cancellation
 	if tc.ctx.Err() == context.DeadlineExceeded {
@@ -88,13 +104,12 @@ func (tc *terminalClient) SendInput(data []byte) error {
 	if !tc.isAttached {
 		return fmt.Errorf("client not connected")
 	}
-
-	session, err := tc.server.GetSession(tc.sessionID)
-	if err != nil {
-		return fmt.Errorf("session not found: %w", err)
+	if tc.config.ReadOnly {
+		return fmt.Errorf("client %s is read-only", tc.clientID)
 	}
 
-	return session.WriteInput(data)
+	tc.session.MarkClientActive(tc.clientID)
+	return tc.session.WriteInput(data)
 }
 
 // GetSessionInfo returns information about the connected session
@@ -113,7 +128,7 @@ func (tc *terminalClient) ResizeTerminal(rows, cols uint16) error {
 		return fmt.Errorf("client not connected")
 	}
 
-	return tc.server.ResizeSession(tc.sessionID, rows, cols)
+	return tc.session.SetClientSize(tc.clientID, rows, cols)
 }
 
 // ConnectAndWait is a convenience method that connects and waits
@@ -139,3 +154,8 @@ func (tc *terminalClient) GetSessionID() string {
 func (tc *terminalClient) GetClientID() string {
 	return tc.clientID
 }
+
+// IsReadOnly returns true if the client only watches the session
+func (tc *terminalClient) IsReadOnly() bool {
+	return tc.config.ReadOnly
+}
@@ -0,0 +1,101 @@
+package terminal
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// clientQueueSize is how many pending output chunks a client may fall behind by
+// before it is dropped, so one slow observer cannot stall the session
+const clientQueueSize = 1024
+
+// ClientInfo describes a client attached to a session
+type ClientInfo struct {
+	ID         string    `json:"id"`
+	ReadOnly   bool      `json:"read_only"`
+	AttachedAt time.Time `json:"attached_at"`
+	Rows       uint16    `json:"rows,omitempty"`
+	Cols       uint16    `json:"cols,omitempty"`
+}
+
+// clientEvent is a unit of work delivered to an attached client
+type clientEvent struct {
+	data       []byte
+	rows, cols uint16
+}
+
+// attachedClient delivers a session's output and resize events to one client
+type attachedClient struct {
+	info       ClientInfo
+	output     io.Writer
+	onResize   func(rows, cols uint16)
+	onDrop     func(reason string)
+	queue      chan clientEvent
+	done       chan struct{}
+	dropped    atomic.Bool
+	rows, cols uint16
+	lastActive time.Time
+}
+
+// newAttachedClient creates a client delivery queue from its configuration
+func newAttachedClient(config ClientConfig) *attachedClient {
+	return &attachedClient{
+		info: ClientInfo{
+			ID:         config.ClientID,
+			ReadOnly:   config.ReadOnly,
+			AttachedAt: time.Now(),
+		},
+		output:     config.Output,
+		onResize:   config.OnResize,
+		onDrop:     config.OnDrop,
+		queue:      make(chan clientEvent, clientQueueSize),
+		done:       make(chan struct{}),
+		lastActive: time.Now(),
+	}
+}
+
+// enqueue hands an event to the client without blocking; false means the client fell too far behind
+func (c *attachedClient) enqueue(event clientEvent) bool {
+	select {
+	case c.queue <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops delivery once everything already queued has been written
+func (c *attachedClient) close() {
+	close(c.queue)
+}
+
+// drop stops delivery straight away, discarding whatever is still queued
+func (c *attachedClient) drop() {
+	c.dropped.Store(true)
+	close(c.queue)
+}
+
+// run writes queued events to the client until the queue is closed
+func (c *attachedClient) run() {
+	defer close(c.done)
+
+	failed := false
+	for event := range c.queue {
+		// Keep draining after a failed write or a drop so the session never blocks on us
+		if failed || c.dropped.Load() {
+			continue
+		}
+		if event.data != nil {
+			if c.output != nil {
+				if _, err := c.output.Write(event.data); err != nil {
+					failed = true
+				}
+			}
+			continue
+		}
+		if c.onResize != nil {
+			c.onResize(event.rows, event.cols)
+		}
+	}
+}
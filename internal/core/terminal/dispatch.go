@@ -0,0 +1,89 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultPromptPatterns match the end of common shell prompts: $, #, % or > and an optional space
+var DefaultPromptPatterns = []string{`[$#%>] ?$`}
+
+// DefaultQuietPeriod is how long output must stop before the next command is sent without a prompt match
+const DefaultQuietPeriod = 2 * time.Second
+
+// DefaultStepTimeout is how long a session waits for its shell to be ready for a command
+const DefaultStepTimeout = 30 * time.Second
+
+// DispatchConfig controls when each startup command is typed into a session.
+// A command is sent once the output since the previous one ends in a prompt, or has gone quiet.
+type DispatchConfig struct {
+	// PromptPatterns are regular expressions matched against the text left of the cursor;
+	// empty uses DefaultPromptPatterns
+	PromptPatterns []string `json:"prompt_patterns,omitempty"`
+	// QuietPeriod sends the next command once output has stopped for this long;
+	// zero uses DefaultQuietPeriod and a negative value waits for a prompt only
+	QuietPeriod time.Duration `json:"quiet_period,omitempty"`
+	// StepTimeout fails the session when its shell is not ready for a command within this long;
+	// zero uses DefaultStepTimeout
+	StepTimeout time.Duration `json:"step_timeout,omitempty"`
+}
+
+// dispatcher is a DispatchConfig with its defaults applied and its patterns compiled
+type dispatcher struct {
+	prompts []*regexp.Regexp
+	quiet   time.Duration
+	timeout time.Duration
+}
+
+// newDispatcher validates a dispatch configuration; nil uses the defaults
+func newDispatcher(config *DispatchConfig) (*dispatcher, error) {
+	if config == nil {
+		config = &DispatchConfig{}
+	}
+
+	patterns := config.PromptPatterns
+	if len(patterns) == 0 {
+		patterns = DefaultPromptPatterns
+	}
+
+	d := &dispatcher{quiet: config.QuietPeriod, timeout: config.StepTimeout}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt pattern %q: %w", pattern, err)
+		}
+		d.prompts = append(d.prompts, re)
+	}
+	if d.quiet == 0 {
+		d.quiet = DefaultQuietPeriod
+	}
+	if d.timeout <= 0 {
+		d.timeout = DefaultStepTimeout
+	}
+	return d, nil
+}
+
+// matchesPrompt reports whether the text left of the cursor is a prompt
+func (d *dispatcher) matchesPrompt(line string) bool {
+	for _, re := range d.prompts {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// StepTimeoutError is reported when a startup step could not complete in time
+type StepTimeoutError struct {
+	Step    int
+	Command string
+	Timeout time.Duration
+	// Waiting describes what the step was waiting for
+	Waiting string
+}
+
+// Error describes the step that timed out
+func (e *StepTimeoutError) Error() string {
+	return fmt.Sprintf("step %d (%s) timed out: %s within %s", e.Step, e.Command, e.Waiting, e.Timeout)
+}
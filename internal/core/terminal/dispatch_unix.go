@@ -0,0 +1,191 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Reasons a startup step gives up
+var (
+	errSessionEnded = errors.New("session ended")
+	errStepTimeout  = errors.New("step timed out")
+	// errSessionStopping keeps a stopped session from being restarted
+	errSessionStopping = errors.New("session is stopping")
+)
+
+// runSteps works through the session's startup steps: commands are typed once the shell is ready
+// for them, sends go out straight away and wait_for steps hold the rest back until the output
+// matches. The session is reported ready once every step is done, and fails when a step cannot
+// complete in time. start is where the run's output began, taken before its output was pumped
+// so the shell's first prompt counts.
+func (s *ptySession) runSteps(ctx context.Context, steps []*startupStep, d *dispatcher, start runOrigin) {
+	since, sentAt, mark := start.seq, start.at, start.mark
+	stepsDone := start.stepsDone
+	echoed := false
+
+	for i, step := range steps {
+		var err error
+		switch step.Type {
+		case StepRun:
+			if err = s.waitReady(ctx, d, since, sentAt, step.timeout(d)); errors.Is(err, errStepTimeout) {
+				err = &StepTimeoutError{Step: i + 1, Command: step.String(), Timeout: step.timeout(d), Waiting: "no prompt to send it at"}
+			}
+		case StepWaitFor:
+			if mark, err = s.waitFor(ctx, step.re, mark, echoed, step.timeout(d)); errors.Is(err, errStepTimeout) {
+				err = &StepTimeoutError{Step: i + 1, Command: step.String(), Timeout: step.timeout(d), Waiting: "the output never matched"}
+			}
+		case StepSleep:
+			select {
+			case <-time.After(step.Duration):
+			case <-ctx.Done():
+				err = errSessionEnded
+			}
+		case StepCheckpoint:
+			s.mu.Lock()
+			s.checkpoint = step.Text
+			s.mu.Unlock()
+			s.publish(Event{Type: EventCheckpoint, Checkpoint: step.Text})
+		}
+		if step.Type == StepWaitFor {
+			echoed = false
+		}
+		if errors.Is(err, errSessionEnded) {
+			return
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		if step.input == nil {
+			continue
+		}
+
+		// Only output that arrives after the input counts towards the next prompt or match
+		s.mu.RLock()
+		since, sentAt, mark = s.outputSeq, time.Now(), s.transcript.Offset()
+		s.mu.RUnlock()
+		echoed = step.Type == StepRun
+
+		if err := s.WriteInput(step.input); err != nil {
+			fmt.Printf("Warning: failed to send step %d (%s): %v\n", i+1, step, err)
+			return
+		}
+	}
+
+	// Without probes finishing startup is all it takes to be ready; with them watchHealth decides
+	s.mu.Lock()
+	probed := s.probed
+	if !probed {
+		s.health = HealthReady
+	}
+	s.mu.Unlock()
+	close(stepsDone)
+
+	if !probed {
+		s.publish(Event{Type: EventReady})
+	}
+}
+
+// waitFor blocks until the text written to the transcript after mark matches re, and returns the
+// offset just past the match so a later wait_for only sees newer output. When echoed is set the
+// first line is the shell echoing a typed command and is skipped. It fails once timeout has passed.
+func (s *ptySession) waitFor(ctx context.Context, re *regexp.Regexp, mark int64, echoed bool, timeout time.Duration) (int64, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.RLock()
+		text, start := s.transcript.Since(mark)
+		wake := s.outputWake
+		s.mu.RUnlock()
+
+		if echoed {
+			if i := bytes.IndexByte(text, '\n'); i >= 0 {
+				text, start = text[i+1:], start+int64(i+1)
+			} else {
+				text = nil
+			}
+		}
+
+		if loc := re.FindIndex(text); loc != nil {
+			return start + int64(loc[1]), nil
+		}
+
+		select {
+		case <-wake:
+		case <-deadline.C:
+			return mark, errStepTimeout
+		case <-ctx.Done():
+			return mark, errSessionEnded
+		}
+	}
+}
+
+// waitReady blocks until the output that arrived after since ends in a prompt, or output has
+// been quiet for the quiet period since sentAt. It fails once timeout has passed.
+func (s *ptySession) waitReady(ctx context.Context, d *dispatcher, since uint64, sentAt time.Time, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		s.mu.RLock()
+		fresh := s.outputSeq > since
+		line := s.screen.CursorLine()
+		lastOutput := s.outputAt
+		wake := s.outputWake
+		s.mu.RUnlock()
+
+		// A line an expect rule answers is a question, not the shell's prompt
+		if fresh && d.matchesPrompt(line) && matchExpect(s.expect, line) == nil {
+			return nil
+		}
+
+		// Without a quiet period only new output or the deadline wake us
+		var quiet *time.Timer
+		var quietC <-chan time.Time
+		if d.quiet > 0 {
+			if lastOutput.Before(sentAt) {
+				lastOutput = sentAt
+			}
+			wait := time.Until(lastOutput.Add(d.quiet))
+			if wait <= 0 {
+				return nil
+			}
+			quiet = time.NewTimer(wait)
+			quietC = quiet.C
+		}
+
+		var err error
+		select {
+		case <-wake:
+		case <-quietC:
+		case <-deadline.C:
+			err = errStepTimeout
+		case <-ctx.Done():
+			err = errSessionEnded
+		}
+		if quiet != nil {
+			quiet.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fail ends the session because a startup step could not complete, telling attached clients why
+func (s *ptySession) fail(err error) {
+	s.mu.Lock()
+	if s.failure == "" {
+		s.failure = err.Error()
+	}
+	s.emitLocked([]byte(fmt.Sprintf("\r\n[hama-shell] %v\r\n", err)))
+	s.mu.Unlock()
+
+	s.terminateRun(s.grace)
+}
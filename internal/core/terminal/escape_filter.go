@@ -0,0 +1,60 @@
+package terminal
+
+// Escape filter states
+const (
+	filterGround = iota
+	filterEscape
+	filterCSI
+	filterString
+	filterCharset
+)
+
+// EscapeFilter tells the text of terminal output from the escape sequences around it, such as
+// colors, cursor movement and window titles. It keeps its state across writes, as a sequence
+// may be split between them.
+type EscapeFilter struct {
+	state int
+}
+
+// Text feeds the next byte of output and reports whether it is text, printable or a control
+// character, rather than part of an escape sequence
+func (f *EscapeFilter) Text(b byte) bool {
+	switch f.state {
+	case filterGround:
+		if b == 0x1b {
+			f.state = filterEscape
+			return false
+		}
+		return true
+	case filterEscape:
+		switch b {
+		case '[':
+			f.state = filterCSI
+		case ']', 'P', 'X', '^', '_':
+			f.state = filterString
+		case '(', ')', '*', '+', '#', '%':
+			f.state = filterCharset
+		default:
+			f.state = filterGround
+		}
+	case filterCSI:
+		if b >= 0x40 && b <= 0x7e {
+			f.state = filterGround
+		}
+	case filterString:
+		// Strings end with BEL or ESC \; the backslash is dropped as an escape sequence
+		if b == 0x07 {
+			f.state = filterGround
+		} else if b == 0x1b {
+			f.state = filterEscape
+		}
+	case filterCharset:
+		f.state = filterGround
+	}
+	return false
+}
+
+// InEscape reports whether the bytes fed so far end inside an escape sequence
+func (f *EscapeFilter) InEscape() bool {
+	return f.state != filterGround
+}
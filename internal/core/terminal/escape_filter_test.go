@@ -0,0 +1,49 @@
+package terminal
+
+import "testing"
+
+func TestEscapeFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{name: "plain text", writes: []string{"hello\r\n"}, want: "hello\r\n"},
+		{name: "SGR", writes: []string{"\x1b[1;31mred\x1b[0m"}, want: "red"},
+		{name: "private CSI", writes: []string{"\x1b[?2004hprompt$ "}, want: "prompt$ "},
+		{name: "OSC ended by BEL", writes: []string{"\x1b]0;title\x07text"}, want: "text"},
+		{name: "OSC ended by ST", writes: []string{"\x1b]2;title\x1b\\text"}, want: "text"},
+		{name: "DCS", writes: []string{"\x1bPq#0;2;0;0;0\x1b\\after"}, want: "after"},
+		{name: "charset designation", writes: []string{"\x1b(Bascii"}, want: "ascii"},
+		{name: "two-byte escape", writes: []string{"a\x1b7b\x1b8c"}, want: "abc"},
+		{name: "sequence split across writes", writes: []string{"x\x1b", "[3", "1mred\x1b]0;t", "itle\x07!"}, want: "xred!"},
+		{name: "UTF-8 passes through", writes: []string{"\x1b[32m密码\x1b[0m: "}, want: "密码: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f EscapeFilter
+			var text []byte
+			for _, write := range tt.writes {
+				for _, b := range []byte(write) {
+					if f.Text(b) {
+						text = append(text, b)
+					}
+				}
+			}
+			if string(text) != tt.want {
+				t.Errorf("text = %q, want %q", text, tt.want)
+			}
+			if f.InEscape() {
+				t.Errorf("filter still inside an escape sequence")
+			}
+		})
+	}
+}
+
+func TestTranscriptDropsControlCharacters(t *testing.T) {
+	tr := newTranscript(64)
+	tr.Write([]byte("\x1b[1mready\x1b[0m\r\n\x07done\tok\b"))
+	if text, _ := tr.Since(0); string(text) != "ready\ndone\tok" {
+		t.Errorf("transcript = %q, want %q", text, "ready\ndone\tok")
+	}
+}
@@ -0,0 +1,130 @@
+package terminal
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType names a session lifecycle event
+type EventType string
+
+// Lifecycle events published by the terminal server
+const (
+	// EventStarted is published once a session's process is running
+	EventStarted EventType = "started"
+	// EventReady is published once a session's startup steps are done and, with probes, they first pass
+	EventReady EventType = "ready"
+	// EventResized is published when a session's PTY changes size
+	EventResized EventType = "resized"
+	// EventAttached is published when a client attaches to a session
+	EventAttached EventType = "attached"
+	// EventDetached is published when a client detaches from a session
+	EventDetached EventType = "detached"
+	// EventCheckpoint is published when a session's startup passes a checkpoint step
+	EventCheckpoint EventType = "checkpoint"
+	// EventHealth is published when a session's probes move it to ready or unhealthy
+	EventHealth EventType = "health"
+	// EventResponded is published when an expect rule answers a prompt
+	EventResponded EventType = "responded"
+	// EventRestarting is published when a session's process ended and its restart policy starts it again
+	EventRestarting EventType = "restarting"
+	// EventExited is published once a session has ended and its exit status is known
+	EventExited EventType = "exited"
+)
+
+// eventHistorySize is how many recent events the bus keeps for new subscribers
+const eventHistorySize = 256
+
+// eventQueueSize is how many events a subscriber may fall behind before it is dropped
+const eventQueueSize = 256
+
+// Event describes something that happened to a session
+type Event struct {
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+	Target    string    `json:"target,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	// Rows and Cols are set on resized events
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	// ClientID and ReadOnly are set on attached and detached events
+	ClientID string `json:"client_id,omitempty"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	// Rule is the pattern of the expect rule that answered, set on responded events
+	Rule string `json:"rule,omitempty"`
+	// Checkpoint is the label of the checkpoint passed, set on checkpoint events
+	Checkpoint string `json:"checkpoint,omitempty"`
+	// Health is the session's new state and Error the failing probe, set on health events
+	Health HealthState `json:"health,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	// Restarts is how often the session has been restarted and Delay the wait before this restart,
+	// set on restarting events
+	Restarts int           `json:"restarts,omitempty"`
+	Delay    time.Duration `json:"delay,omitempty"`
+	// Exit is set on exited events, and on restarting events to say how the process ended
+	Exit *ExitStatus `json:"exit,omitempty"`
+}
+
+// EventBus fans lifecycle events out to subscribers without ever blocking the publisher
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[int]chan Event
+	nextID      int
+	history     []Event
+}
+
+// NewEventBus creates an empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[int]chan Event),
+	}
+}
+
+// Publish sends an event to every subscriber. A subscriber that has fallen too far behind
+// is dropped and its channel closed rather than stalling the session that published.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for id, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the recent event history and a channel of the events published after it.
+// The channel is closed when unsubscribe is called or the subscriber falls behind.
+func (b *EventBus) Subscribe() (history []Event, events <-chan Event, unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan Event, eventQueueSize)
+	b.subscribers[id] = ch
+
+	history = append([]Event(nil), b.history...)
+	unsubscribe = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, exists := b.subscribers[id]; exists {
+			delete(b.subscribers, id)
+			close(ch)
+		}
+	}
+	return history, ch, unsubscribe
+}
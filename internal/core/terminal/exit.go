@@ -0,0 +1,146 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// EndReason explains why a session stopped running
+type EndReason string
+
+// Possible end reasons
+const (
+	// EndReasonExited means the process exited on its own
+	EndReasonExited EndReason = "exited"
+	// EndReasonSignaled means the process was terminated by a signal hama-shell did not send
+	EndReasonSignaled EndReason = "signaled"
+	// EndReasonKilled means the session was killed through the server
+	EndReasonKilled EndReason = "killed"
+	// EndReasonCancelled means the server shut down while the session was running
+	EndReasonCancelled EndReason = "cancelled"
+	// EndReasonFailed means a startup step could not complete and the session was stopped
+	EndReasonFailed EndReason = "failed"
+)
+
+// ExitStatus describes how a session ended
+type ExitStatus struct {
+	// Code is the exit code, or 128+signal number when the process was signaled, as shells report it
+	Code    int       `json:"code"`
+	Signal  string    `json:"signal,omitempty"`
+	Reason  EndReason `json:"reason"`
+	EndTime time.Time `json:"end_time"`
+	Error   string    `json:"error,omitempty"`
+	// Stop is the step of a graceful stop that ended the session, if it was stopped
+	Stop StopStep `json:"stop,omitempty"`
+}
+
+// ExitError is returned by Session.Wait when a session did not exit cleanly
+type ExitError struct {
+	SessionID string
+	Status    *ExitStatus
+}
+
+// Error describes how the session ended
+func (e *ExitError) Error() string {
+	switch {
+	case e.Status.Reason == EndReasonFailed:
+		return fmt.Sprintf("session %s failed: %s", e.SessionID, e.Status.Error)
+	case e.Status.Stop == StopStepKill:
+		return fmt.Sprintf("session %s killed with SIGKILL after its grace period", e.SessionID)
+	case e.Status.Signal != "":
+		return fmt.Sprintf("session %s %s by %s", e.SessionID, e.Status.Reason, e.Status.Signal)
+	case e.Status.Error != "":
+		return fmt.Sprintf("session %s %s: %s", e.SessionID, e.Status.Reason, e.Status.Error)
+	default:
+		return fmt.Sprintf("session %s %s with status %d", e.SessionID, e.Status.Reason, e.Status.Code)
+	}
+}
+
+// Failed returns true if the session ended with a non-zero status on its own or a startup step failed
+func (es *ExitStatus) Failed() bool {
+	if es.Reason == EndReasonFailed {
+		return true
+	}
+	return es.Code != 0 && (es.Reason == EndReasonExited || es.Reason == EndReasonSignaled)
+}
+
+// remoteExit is how a remote process ended, as its SSH session reported it
+type remoteExit interface {
+	ExitStatus() int
+	// Signal is the signal name without its SIG prefix, or empty if the process exited
+	Signal() string
+}
+
+// newExitStatus builds an ExitStatus from the error returned by a process's Wait
+func newExitStatus(reason EndReason, waitErr error) *ExitStatus {
+	status := &ExitStatus{
+		Reason:  reason,
+		EndTime: time.Now(),
+	}
+	if waitErr == nil {
+		return status
+	}
+
+	var remote remoteExit
+	if errors.As(waitErr, &remote) {
+		if name := remote.Signal(); name != "" {
+			status.Signal = "SIG" + name
+			status.Code = 128
+			if sig, ok := remoteSignals[name]; ok {
+				status.Code += int(sig)
+			}
+			if status.Reason == EndReasonExited {
+				status.Reason = EndReasonSignaled
+			}
+			return status
+		}
+		status.Code = remote.ExitStatus()
+		return status
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(waitErr, &exitErr) {
+		status.Code = -1
+		status.Error = waitErr.Error()
+		return status
+	}
+
+	waitStatus, ok := exitErr.Sys().(syscall.WaitStatus)
+	if ok && waitStatus.Signaled() {
+		status.Code = 128 + int(waitStatus.Signal())
+		status.Signal = signalName(waitStatus.Signal())
+		if status.Reason == EndReasonExited {
+			status.Reason = EndReasonSignaled
+		}
+		return status
+	}
+
+	status.Code = exitErr.ExitCode()
+	return status
+}
+
+// signalName returns the conventional SIGXXX name of a signal
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	}
+	return sig.String()
+}
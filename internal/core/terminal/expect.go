@@ -0,0 +1,97 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultMaxResponses is how many times an expect rule answers before it gives up,
+// so a rejected password is not retried until the account locks
+const DefaultMaxResponses = 3
+
+// ExpectRule answers an interactive prompt, such as a password or host-key question, when the
+// text left of the cursor matches its pattern. Exactly one response source is set.
+type ExpectRule struct {
+	// Pattern is a regular expression matched against the text left of the cursor
+	Pattern string `json:"pattern"`
+	// Response is typed as is
+	Response string `json:"response,omitempty"`
+	// ResponseEnv names a variable in the session's environment that holds the response
+	ResponseEnv string `json:"response_env,omitempty"`
+	// ResponseCommand is run with sh -c in the session's environment when the prompt appears;
+	// its output is the response, which suits secret stores and one-time codes
+	ResponseCommand string `json:"response_command,omitempty"`
+	// MaxResponses limits how often the rule answers; zero uses DefaultMaxResponses and a
+	// negative value never limits it
+	MaxResponses int `json:"max_responses,omitempty"`
+}
+
+// Secret reports whether the response comes from the environment or a command. Secret responses
+// are only typed while the terminal does not echo them, so they never reach output or logs.
+func (r ExpectRule) Secret() bool {
+	return r.ResponseEnv != "" || r.ResponseCommand != ""
+}
+
+// Source describes where the response comes from without revealing it
+func (r ExpectRule) Source() string {
+	switch {
+	case r.ResponseEnv != "":
+		return "$" + r.ResponseEnv
+	case r.ResponseCommand != "":
+		return "command"
+	default:
+		return "literal"
+	}
+}
+
+// expectRule is an ExpectRule with its pattern compiled and the count of its answers
+type expectRule struct {
+	ExpectRule
+	re        *regexp.Regexp
+	responses int
+}
+
+// compileExpectRules validates a session's expect rules
+func compileExpectRules(rules []ExpectRule) ([]*expectRule, error) {
+	compiled := make([]*expectRule, 0, len(rules))
+	for i, rule := range rules {
+		sources := 0
+		for _, source := range []string{rule.Response, rule.ResponseEnv, rule.ResponseCommand} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return nil, fmt.Errorf("expect rule %d needs exactly one of response, response_env or response_command", i+1)
+		}
+
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expect pattern %q: %w", rule.Pattern, err)
+		}
+		if rule.MaxResponses == 0 {
+			rule.MaxResponses = DefaultMaxResponses
+		}
+		compiled = append(compiled, &expectRule{ExpectRule: rule, re: re})
+	}
+	return compiled, nil
+}
+
+// freshExpectRules copies rules with their answer counts reset, for a restarted process
+func freshExpectRules(rules []*expectRule) []*expectRule {
+	fresh := make([]*expectRule, 0, len(rules))
+	for _, rule := range rules {
+		fresh = append(fresh, &expectRule{ExpectRule: rule.ExpectRule, re: rule.re})
+	}
+	return fresh
+}
+
+// matchExpect returns the first rule whose pattern matches the text left of the cursor
+func matchExpect(rules []*expectRule, line string) *expectRule {
+	for _, rule := range rules {
+		if rule.re.MatchString(line) {
+			return rule
+		}
+	}
+	return nil
+}
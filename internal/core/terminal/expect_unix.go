@@ -0,0 +1,149 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// responseCommandTimeout bounds how long a response command may take
+const responseCommandTimeout = 30 * time.Second
+
+// echoPollInterval is how often a held back secret response checks for echo turning off, which
+// produces no output to wake on
+const echoPollInterval = 50 * time.Millisecond
+
+// watchExpect answers prompts that match the expect rules until ctx, the current run's, ends.
+// A prompt is answered once; the rule can only fire again after the line has changed.
+func (s *ptySession) watchExpect(ctx context.Context, rules []*expectRule) {
+	answered := ""
+	for {
+		s.mu.RLock()
+		line := s.screen.CursorLine()
+		wake := s.outputWake
+		s.mu.RUnlock()
+
+		var recheck <-chan time.Time
+		if line != answered {
+			answered = ""
+			if rule := matchExpect(rules, line); rule != nil {
+				sent, err := s.respond(rule)
+				if err != nil {
+					s.fail(err)
+					return
+				}
+				if sent {
+					answered = line
+				} else {
+					recheck = time.After(echoPollInterval)
+				}
+			}
+		}
+
+		select {
+		case <-wake:
+		case <-recheck:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// respond types a rule's response into the session; the response itself is never logged. A secret
+// response is held back while the terminal echoes input, reported by sent being false.
+func (s *ptySession) respond(rule *expectRule) (sent bool, err error) {
+	if rule.MaxResponses > 0 && rule.responses >= rule.MaxResponses {
+		if rule.responses == rule.MaxResponses {
+			rule.responses++
+			s.mu.Lock()
+			s.emitLocked([]byte(fmt.Sprintf("\r\n[hama-shell] expect rule %q answered %d times, not answering again\r\n", rule.Pattern, rule.MaxResponses)))
+			s.mu.Unlock()
+		}
+		return true, nil
+	}
+
+	if rule.Secret() {
+		s.mu.RLock()
+		proc := s.proc
+		s.mu.RUnlock()
+
+		echo, err := proc.EchoEnabled()
+		if err != nil {
+			return false, fmt.Errorf("expect rule %q: failed to check terminal echo: %w", rule.Pattern, err)
+		}
+		if echo {
+			return false, nil
+		}
+	}
+
+	response, err := s.resolveResponse(rule)
+	if err != nil {
+		return false, err
+	}
+	if err := s.WriteInput([]byte(response + "\n")); err != nil {
+		return false, fmt.Errorf("expect rule %q: failed to send response: %w", rule.Pattern, err)
+	}
+	rule.responses++
+	s.publish(Event{Type: EventResponded, Rule: rule.Pattern})
+	return true, nil
+}
+
+// expectLogin answers an SSH login's password and keyboard-interactive prompts with the first
+// matching rule, counting the answers against the rule's limit like those typed into the shell
+type expectLogin struct {
+	session *ptySession
+	rules   []*expectRule
+}
+
+// Has reports whether a rule matches the prompt
+func (l expectLogin) Has(prompt string) bool {
+	return matchExpect(l.rules, prompt) != nil
+}
+
+// Answer resolves the matching rule's response
+func (l expectLogin) Answer(prompt string) (string, error) {
+	rule := matchExpect(l.rules, prompt)
+	if rule == nil {
+		return "", fmt.Errorf("no expect rule answers the login prompt %q", prompt)
+	}
+	if rule.MaxResponses > 0 && rule.responses >= rule.MaxResponses {
+		return "", fmt.Errorf("expect rule %q answered %d times, not answering again", rule.Pattern, rule.MaxResponses)
+	}
+	response, err := l.session.resolveResponse(rule)
+	if err != nil {
+		return "", err
+	}
+	rule.responses++
+	l.session.publish(Event{Type: EventResponded, Rule: rule.Pattern})
+	return response, nil
+}
+
+// resolveResponse looks up or runs a rule's response source
+func (s *ptySession) resolveResponse(rule *expectRule) (string, error) {
+	switch {
+	case rule.ResponseEnv != "":
+		for _, entry := range s.env {
+			if name, value, ok := strings.Cut(entry, "="); ok && name == rule.ResponseEnv {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("expect rule %q: environment variable %s is not set", rule.Pattern, rule.ResponseEnv)
+
+	case rule.ResponseCommand != "":
+		ctx, cancel := context.WithTimeout(s.ctx, responseCommandTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", rule.ResponseCommand)
+		cmd.Env = s.env
+		cmd.Dir = s.dir
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("expect rule %q: response command failed: %w", rule.Pattern, err)
+		}
+		return strings.TrimRight(string(output), "\r\n"), nil
+	}
+
+	return rule.Response, nil
+}
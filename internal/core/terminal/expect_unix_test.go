@@ -0,0 +1,66 @@
+package terminal
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretResponseWaitsForEchoOff(t *testing.T) {
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+
+	_, events, unsubscribe := ts.Events().Subscribe()
+	defer unsubscribe()
+
+	// The prompt shows while echo is still on, and echo only turns off a while later
+	script := `printf 'Password: '; sleep 1; touch "$ECHO_OFF"; stty -echo; read pw; stty echo; echo
+[ "$pw" = hunter2 ] && echo matched`
+	echoOff := t.TempDir() + "/echo-off"
+	session, err := ts.CreateSessionWithConfig(SessionConfig{
+		ID:     "secret",
+		Shell:  "/bin/sh",
+		Args:   []string{"-c", script},
+		Env:    []string{"PATH=/usr/bin:/bin", "TEST_SECRET=hunter2", "ECHO_OFF=" + echoOff},
+		Expect: []ExpectRule{{Pattern: `Password: $`, ResponseEnv: "TEST_SECRET"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+
+	timeout := time.After(10 * time.Second)
+	responded := false
+	for !responded {
+		select {
+		case event := <-events:
+			if event.Type != EventResponded {
+				continue
+			}
+			responded = true
+			if _, err := os.Stat(echoOff); err != nil {
+				t.Errorf("secret typed before echo was turned off")
+			}
+		case <-session.Done():
+			t.Fatalf("session ended without the secret typed: %s", session.GetScrollback())
+		case <-timeout:
+			t.Fatalf("secret not typed once echo was off")
+		}
+	}
+
+	select {
+	case <-session.Done():
+	case <-timeout:
+		t.Fatalf("session did not end after the secret was typed")
+	}
+	output := string(session.GetScrollback())
+	if !strings.Contains(output, "matched") {
+		t.Errorf("output = %q, want the script to have read the secret", output)
+	}
+	if strings.Contains(output, "hunter2") {
+		t.Errorf("output = %q, want no secret in it", output)
+	}
+	if status := session.GetExitStatus(); status == nil || status.Code != 0 {
+		t.Errorf("exit status = %+v, want code 0", status)
+	}
+}
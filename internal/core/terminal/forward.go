@@ -0,0 +1,253 @@
+package terminal
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ForwardType names which way a forward tunnels connections
+type ForwardType string
+
+// Forward types
+const (
+	// ForwardLocal listens locally and connects to Remote from the SSH server, like ssh -L
+	ForwardLocal ForwardType = "local"
+	// ForwardRemote listens on the SSH server and connects to Local from here, like ssh -R
+	ForwardRemote ForwardType = "remote"
+	// ForwardDynamic is a local SOCKS5 proxy whose connections leave from the SSH server, like ssh -D
+	ForwardDynamic ForwardType = "dynamic"
+)
+
+// forwardBindHost is where listeners bind when a forward names only a port, as OpenSSH does
+const forwardBindHost = "127.0.0.1"
+
+// ForwardAutoPort asks for any free local port, which the session's steps and probes can refer to
+// as ${forward.<name>.port}
+const ForwardAutoPort = "auto"
+
+// forwardVarPattern matches ${forward.<name>.port} and ${forward.<name>.host}
+var forwardVarPattern = regexp.MustCompile(`\$\{forward\.([^.}]+)\.(port|host)\}`)
+
+// Forward tunnels connections through a session's SSH connection
+type Forward struct {
+	Name string      `json:"name"`
+	Type ForwardType `json:"type"`
+	// Local is where a local or dynamic forward listens, [host:]port with ForwardAutoPort for any
+	// free port, or where a remote forward's connections go, host:port or a bare port on localhost
+	Local string `json:"local"`
+	// Remote is where a local forward's connections go from the SSH server, host:port, or where a
+	// remote forward listens on it, [host:]port
+	Remote string `json:"remote,omitempty"`
+}
+
+// ForwardInfo describes a session's forward and what it is doing
+type ForwardInfo struct {
+	Name   string      `json:"name"`
+	Type   ForwardType `json:"type"`
+	Listen string      `json:"listen"`
+	// Target is where connections go; empty for a dynamic forward, whose clients choose
+	Target string `json:"target,omitempty"`
+	// Connections is how many tunnelled connections are open
+	Connections int `json:"connections"`
+	// Error is why the last connection could not be tunnelled, cleared by the next success
+	Error string `json:"error,omitempty"`
+}
+
+// forwarder is a Forward with its addresses resolved and its listener, once open
+type forwarder struct {
+	Forward
+	listen string
+	target string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    int
+	lastErr  string
+}
+
+// newForwarders validates a session's forwards
+func newForwarders(forwards []Forward) ([]*forwarder, error) {
+	names := make(map[string]bool)
+	var compiled []*forwarder
+	for i, f := range forwards {
+		if f.Name == "" {
+			return nil, fmt.Errorf("forward %d has no name", i+1)
+		}
+		if names[f.Name] {
+			return nil, fmt.Errorf("forward %q is declared twice", f.Name)
+		}
+		names[f.Name] = true
+
+		fw := &forwarder{Forward: f}
+		if fw.Type == "" {
+			fw.Type = ForwardLocal
+		}
+
+		var err error
+		switch fw.Type {
+		case ForwardLocal:
+			if fw.listen, err = listenAddress(f.Local); err == nil {
+				fw.target, err = targetAddress(f.Remote, false)
+			}
+		case ForwardRemote:
+			if strings.HasSuffix(f.Remote, ForwardAutoPort) {
+				err = fmt.Errorf("a remote forward cannot listen on an %s port", ForwardAutoPort)
+			} else if fw.listen, err = listenAddress(f.Remote); err == nil {
+				fw.target, err = targetAddress(f.Local, true)
+			}
+		case ForwardDynamic:
+			fw.listen, err = listenAddress(f.Local)
+		default:
+			err = fmt.Errorf("unknown type %q", f.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("forward %q: %w", f.Name, err)
+		}
+		for _, other := range compiled {
+			if port := fw.localPort(); port != 0 && port == other.localPort() {
+				return nil, fmt.Errorf("forwards %q and %q both listen on local port %d", other.Name, f.Name, port)
+			}
+		}
+		compiled = append(compiled, fw)
+	}
+	return compiled, nil
+}
+
+// listenAddress resolves [host:]port, binding a bare port to the loopback address;
+// an auto port becomes port 0 until the listener is open
+func listenAddress(address string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("missing listen port")
+	}
+	if !strings.Contains(address, ":") {
+		address = net.JoinHostPort(forwardBindHost, address)
+	}
+	if host, port, err := net.SplitHostPort(address); err == nil && port == ForwardAutoPort {
+		return net.JoinHostPort(host, "0"), nil
+	}
+	if _, err := addressPort(address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// targetAddress checks host:port; a bare port means localhost where bare is allowed
+func targetAddress(address string, bare bool) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("missing target address")
+	}
+	if bare && !strings.Contains(address, ":") {
+		address = net.JoinHostPort("localhost", address)
+	}
+	if _, err := addressPort(address); err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+// addressPort returns the port of host:port
+func addressPort(address string) (int, error) {
+	_, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port in %q", address)
+	}
+	return port, nil
+}
+
+// localPort is the local port a forward listens on, or 0 for a remote forward and an
+// auto port not yet chosen
+func (f *forwarder) localPort() int {
+	if f.Type == ForwardRemote {
+		return 0
+	}
+	port, _ := addressPort(f.listen)
+	return port
+}
+
+// info reports the forward's addresses and connections
+func (f *forwarder) info() ForwardInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return ForwardInfo{
+		Name:        f.Name,
+		Type:        f.Type,
+		Listen:      f.listen,
+		Target:      f.target,
+		Connections: f.conns,
+		Error:       f.lastErr,
+	}
+}
+
+// String describes the forward, e.g. "db local 127.0.0.1:5432 → db.internal:5432"
+func (f ForwardInfo) String() string {
+	if f.Target == "" {
+		return fmt.Sprintf("%s %s %s (SOCKS)", f.Name, f.Type, f.Listen)
+	}
+	return fmt.Sprintf("%s %s %s → %s", f.Name, f.Type, f.Listen, f.Target)
+}
+
+// expandForwardVars replaces ${forward.<name>.port} and ${forward.<name>.host} in text with where
+// the named forward listens; the forwards' listeners must be open so auto ports are known
+func expandForwardVars(text string, forwards []*forwarder) (string, error) {
+	var err error
+	expanded := forwardVarPattern.ReplaceAllStringFunc(text, func(variable string) string {
+		match := forwardVarPattern.FindStringSubmatch(variable)
+		for _, f := range forwards {
+			if f.Name != match[1] {
+				continue
+			}
+			host, port, _ := net.SplitHostPort(f.listen)
+			if match[2] == "host" {
+				return host
+			}
+			return port
+		}
+		if err == nil {
+			err = fmt.Errorf("%s refers to an unknown forward", variable)
+		}
+		return variable
+	})
+	return expanded, err
+}
+
+// applyForwardVars fills in forward variables in the session's run and send steps and its
+// tcp and command probes
+func (s *ptySession) applyForwardVars() error {
+	for i, step := range s.steps {
+		if step.Type != StepRun && step.Type != StepSend {
+			continue
+		}
+		text, err := expandForwardVars(step.Text, s.forwards)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		step.input = append([]byte(text), step.input[len(step.Text):]...)
+		step.Text = text
+	}
+
+	if s.checker == nil {
+		return nil
+	}
+	for _, p := range s.checker.probes {
+		if p.Type == ProbeOutput {
+			continue
+		}
+		target, err := expandForwardVars(p.Target, s.forwards)
+		if err != nil {
+			return fmt.Errorf("probe %s: %w", p.Probe, err)
+		}
+		if p.address, err = expandForwardVars(p.address, s.forwards); err != nil {
+			return err
+		}
+		p.Target = target
+	}
+	return nil
+}
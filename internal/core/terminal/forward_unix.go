@@ -0,0 +1,296 @@
+package terminal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SOCKS5 reply codes (RFC 1928)
+const (
+	socksSucceeded          = 0x00
+	socksHostUnreachable    = 0x04
+	socksCommandUnsupported = 0x07
+	socksAddressUnsupported = 0x08
+)
+
+// openForwards reserves the local ports of a new session's forwards before it starts, naming the
+// session that already forwards a port. The caller must hold ts.mu.
+func (ts *terminalServer) openForwards(sessionID string, forwards []*forwarder) error {
+	for _, f := range forwards {
+		if f.Type == ForwardRemote {
+			continue
+		}
+
+		// An auto port is 0 until the listener picks one, so no session can hold it
+		port := f.localPort()
+		if port != 0 {
+			if holder, held := ts.forwardHolderLocked(sessionID, port); holder != nil {
+				closeForwards(forwards)
+				return fmt.Errorf("local port %d of forward %q is already forwarded by session %s (%s, forward %q)",
+					port, f.Name, holder.id, holder.target, held.Name)
+			}
+		}
+
+		listener, err := net.Listen("tcp", f.listen)
+		if err != nil {
+			closeForwards(forwards)
+			if errors.Is(err, syscall.EADDRINUSE) {
+				return fmt.Errorf("local port %d of forward %q is already in use by another program", port, f.Name)
+			}
+			return fmt.Errorf("forward %q: failed to listen on %s: %w", f.Name, f.listen, err)
+		}
+		f.mu.Lock()
+		f.listener = listener
+		f.listen = listener.Addr().String()
+		f.mu.Unlock()
+	}
+	return nil
+}
+
+// forwardHolderLocked finds the running or starting session, other than sessionID, with a forward
+// on the local port. The caller must hold ts.mu.
+func (ts *terminalServer) forwardHolderLocked(sessionID string, port int) (*ptySession, *forwarder) {
+	for _, sessions := range []map[string]*ptySession{ts.sessions, ts.starting} {
+		for id, session := range sessions {
+			if id == sessionID || session.GetExitStatus() != nil {
+				continue
+			}
+			for _, f := range session.forwards {
+				if f.localPort() == port {
+					return session, f
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// closeForwards closes the forwards' listeners; tunnelled connections stay open until either side closes
+func closeForwards(forwards []*forwarder) {
+	for _, f := range forwards {
+		f.mu.Lock()
+		if f.listener != nil {
+			f.listener.Close()
+			f.listener = nil
+		}
+		f.mu.Unlock()
+	}
+}
+
+// serveLocalForwards accepts connections on the session's local and dynamic forwards for as long as it lives
+func (s *ptySession) serveLocalForwards() {
+	for _, f := range s.forwards {
+		f.mu.Lock()
+		listener := f.listener
+		f.mu.Unlock()
+		if listener != nil {
+			go s.serveForward(f, listener)
+		}
+	}
+}
+
+// listenRemote opens the remote forwards of a freshly connected SSH process; they close with its connection
+func (s *ptySession) listenRemote(p *sshProcess) error {
+	for _, f := range s.forwards {
+		if f.Type != ForwardRemote {
+			continue
+		}
+		listener, err := p.client.Listen("tcp", f.listen)
+		if err != nil {
+			return fmt.Errorf("forward %q: failed to listen on %s on the remote host: %w", f.Name, f.listen, err)
+		}
+		go s.serveForward(f, listener)
+	}
+	return nil
+}
+
+// serveForward tunnels every connection the listener accepts until it is closed
+func (s *ptySession) serveForward(f *forwarder, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.tunnel(f, conn)
+	}
+}
+
+// tunnel connects conn to the forward's target, or for a dynamic forward the one its SOCKS
+// client asks for, and copies data both ways until either side closes
+func (s *ptySession) tunnel(f *forwarder, conn net.Conn) {
+	defer conn.Close()
+
+	target := f.target
+	if f.Type == ForwardDynamic {
+		var err error
+		if target, err = socksHandshake(conn); err != nil {
+			f.failed(fmt.Errorf("SOCKS request refused: %w", err))
+			return
+		}
+	}
+
+	upstream, err := s.dialForward(f, target)
+	if f.Type == ForwardDynamic {
+		code := byte(socksSucceeded)
+		if err != nil {
+			code = socksHostUnreachable
+		}
+		socksReply(conn, code)
+	}
+	if err != nil {
+		f.failed(fmt.Errorf("failed to connect to %s: %w", target, err))
+		return
+	}
+
+	f.opened()
+	defer f.closed()
+	pipeConns(conn, upstream)
+}
+
+// dialForward connects to target from the SSH server, or from here for a remote forward
+func (s *ptySession) dialForward(f *forwarder, target string) (net.Conn, error) {
+	if f.Type == ForwardRemote {
+		return net.DialTimeout("tcp", target, DefaultSSHConnectTimeout)
+	}
+
+	client := s.sshClient()
+	if client == nil {
+		return nil, errors.New("the ssh connection is down")
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, DefaultSSHConnectTimeout)
+	defer cancel()
+	return client.DialContext(ctx, "tcp", target)
+}
+
+// sshClient returns the connection of the session's current SSH process, or nil while there is none
+func (s *ptySession) sshClient() *ssh.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.proc.(*sshProcess)
+	if !ok || s.restarting {
+		return nil
+	}
+	return p.client
+}
+
+// opened counts a tunnelled connection
+func (f *forwarder) opened() {
+	f.mu.Lock()
+	f.conns++
+	f.lastErr = ""
+	f.mu.Unlock()
+}
+
+// closed stops counting a tunnelled connection
+func (f *forwarder) closed() {
+	f.mu.Lock()
+	f.conns--
+	f.mu.Unlock()
+}
+
+// failed records why a connection could not be tunnelled
+func (f *forwarder) failed(err error) {
+	f.mu.Lock()
+	f.lastErr = err.Error()
+	f.mu.Unlock()
+}
+
+// pipeConns copies between a and b until either side closes, then closes both
+func pipeConns(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
+
+// socksHandshake reads a SOCKS5 greeting and CONNECT request without authentication and
+// returns the address asked for
+func socksHandshake(conn net.Conn) (string, error) {
+	buf := make([]byte, 256)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", err
+	}
+	if buf[0] != 5 {
+		return "", fmt.Errorf("unsupported SOCKS version %d", buf[0])
+	}
+	methods := buf[:buf[1]]
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	noAuth := false
+	for _, method := range methods {
+		noAuth = noAuth || method == 0
+	}
+	if !noAuth {
+		_, _ = conn.Write([]byte{5, 0xff})
+		return "", errors.New("client requires authentication")
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	// Request: version, command, reserved, address type
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return "", err
+	}
+	if buf[1] != 1 {
+		socksReply(conn, socksCommandUnsupported)
+		return "", fmt.Errorf("unsupported SOCKS command %d", buf[1])
+	}
+
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:net.IPv4len]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:net.IPv4len]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return "", err
+		}
+		name := buf[:buf[0]]
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	case 4:
+		if _, err := io.ReadFull(conn, buf[:net.IPv6len]); err != nil {
+			return "", err
+		}
+		host = net.IP(buf[:net.IPv6len]).String()
+	default:
+		socksReply(conn, socksAddressUnsupported)
+		return "", fmt.Errorf("unsupported SOCKS address type %d", buf[3])
+	}
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(buf[:2])
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// socksReply answers a SOCKS5 request; the bound address is left empty, which clients ignore
+func socksReply(conn net.Conn, code byte) {
+	_, _ = conn.Write([]byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0})
+}
@@ -0,0 +1,260 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer starts a TCP server on a free local port that echoes what it reads
+func newEchoServer(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener
+}
+
+// freePort returns a local port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// forward starts a session on ts that logs into server and keeps its forwards open
+func (c *sshTestClient) forward(t *testing.T, ts Server, server *testSSHServer, id string, forwards []Forward, commands ...string) (Session, error) {
+	t.Helper()
+	config := c.config(server, "deploy", map[string]string{"StrictHostKeyChecking": "no"})
+	return ts.CreateSessionWithConfig(SessionConfig{
+		ID:       id,
+		Env:      []string{"HOME=" + c.home, "PATH=/usr/bin:/bin"},
+		Commands: commands,
+		Dispatch: &DispatchConfig{QuietPeriod: -1, StepTimeout: 5 * time.Second},
+		SSH:      &config,
+		Forwards: forwards,
+	})
+}
+
+// forwardInfo returns what the session reports about its forward
+func forwardInfo(t *testing.T, session Session, name string) ForwardInfo {
+	t.Helper()
+	forwards, _ := session.GetInfo()["forwards"].([]ForwardInfo)
+	for _, f := range forwards {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("session has no forward %q in %v", name, forwards)
+	return ForwardInfo{}
+}
+
+// roundTrip writes a line to conn and checks it comes back
+func roundTrip(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "ping\n" {
+		t.Fatalf("read %q (%v), want the line echoed", reply, err)
+	}
+}
+
+func TestDynamicForwardSOCKS(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+	echo := newEchoServer(t)
+	echoAddr := echo.Addr().(*net.TCPAddr)
+
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+	session, err := client.forward(t, ts, server, "socks", []Forward{{Name: "proxy", Type: ForwardDynamic, Local: ForwardAutoPort}})
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+	listen := forwardInfo(t, session, "proxy").Listen
+
+	tests := []struct {
+		name      string
+		greeting  []byte
+		request   []byte
+		wantReply []byte
+		wantErr   string
+	}{
+		{
+			name:      "connect",
+			greeting:  []byte{5, 1, 0},
+			request:   append([]byte{5, 1, 0, 1}, binary.BigEndian.AppendUint16(echoAddr.IP.To4(), uint16(echoAddr.Port))...),
+			wantReply: []byte{5, 0},
+		},
+		{
+			name:     "auth method refused",
+			greeting: []byte{5, 1, 2},
+			wantErr:  "client requires authentication",
+		},
+		{
+			name:      "bind refused",
+			greeting:  []byte{5, 1, 0},
+			request:   append([]byte{5, 2, 0, 1}, binary.BigEndian.AppendUint16(echoAddr.IP.To4(), uint16(echoAddr.Port))...),
+			wantReply: []byte{5, socksCommandUnsupported},
+			wantErr:   "unsupported SOCKS command 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", listen)
+			if err != nil {
+				t.Fatalf("failed to connect to the proxy: %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			if _, err := conn.Write(tt.greeting); err != nil {
+				t.Fatalf("failed to greet: %v", err)
+			}
+			method := make([]byte, 2)
+			if _, err := io.ReadFull(conn, method); err != nil {
+				t.Fatalf("failed to read the chosen method: %v", err)
+			}
+			if tt.request == nil {
+				if !bytes.Equal(method, []byte{5, 0xff}) {
+					t.Fatalf("method reply = %v, want no acceptable method", method)
+				}
+			} else {
+				if !bytes.Equal(method, []byte{5, 0}) {
+					t.Fatalf("method reply = %v, want no authentication", method)
+				}
+				if _, err := conn.Write(tt.request); err != nil {
+					t.Fatalf("failed to send the request: %v", err)
+				}
+				reply := make([]byte, 10)
+				if _, err := io.ReadFull(conn, reply); err != nil {
+					t.Fatalf("failed to read the reply: %v", err)
+				}
+				if !bytes.Equal(reply[:2], tt.wantReply) {
+					t.Fatalf("reply = %v, want it to start with %v", reply, tt.wantReply)
+				}
+			}
+
+			if tt.wantErr == "" {
+				roundTrip(t, conn)
+				if _, tunnels, _ := server.seen(); !strings.Contains(strings.Join(tunnels, ","), echo.Addr().String()) {
+					t.Errorf("tunnels = %v, want one to %s", tunnels, echo.Addr())
+				}
+				return
+			}
+			// The proxy closes the connection and records why
+			if n, _ := conn.Read(make([]byte, 1)); n != 0 {
+				t.Errorf("proxy kept talking after refusing the request")
+			}
+			if info := forwardInfo(t, session, "proxy"); !strings.Contains(info.Error, tt.wantErr) {
+				t.Errorf("forward error = %q, want one containing %q", info.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestForwardLocalPortConflict(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+	echo := newEchoServer(t)
+	port := freePort(t)
+	forwards := []Forward{{Name: "db", Local: fmt.Sprint(port), Remote: echo.Addr().String()}}
+
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+	first, err := client.forward(t, ts, server, "first", forwards)
+	if err != nil {
+		t.Fatalf("first session: %v", err)
+	}
+
+	_, err = client.forward(t, ts, server, "second", []Forward{{Name: "cache", Local: fmt.Sprint(port), Remote: echo.Addr().String()}})
+	want := fmt.Sprintf(`local port %d of forward "cache" is already forwarded by session first`, port)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("second session error = %v, want one containing %q", err, want)
+	}
+
+	// The first session still owns the port and tunnels through it
+	conn, err := net.Dial("tcp", forwardInfo(t, first, "db").Listen)
+	if err != nil {
+		t.Fatalf("failed to connect to the forward: %v", err)
+	}
+	defer conn.Close()
+	roundTrip(t, conn)
+
+	// Another server's sessions do not know about the port, but find it taken all the same
+	other := NewTerminalServer()
+	defer other.Shutdown()
+	_, err = client.forward(t, other, server, "third", forwards)
+	want = fmt.Sprintf(`local port %d of forward "db" is already in use by another program`, port)
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("third session error = %v, want one containing %q", err, want)
+	}
+}
+
+func TestForwardAutoPortInSteps(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+	echo := newEchoServer(t)
+
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+	session, err := client.forward(t, ts, server, "auto",
+		[]Forward{{Name: "db", Local: ForwardAutoPort, Remote: echo.Addr().String()}},
+		"connect ${forward.db.host}:${forward.db.port}")
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+
+	listen := forwardInfo(t, session, "db").Listen
+	if _, port, _ := net.SplitHostPort(listen); port == "0" {
+		t.Fatalf("forward listens on %s, want a chosen port", listen)
+	}
+
+	// The step reaches the shell with the port that was picked
+	want := "connect " + listen
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, commands := server.seen()
+		if strings.Join(commands, ",") == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("commands = %v, want [%s]", commands, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("tcp", listen)
+	if err != nil {
+		t.Fatalf("failed to connect to the forward: %v", err)
+	}
+	defer conn.Close()
+	roundTrip(t, conn)
+}
@@ -0,0 +1,151 @@
+package terminal
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HealthState is how far a session has got towards being usable
+type HealthState string
+
+// Session health states
+const (
+	// HealthStarting means the startup steps are still running or the probes have not passed yet
+	HealthStarting HealthState = "starting"
+	// HealthReady means startup is done and every probe passed on its last run
+	HealthReady HealthState = "ready"
+	// HealthUnhealthy means a probe kept failing after the session was ready
+	HealthUnhealthy HealthState = "unhealthy"
+)
+
+// DefaultProbeInterval is how often a session's probes are re-run
+const DefaultProbeInterval = 10 * time.Second
+
+// DefaultProbeTimeout bounds a single TCP or command probe
+const DefaultProbeTimeout = 5 * time.Second
+
+// DefaultFailureThreshold is how many failed runs in a row make a ready session unhealthy
+const DefaultFailureThreshold = 3
+
+// startingProbeInterval is how often probes are retried while the session is starting,
+// so it turns ready soon after its tunnel or server is up
+const startingProbeInterval = time.Second
+
+// ProbeType names how a probe checks a session
+type ProbeType string
+
+// Probe types
+const (
+	// ProbeTCP passes when a TCP connection to its address succeeds
+	ProbeTCP ProbeType = "tcp"
+	// ProbeCommand passes when its command, run with sh -c in the session's environment, exits 0
+	ProbeCommand ProbeType = "command"
+	// ProbeOutput passes once the session's output has matched its pattern
+	ProbeOutput ProbeType = "output"
+)
+
+// Probe checks one thing a usable session provides
+type Probe struct {
+	Type ProbeType `json:"type"`
+	// Target is the address of a tcp probe (a bare port means localhost), the command of a
+	// command probe and the pattern of an output probe
+	Target string `json:"target"`
+}
+
+// String describes the probe for status and error messages
+func (p Probe) String() string {
+	switch p.Type {
+	case ProbeTCP:
+		return fmt.Sprintf("tcp %s", p.Target)
+	default:
+		return fmt.Sprintf("%s '%s'", p.Type, p.Target)
+	}
+}
+
+// HealthConfig declares how a session proves it is ready and stays healthy
+type HealthConfig struct {
+	Probes []Probe `json:"probes"`
+	// Interval is how often the probes are re-run; zero uses DefaultProbeInterval
+	Interval time.Duration `json:"interval,omitempty"`
+	// Timeout bounds each TCP or command probe; zero uses DefaultProbeTimeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// FailureThreshold is how many failed runs in a row make a ready session unhealthy;
+	// zero uses DefaultFailureThreshold
+	FailureThreshold int `json:"failure_threshold,omitempty"`
+}
+
+// healthChecker is a HealthConfig with its defaults applied and its probes prepared
+type healthChecker struct {
+	probes    []*probe
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+}
+
+// probe is a Probe with its address resolved or pattern compiled, and what it has seen so far
+type probe struct {
+	Probe
+	address string
+	re      *regexp.Regexp
+	mark    int64
+	matched bool
+}
+
+// newHealthChecker validates a health configuration; it returns nil when there is nothing to probe
+func newHealthChecker(config *HealthConfig) (*healthChecker, error) {
+	if config == nil || len(config.Probes) == 0 {
+		return nil, nil
+	}
+
+	h := &healthChecker{interval: config.Interval, timeout: config.Timeout, threshold: config.FailureThreshold}
+	for _, p := range config.Probes {
+		compiled := &probe{Probe: p}
+		switch p.Type {
+		case ProbeTCP:
+			compiled.address = p.Target
+			if !strings.Contains(p.Target, ":") {
+				compiled.address = net.JoinHostPort("localhost", p.Target)
+			}
+			if _, _, err := net.SplitHostPort(compiled.address); err != nil {
+				return nil, fmt.Errorf("invalid tcp probe %q: %w", p.Target, err)
+			}
+		case ProbeCommand:
+			if p.Target == "" {
+				return nil, fmt.Errorf("command probe has no command")
+			}
+		case ProbeOutput:
+			re, err := regexp.Compile(p.Target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid output probe %q: %w", p.Target, err)
+			}
+			compiled.re = re
+		default:
+			return nil, fmt.Errorf("unknown probe type %q", p.Type)
+		}
+		h.probes = append(h.probes, compiled)
+	}
+
+	if h.interval <= 0 {
+		h.interval = DefaultProbeInterval
+	}
+	if h.timeout <= 0 {
+		h.timeout = DefaultProbeTimeout
+	}
+	if h.threshold <= 0 {
+		h.threshold = DefaultFailureThreshold
+	}
+	return h, nil
+}
+
+// forRun copies the checker with fresh probe state for a process whose output starts at mark
+func (h *healthChecker) forRun(mark int64) *healthChecker {
+	run := *h
+	run.probes = make([]*probe, 0, len(h.probes))
+	for _, p := range h.probes {
+		run.probes = append(run.probes, &probe{Probe: p.Probe, address: p.address, re: p.re, mark: mark})
+	}
+	return &run
+}
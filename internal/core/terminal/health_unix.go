@@ -0,0 +1,133 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"time"
+)
+
+// watchHealth runs the probes once the startup steps are done and then every interval, moving
+// the session between starting, ready and unhealthy until ctx, the current run's, ends
+func (s *ptySession) watchHealth(ctx context.Context, h *healthChecker) {
+	s.mu.RLock()
+	stepsDone := s.stepsDone
+	s.mu.RUnlock()
+
+	select {
+	case <-stepsDone:
+	case <-ctx.Done():
+		return
+	}
+
+	failures := 0
+	for {
+		err := s.runProbes(ctx, h)
+
+		s.mu.RLock()
+		state := s.health
+		s.mu.RUnlock()
+
+		switch {
+		case err == nil:
+			failures = 0
+			s.setHealth(HealthReady, "")
+			if state == HealthStarting {
+				s.publish(Event{Type: EventReady})
+			}
+		case state == HealthStarting:
+			// Probes failing while starting just mean the session is not up yet; keep why for hs list
+			s.mu.Lock()
+			s.healthErr = err.Error()
+			s.mu.Unlock()
+		default:
+			failures++
+			if failures >= h.threshold {
+				s.setHealth(HealthUnhealthy, err.Error())
+			}
+		}
+
+		interval := h.interval
+		if state == HealthStarting && err != nil {
+			interval = min(interval, startingProbeInterval)
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runProbes runs every probe and returns the first failure
+func (s *ptySession) runProbes(ctx context.Context, h *healthChecker) error {
+	for _, p := range h.probes {
+		if err := s.checkProbe(ctx, p, h.timeout); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// checkProbe runs a single probe
+func (s *ptySession) checkProbe(ctx context.Context, p *probe, timeout time.Duration) error {
+	switch p.Type {
+	case ProbeTCP:
+		conn, err := net.DialTimeout("tcp", p.address, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case ProbeCommand:
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", p.Target)
+		cmd.Env = s.env
+		cmd.Dir = s.dir
+		if err := cmd.Run(); err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s", timeout)
+			}
+			return err
+		}
+		return nil
+
+	case ProbeOutput:
+		if p.matched {
+			return nil
+		}
+
+		s.mu.RLock()
+		text, start := s.transcript.Since(p.mark)
+		s.mu.RUnlock()
+
+		if p.re.Match(text) {
+			p.matched = true
+			return nil
+		}
+		// The unfinished last line is scanned again next time, in case the match spans it
+		if i := bytes.LastIndexByte(text, '\n'); i >= 0 {
+			p.mark = start + int64(i+1)
+		}
+		return errors.New("no matching output yet")
+	}
+	return nil
+}
+
+// setHealth records a change of the session's health and announces it
+func (s *ptySession) setHealth(state HealthState, reason string) {
+	s.mu.Lock()
+	changed := s.health != state
+	s.health = state
+	s.healthErr = reason
+	s.mu.Unlock()
+
+	if changed {
+		s.publish(Event{Type: EventHealth, Health: state, Error: reason})
+	}
+}
@@ -0,0 +1,69 @@
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestReadyWaitsForProbes checks a probed session is only announced ready once its probes pass,
+// so hs events agrees with hs list
+func TestReadyWaitsForProbes(t *testing.T) {
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+
+	_, events, unsubscribe := ts.Events().Subscribe()
+	defer unsubscribe()
+
+	up := filepath.Join(t.TempDir(), "up")
+	_, err := ts.CreateSessionWithConfig(SessionConfig{
+		ID:     "probed",
+		Shell:  "/bin/sh",
+		Args:   []string{"-c", "sleep 30"},
+		Health: &HealthConfig{Probes: []Probe{{Type: ProbeCommand, Target: "test -e " + up}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+
+	// The steps are done straight away but the probe keeps failing
+	quiet := time.After(1500 * time.Millisecond)
+waiting:
+	for {
+		select {
+		case event := <-events:
+			if event.Type == EventReady || event.Type == EventHealth {
+				t.Fatalf("%s event published before the probe passed", event.Type)
+			}
+		case <-quiet:
+			break waiting
+		}
+	}
+
+	if err := os.WriteFile(up, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var health bool
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			switch event.Type {
+			case EventHealth:
+				if event.Health != HealthReady {
+					t.Fatalf("health = %s, want %s", event.Health, HealthReady)
+				}
+				health = true
+			case EventReady:
+				if !health {
+					t.Fatalf("ready published before the session's health turned ready")
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no ready event after the probe passed")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseKeySequence converts a comma-separated key list such as "ctrl-p,ctrl-q" or "C-c,Enter"
+// into the bytes a terminal would send for it.
+// Each key is a single character, a control key written as ctrl-<char> or C-<char>,
+// a named key such as Enter, Up or F5, or any of those with an alt-, meta- or M- prefix.
+func ParseKeySequence(spec string) ([]byte, error) {
+	var result []byte
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("empty key in sequence %q", spec)
+		}
+
+		b, err := parseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, b...)
+	}
+	return result, nil
+}
+
+// namedKeys maps key names, in lower case, to the bytes an xterm sends for them
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"return":    "\r",
+	"tab":       "\t",
+	"btab":      "\x1b[Z",
+	"escape":    "\x1b",
+	"esc":       "\x1b",
+	"space":     " ",
+	"comma":     ",",
+	"bspace":    "\x7f",
+	"backspace": "\x7f",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"insert":    "\x1b[2~",
+	"ic":        "\x1b[2~",
+	"delete":    "\x1b[3~",
+	"dc":        "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"ppage":     "\x1b[5~",
+	"pgup":      "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+	"npage":     "\x1b[6~",
+	"pgdn":      "\x1b[6~",
+	"f1":        "\x1bOP",
+	"f2":        "\x1bOQ",
+	"f3":        "\x1bOR",
+	"f4":        "\x1bOS",
+	"f5":        "\x1b[15~",
+	"f6":        "\x1b[17~",
+	"f7":        "\x1b[18~",
+	"f8":        "\x1b[19~",
+	"f9":        "\x1b[20~",
+	"f10":       "\x1b[21~",
+	"f11":       "\x1b[23~",
+	"f12":       "\x1b[24~",
+}
+
+// parseKey converts a single key name into its bytes
+func parseKey(key string) ([]byte, error) {
+	lower := strings.ToLower(key)
+	for _, prefix := range []string{"ctrl-", "c-"} {
+		if strings.HasPrefix(lower, prefix) && len(key) == len(prefix)+1 {
+			return controlKey(lower[len(prefix)])
+		}
+	}
+
+	// Alt sends the key prefixed with escape
+	for _, prefix := range []string{"alt-", "meta-", "m-"} {
+		if strings.HasPrefix(lower, prefix) && len(key) > len(prefix) {
+			b, err := parseKey(key[len(prefix):])
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{0x1b}, b...), nil
+		}
+	}
+
+	if seq, ok := namedKeys[lower]; ok {
+		return []byte(seq), nil
+	}
+	if len(key) == 1 {
+		return []byte(key), nil
+	}
+	return nil, fmt.Errorf("unknown key %q", key)
+}
+
+// controlKey returns the control character produced by ctrl+c
+func controlKey(c byte) ([]byte, error) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return []byte{c - 'a' + 1}, nil
+	case c == '@' || c == ' ':
+		return []byte{0}, nil
+	case c == '[':
+		return []byte{0x1b}, nil
+	case c == '\\':
+		return []byte{0x1c}, nil
+	case c == ']':
+		return []byte{0x1d}, nil
+	case c == '^':
+		return []byte{0x1e}, nil
+	case c == '_':
+		return []byte{0x1f}, nil
+	}
+	return nil, fmt.Errorf("unsupported control key ctrl-%c", c)
+}
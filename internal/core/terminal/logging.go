@@ -0,0 +1,285 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for session output logs
+const (
+	DefaultLogMaxSize  = 10 * 1024 * 1024
+	DefaultLogMaxAge   = 24 * time.Hour
+	DefaultLogMaxFiles = 5
+	// DefaultLogMaxSessions is how many ended sessions' logs a target keeps
+	DefaultLogMaxSessions = 10
+)
+
+// logTimeFormat prefixes every line of a session log in UTC; fixed width so it can be stripped cheaply
+const logTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// logRotatedFormat timestamps rotated log files so they sort in the order they were written
+const logRotatedFormat = "20060102T150405.000"
+
+// LogConfig enables an on-disk transcript of a session's output
+type LogConfig struct {
+	// MaxSize rotates the log once it reaches this many bytes; zero uses DefaultLogMaxSize
+	MaxSize int64 `json:"max_size,omitempty"`
+	// MaxAge rotates the log once it has been open this long; zero uses DefaultLogMaxAge
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// MaxFiles is how many rotated logs are kept per session; zero uses DefaultLogMaxFiles
+	MaxFiles int `json:"max_files,omitempty"`
+	// MaxSessions is how many ended sessions of the target keep their logs, the most recently
+	// written first; zero uses DefaultLogMaxSessions
+	MaxSessions int `json:"max_sessions,omitempty"`
+}
+
+// LogDir returns the directory holding the logs of a target's sessions
+func LogDir(root, target string) string {
+	if target == "" {
+		target = "untargeted"
+	}
+	return filepath.Join(root, strings.ReplaceAll(target, string(filepath.Separator), "_"))
+}
+
+// LogFiles returns a session's log files under root, oldest first, with the live log last
+func LogFiles(root, sessionID string) ([]string, error) {
+	current, err := filepath.Glob(filepath.Join(root, "*", sessionID+".log"))
+	if err != nil {
+		return nil, err
+	}
+	if len(current) == 0 {
+		return nil, fmt.Errorf("no logs found for session %s", sessionID)
+	}
+
+	dir := filepath.Dir(current[0])
+	rotated, err := filepath.Glob(filepath.Join(dir, sessionID+".*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(rotated)
+
+	return append(rotated, current[0]), nil
+}
+
+// LogPrefixLen is the length of the timestamp, and the space after it, that starts every log line
+const LogPrefixLen = len(logTimeFormat) + 1
+
+// ParseLogPrefix reads the timestamp starting a line of a session log.
+// b must hold at least LogPrefixLen bytes; ok is false if it does not start with a timestamp.
+func ParseLogPrefix(b []byte) (ts time.Time, ok bool) {
+	if len(b) < LogPrefixLen || b[LogPrefixLen-1] != ' ' {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(logTimeFormat, string(b[:LogPrefixLen-1]))
+	return ts, err == nil
+}
+
+// sessionLog tees PTY output to a file, stamping each line with the time it started and
+// rotating the file by size and age
+type sessionLog struct {
+	mu        sync.Mutex
+	dir       string
+	sessionID string
+	config    LogConfig
+	file      *bufio.Writer
+	raw       *os.File
+	size      int64
+	opened    time.Time
+	lineStart bool
+}
+
+// openSessionLog creates dir, starts the session's live log in it and removes the logs of the
+// target's ended sessions beyond MaxSessions; running reports which other sessions are still running
+func openSessionLog(dir, sessionID string, config LogConfig, running func(id string) bool) (*sessionLog, error) {
+	if config.MaxSize == 0 {
+		config.MaxSize = DefaultLogMaxSize
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = DefaultLogMaxAge
+	}
+	if config.MaxFiles == 0 {
+		config.MaxFiles = DefaultLogMaxFiles
+	}
+	if config.MaxSessions == 0 {
+		config.MaxSessions = DefaultLogMaxSessions
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	l := &sessionLog{
+		dir:       dir,
+		sessionID: sessionID,
+		config:    config,
+		lineStart: true,
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	pruneSessionLogs(dir, config.MaxSessions, func(id string) bool {
+		return id == sessionID || running(id)
+	})
+	return l, nil
+}
+
+// pruneSessionLogs removes the log files of all but the keep most recently written ended
+// sessions in dir; sessions that live reports on are left alone
+func pruneSessionLogs(dir string, keep int, live func(id string) bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	files := make(map[string][]string)
+	written := make(map[string]time.Time)
+	for _, entry := range entries {
+		id, ok := logSessionID(entry.Name())
+		if !ok || entry.IsDir() || live(id) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[id] = append(files[id], filepath.Join(dir, entry.Name()))
+		if info.ModTime().After(written[id]) {
+			written[id] = info.ModTime()
+		}
+	}
+	if len(files) <= keep {
+		return
+	}
+
+	ended := make([]string, 0, len(files))
+	for id := range files {
+		ended = append(ended, id)
+	}
+	sort.Slice(ended, func(i, j int) bool { return written[ended[i]].After(written[ended[j]]) })
+	for _, id := range ended[keep:] {
+		for _, path := range files[id] {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// logSessionID returns the session a live or rotated log file belongs to
+func logSessionID(name string) (string, bool) {
+	name, ok := strings.CutSuffix(name, ".log")
+	if !ok || name == "" {
+		return "", false
+	}
+	// A rotated log is <id>.<timestamp>.log
+	if i := len(name) - len(logRotatedFormat) - 1; i > 0 && name[i] == '.' {
+		if _, err := time.Parse(logRotatedFormat, name[i+1:]); err == nil {
+			return name[:i], true
+		}
+	}
+	return name, true
+}
+
+// Write appends PTY output to the log
+func (l *sessionLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for rest := p; len(rest) > 0; {
+		if l.lineStart {
+			// Only rotate between lines so every file starts with a timestamp
+			if l.size >= l.config.MaxSize || now.Sub(l.opened) >= l.config.MaxAge {
+				if err := l.rotate(); err != nil {
+					return 0, err
+				}
+			}
+			n, _ := l.file.WriteString(now.UTC().Format(logTimeFormat) + " ")
+			l.size += int64(n)
+			l.lineStart = false
+		}
+
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+			l.lineStart = true
+		}
+		n, err := l.file.Write(line)
+		l.size += int64(n)
+		if err != nil {
+			return 0, err
+		}
+		rest = rest[len(line):]
+	}
+
+	// Flush per chunk so "hs logs -f" sees output as it happens
+	if err := l.file.Flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close flushes and closes the live log
+func (l *sessionLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.raw == nil {
+		return nil
+	}
+	if !l.lineStart {
+		_, _ = l.file.WriteString("\n")
+	}
+	_ = l.file.Flush()
+	err := l.raw.Close()
+	l.raw = nil
+	return err
+}
+
+// open starts a new live log file
+func (l *sessionLog) open() error {
+	path := filepath.Join(l.dir, l.sessionID+".log")
+	raw, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open session log: %w", err)
+	}
+
+	info, err := raw.Stat()
+	if err != nil {
+		raw.Close()
+		return fmt.Errorf("failed to open session log: %w", err)
+	}
+
+	l.raw = raw
+	l.file = bufio.NewWriter(raw)
+	l.size = info.Size()
+	l.opened = time.Now()
+	return nil
+}
+
+// rotate moves the live log aside under a timestamped name, starts a new one and
+// removes the oldest rotated logs beyond MaxFiles
+func (l *sessionLog) rotate() error {
+	_ = l.file.Flush()
+	_ = l.raw.Close()
+
+	current := filepath.Join(l.dir, l.sessionID+".log")
+	rotated := filepath.Join(l.dir, fmt.Sprintf("%s.%s.log", l.sessionID, time.Now().UTC().Format(logRotatedFormat)))
+	if err := os.Rename(current, rotated); err != nil {
+		return fmt.Errorf("failed to rotate session log: %w", err)
+	}
+
+	if old, err := filepath.Glob(filepath.Join(l.dir, l.sessionID+".*.log")); err == nil && len(old) > l.config.MaxFiles {
+		sort.Strings(old)
+		for _, path := range old[:len(old)-l.config.MaxFiles] {
+			_ = os.Remove(path)
+		}
+	}
+
+	return l.open()
+}
@@ -0,0 +1,132 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logNames lists the log files in dir
+func logNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSessionLogRotation(t *testing.T) {
+	root := t.TempDir()
+	dir := LogDir(root, "proj.svc.dev")
+	log, err := openSessionLog(dir, "s1", LogConfig{MaxSize: 64, MaxFiles: 2}, func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("openSessionLog: %v", err)
+	}
+
+	// Every line fills a file, so each one after the first rotates; rotated names have a
+	// millisecond timestamp, so keep them apart
+	for i := 1; i <= 5; i++ {
+		if _, err := fmt.Fprintf(log, "line %d %s\n", i, strings.Repeat("x", 64)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	files, err := LogFiles(root, "s1")
+	if err != nil {
+		t.Fatalf("LogFiles: %v", err)
+	}
+	if len(files) != 3 || files[2] != filepath.Join(dir, "s1.log") {
+		t.Fatalf("log files = %v, want 2 rotated and the live log last", files)
+	}
+
+	// The oldest lines went with the rotated files beyond MaxFiles
+	for i, want := range []string{"line 3 ", "line 4 ", "line 5 "} {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", files[i], err)
+		}
+		ts, ok := ParseLogPrefix(data)
+		if !ok || time.Since(ts) > time.Minute {
+			t.Errorf("%s starts with %q, want a timestamp", files[i], data)
+		}
+		if line := string(data[LogPrefixLen:]); !strings.HasPrefix(line, want) || strings.Count(line, "\n") != 1 {
+			t.Errorf("%s holds %q, want the single line %q...", files[i], line, want)
+		}
+	}
+}
+
+func TestSessionLogPrunesEndedSessions(t *testing.T) {
+	dir := t.TempDir()
+	rotated := time.Now().UTC().Format(logRotatedFormat)
+
+	// Sessions a to e ended in that order, each leaving a live and a rotated log
+	written := time.Now().Add(-time.Hour)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		for _, name := range []string{id + ".log", id + "." + rotated + ".log"} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte("output\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, written, written); err != nil {
+				t.Fatal(err)
+			}
+		}
+		written = written.Add(time.Minute)
+	}
+	// Files that are no session's log are left alone
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is still running, so only the two newest of b to e are kept along with the new session
+	running := func(id string) bool { return id == "a" }
+	log, err := openSessionLog(dir, "new", LogConfig{MaxSessions: 2}, running)
+	if err != nil {
+		t.Fatalf("openSessionLog: %v", err)
+	}
+	defer log.Close()
+
+	want := []string{
+		"a." + rotated + ".log", "a.log",
+		"d." + rotated + ".log", "d.log",
+		"e." + rotated + ".log", "e.log",
+		"new.log", "notes.txt",
+	}
+	if got := logNames(t, dir); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestLogSessionID(t *testing.T) {
+	tests := []struct {
+		name   string
+		wantID string
+		wantOK bool
+	}{
+		{name: "proj-svc-1.log", wantID: "proj-svc-1", wantOK: true},
+		{name: "proj-svc-1.20260102T150405.123.log", wantID: "proj-svc-1", wantOK: true},
+		{name: "v1.2.log", wantID: "v1.2", wantOK: true},
+		{name: "notes.txt"},
+		{name: ".log"},
+	}
+	for _, tt := range tests {
+		id, ok := logSessionID(tt.name)
+		if id != tt.wantID || ok != tt.wantOK {
+			t.Errorf("logSessionID(%q) = %q, %v; want %q, %v", tt.name, id, ok, tt.wantID, tt.wantOK)
+		}
+	}
+}
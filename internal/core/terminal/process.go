@@ -0,0 +1,41 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errEchoUnknown is returned by processes that cannot tell whether their terminal echoes input
+var errEchoUnknown = errors.New("the terminal's echo setting cannot be read")
+
+// process is what a session runs on its terminal: a shell on a local PTY or a remote one over SSH.
+// Reads return the terminal's output and fail once the process is gone and its output drained.
+type process interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+
+	// Resize sets the terminal size
+	Resize(rows, cols uint16) error
+
+	// Wait blocks until the process ends; its error is understood by newExitStatus
+	Wait() error
+
+	// Signal sends sig to everything the process started
+	Signal(sig syscall.Signal)
+
+	// Close releases the terminal, ending pending reads
+	Close() error
+
+	// PID is the local process ID, or 0 for a remote process
+	PID() int
+
+	// Describe names what runs, for session listings
+	Describe() string
+
+	// EchoEnabled reports whether the terminal currently echoes input
+	EchoEnabled() (bool, error)
+
+	// File is the local PTY master, or nil for a remote process
+	File() *os.File
+}
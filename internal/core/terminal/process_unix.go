@@ -0,0 +1,194 @@
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// localProcess is a shell running on a local PTY
+type localProcess struct {
+	cmd        *exec.Cmd
+	ptyMaster  *os.File
+	mu         sync.Mutex
+	foreground int
+}
+
+// startLocalProcess starts shell on a new PTY of the given size; pty.Start runs it as a session
+// leader, so it leads its own process group
+func startLocalProcess(shell string, args, env []string, dir string, rows, cols uint16) (*localProcess, error) {
+	cmd := exec.Command(shell, args...)
+	if len(env) > 0 {
+		cmd.Env = env
+	}
+	cmd.Dir = dir
+
+	ptyMaster, err := pty.Start(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start PTY: %w", err)
+	}
+	if err := pty.Setsize(ptyMaster, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
+	}); err != nil {
+		fmt.Printf("Warning: failed to set PTY size: %v\n", err)
+	}
+
+	return &localProcess{cmd: cmd, ptyMaster: ptyMaster}, nil
+}
+
+// Read returns the PTY's output
+func (p *localProcess) Read(b []byte) (int, error) {
+	return p.ptyMaster.Read(b)
+}
+
+// Write types into the PTY
+func (p *localProcess) Write(b []byte) (int, error) {
+	return p.ptyMaster.Write(b)
+}
+
+// Resize sets the PTY size
+func (p *localProcess) Resize(rows, cols uint16) error {
+	return pty.Setsize(p.ptyMaster, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
+	})
+}
+
+// Wait blocks until the shell exits
+func (p *localProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+// Signal sends sig to every process group in the session: the shell's own, the PTY's
+// foreground group, which job-control shells give to the command they are running, and any
+// background jobs still attached to the session
+func (p *localProcess) Signal(sig syscall.Signal) {
+	if p.cmd.Process == nil {
+		return
+	}
+
+	for _, pgid := range p.processGroups() {
+		_ = syscall.Kill(-pgid, sig)
+	}
+}
+
+// Close closes the PTY master
+func (p *localProcess) Close() error {
+	return p.ptyMaster.Close()
+}
+
+// PID returns the shell's process ID
+func (p *localProcess) PID() int {
+	if p.cmd.Process != nil {
+		return p.cmd.Process.Pid
+	}
+	return 0
+}
+
+// Describe returns the shell's command line
+func (p *localProcess) Describe() string {
+	return strings.Join(p.cmd.Args, " ")
+}
+
+// File returns the PTY master
+func (p *localProcess) File() *os.File {
+	return p.ptyMaster
+}
+
+// EchoEnabled reports whether the PTY currently echoes input
+func (p *localProcess) EchoEnabled() (bool, error) {
+	rawConn, err := p.ptyMaster.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+
+	// Terminal settings read through the master are the slave's, which the program controls
+	var termios *unix.Termios
+	var ioctlErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		termios, ioctlErr = unix.IoctlGetTermios(int(fd), ioctlGetTermios)
+	}); err != nil {
+		return false, err
+	}
+	if ioctlErr != nil {
+		return false, os.NewSyscallError("ioctl", ioctlErr)
+	}
+	return termios.Lflag&unix.ECHO != 0, nil
+}
+
+// processGroups returns the process groups belonging to the session. The foreground group is
+// remembered because the PTY forgets it once the shell exits.
+func (p *localProcess) processGroups() []int {
+	sid := p.cmd.Process.Pid
+	groups := []int{sid}
+
+	// Use the raw descriptor rather than Fd, which would switch the master to blocking mode
+	if rawConn, err := p.ptyMaster.SyscallConn(); err == nil {
+		_ = rawConn.Control(func(fd uintptr) {
+			if fg, err := unix.IoctlGetInt(int(fd), unix.TIOCGPGRP); err == nil && fg > 0 {
+				p.mu.Lock()
+				p.foreground = fg
+				p.mu.Unlock()
+			}
+		})
+	}
+	p.mu.Lock()
+	if p.foreground > 0 && p.foreground != sid {
+		groups = append(groups, p.foreground)
+	}
+	p.mu.Unlock()
+
+	return append(groups, sessionProcessGroups(sid, groups)...)
+}
+
+// sessionProcessGroups lists the process groups of session sid not already in known.
+// It reads /proc and finds nothing on systems without it.
+func sessionProcessGroups(sid int, known []int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	for _, pgid := range known {
+		seen[pgid] = true
+	}
+
+	var groups []int
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// Fields after the parenthesised command name: state ppid pgrp session
+		end := bytes.LastIndexByte(stat, ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 4 {
+			continue
+		}
+		pgid, _ := strconv.Atoi(fields[2])
+		session, _ := strconv.Atoi(fields[3])
+		if session == sid && pgid > 0 && !seen[pgid] {
+			seen[pgid] = true
+			groups = append(groups, pgid)
+		}
+	}
+	return groups
+}
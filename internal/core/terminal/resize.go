@@ -0,0 +1,61 @@
+package terminal
+
+import "fmt"
+
+// ResizePolicy decides the PTY size of a session shared by several clients
+type ResizePolicy string
+
+// Supported resize policies
+const (
+	// ResizeSmallest fits the PTY into every attached client
+	ResizeSmallest ResizePolicy = "smallest"
+	// ResizeLargest uses the biggest attached client
+	ResizeLargest ResizePolicy = "largest"
+	// ResizeLatest follows the client that most recently typed or resized
+	ResizeLatest ResizePolicy = "latest"
+)
+
+// DefaultResizePolicy is used when neither the server nor the session sets one
+const DefaultResizePolicy = ResizeSmallest
+
+// ParseResizePolicy validates a policy name; an empty name yields an empty policy
+func ParseResizePolicy(name string) (ResizePolicy, error) {
+	switch policy := ResizePolicy(name); policy {
+	case "", ResizeSmallest, ResizeLargest, ResizeLatest:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown resize policy %q (use smallest, largest or latest)", name)
+	}
+}
+
+// computeSize applies the policy to the sizes reported by attached clients.
+// ok is false when no client has reported a size yet.
+func (p ResizePolicy) computeSize(clients map[string]*attachedClient) (rows, cols uint16, ok bool) {
+	var latest *attachedClient
+	for _, client := range clients {
+		if client.rows == 0 || client.cols == 0 {
+			continue
+		}
+
+		switch p {
+		case ResizeLargest:
+			rows, cols = max(rows, client.rows), max(cols, client.cols)
+		case ResizeLatest:
+			if latest == nil || client.lastActive.After(latest.lastActive) {
+				latest = client
+			}
+		default:
+			if !ok {
+				rows, cols = client.rows, client.cols
+			} else {
+				rows, cols = min(rows, client.rows), min(cols, client.cols)
+			}
+		}
+		ok = true
+	}
+
+	if latest != nil {
+		rows, cols = latest.rows, latest.cols
+	}
+	return rows, cols, ok
+}
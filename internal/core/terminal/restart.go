@@ -0,0 +1,91 @@
+package terminal
+
+import (
+	"fmt"
+	"time"
+)
+
+// RestartPolicy decides whether a session's process is started again once it ends on its own
+type RestartPolicy string
+
+// Restart policies
+const (
+	// RestartNever lets the session end with its process
+	RestartNever RestartPolicy = "never"
+	// RestartOnFailure restarts a process that exited with a non-zero status, was killed by a
+	// signal hama-shell did not send or failed a startup step
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartAlways restarts the process however it ended, unless the session was stopped
+	RestartAlways RestartPolicy = "always"
+)
+
+// DefaultRestartBackoff is the delay before the first restart
+const DefaultRestartBackoff = time.Second
+
+// DefaultRestartMaxBackoff caps the delay between restarts, which doubles with every attempt
+const DefaultRestartMaxBackoff = time.Minute
+
+// restartResetAfter is how long a process must run before its restart is no longer counted as
+// part of a crash loop, so the backoff and attempt count start over
+const restartResetAfter = time.Minute
+
+// RestartConfig controls how a session recovers when its process ends
+type RestartConfig struct {
+	Policy RestartPolicy `json:"policy"`
+	// MaxAttempts is how many restarts in a row are tried before giving up; zero never gives up
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Backoff is the delay before the first restart; zero uses DefaultRestartBackoff
+	Backoff time.Duration `json:"backoff,omitempty"`
+	// MaxBackoff caps the delay; zero uses DefaultRestartMaxBackoff
+	MaxBackoff time.Duration `json:"max_backoff,omitempty"`
+}
+
+// newRestartConfig validates a restart configuration and applies its defaults;
+// it returns nil when the session is never restarted
+func newRestartConfig(config *RestartConfig) (*RestartConfig, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch config.Policy {
+	case "", RestartNever:
+		return nil, nil
+	case RestartOnFailure, RestartAlways:
+	default:
+		return nil, fmt.Errorf("unknown restart policy %q", config.Policy)
+	}
+	if config.MaxAttempts < 0 || config.Backoff < 0 || config.MaxBackoff < 0 {
+		return nil, fmt.Errorf("restart max_attempts and backoff cannot be negative")
+	}
+
+	restart := *config
+	if restart.Backoff == 0 {
+		restart.Backoff = DefaultRestartBackoff
+	}
+	if restart.MaxBackoff == 0 {
+		restart.MaxBackoff = DefaultRestartMaxBackoff
+	}
+	restart.MaxBackoff = max(restart.MaxBackoff, restart.Backoff)
+	return &restart, nil
+}
+
+// applies reports whether a process that ended with status should be restarted
+func (c *RestartConfig) applies(status *ExitStatus) bool {
+	switch c.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return status.Failed()
+	default:
+		return false
+	}
+}
+
+// delay is how long to wait before the given restart attempt, counting from 1
+func (c *RestartConfig) delay(attempt int) time.Duration {
+	delay := c.Backoff
+	for i := 1; i < attempt && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.MaxBackoff)
+}
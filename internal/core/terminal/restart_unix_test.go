@@ -0,0 +1,59 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRestartingSessionIsRaceFree reads a session from other goroutines while its restart policy
+// keeps replacing its process; run it with -race
+func TestRestartingSessionIsRaceFree(t *testing.T) {
+	ts := NewTerminalServer()
+	defer ts.Shutdown()
+
+	_, events, unsubscribe := ts.Events().Subscribe()
+	defer unsubscribe()
+
+	session, err := ts.CreateSessionWithConfig(SessionConfig{
+		ID:      "restarting",
+		Shell:   "/bin/sh",
+		Args:    []string{"-c", "exit 3"},
+		Restart: &RestartConfig{Policy: RestartAlways, MaxAttempts: 3, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+
+	// Poll from a goroutine of its own, which nothing else synchronises with
+	go func() {
+		for {
+			select {
+			case <-session.Done():
+				return
+			default:
+				_ = session.GetPID()
+			}
+		}
+	}()
+
+	restarts := 0
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == EventRestarting {
+				restarts++
+			}
+		case <-session.Done():
+			if restarts == 0 {
+				t.Fatalf("session ended without restarting")
+			}
+			if status := session.GetExitStatus(); status == nil || status.Code != 3 {
+				t.Fatalf("exit status = %+v, want code 3", status)
+			}
+			return
+		case <-timeout:
+			t.Fatalf("session still running after %d restarts", restarts)
+		}
+	}
+}
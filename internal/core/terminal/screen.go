@@ -0,0 +1,650 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// Cell attribute flags
+const (
+	attrBold uint8 = 1 << iota
+	attrDim
+	attrItalic
+	attrUnderline
+	attrBlink
+	attrReverse
+	attrHidden
+	attrStrike
+)
+
+// Color kinds
+const (
+	colorDefault uint8 = iota
+	colorIndexed
+	colorRGB
+)
+
+// color is a cell's foreground or background color
+type color struct {
+	kind  uint8
+	value uint32
+}
+
+// cellAttr is the rendition of a cell
+type cellAttr struct {
+	fg, bg color
+	flags  uint8
+}
+
+// cell is one column of the screen. A wide character occupies a lead cell of width 2
+// followed by a continuation cell of width 0.
+type cell struct {
+	ch    rune
+	comb  string
+	width uint8
+	attr  cellAttr
+}
+
+// blankCell is an erased cell; erasing keeps the current background color
+func blankCell(attr cellAttr) cell {
+	return cell{width: 1, attr: cellAttr{bg: attr.bg}}
+}
+
+// savedCursor is the state kept by DECSC and the alternate screen
+type savedCursor struct {
+	x, y        int
+	attr        cellAttr
+	originMode  bool
+	wrapPending bool
+}
+
+// ScreenSnapshot is the rendered state of a screen at one moment
+type ScreenSnapshot struct {
+	Rows          int    `json:"rows"`
+	Cols          int    `json:"cols"`
+	CursorRow     int    `json:"cursor_row"`
+	CursorCol     int    `json:"cursor_col"`
+	CursorVisible bool   `json:"cursor_visible"`
+	AltScreen     bool   `json:"alt_screen"`
+	Title         string `json:"title,omitempty"`
+	// Content is the screen as plain text, or with SGR escape sequences when rendered as ANSI
+	Content string `json:"content"`
+}
+
+// Screen is a VT100/xterm screen emulator: it interprets terminal output and keeps the
+// resulting grid of characters. It is not safe for concurrent use.
+type Screen struct {
+	rows, cols int
+	primary    [][]cell
+	alternate  [][]cell
+	lines      [][]cell
+	altActive  bool
+
+	x, y        int
+	wrapPending bool
+	attr        cellAttr
+	saved       savedCursor
+	altSaved    savedCursor
+
+	top, bottom   int
+	autowrap      bool
+	originMode    bool
+	insertMode    bool
+	cursorVisible bool
+	tabs          []bool
+	title         string
+	lastChar      rune
+
+	parser screenParser
+}
+
+// NewScreen creates a blank screen of the given size
+func NewScreen(rows, cols int) *Screen {
+	if rows < 1 {
+		rows = 1
+	}
+	if cols < 1 {
+		cols = 1
+	}
+
+	s := &Screen{rows: rows, cols: cols}
+	s.reset()
+	return s
+}
+
+// Resize changes the screen size, keeping the cursor's line in view
+func (s *Screen) Resize(rows, cols int) {
+	if rows < 1 || cols < 1 || (rows == s.rows && cols == s.cols) {
+		return
+	}
+
+	// Scroll the primary screen up rather than lose the line the cursor is on
+	if s.y >= rows && !s.altActive {
+		shift := s.y - rows + 1
+		s.primary = append(s.primary[shift:], makeLines(shift, s.cols, cellAttr{})...)
+		s.y -= shift
+	}
+
+	s.primary = resizeLines(s.primary, rows, cols)
+	s.alternate = resizeLines(s.alternate, rows, cols)
+	if s.altActive {
+		s.lines = s.alternate
+	} else {
+		s.lines = s.primary
+	}
+
+	tabs := make([]bool, cols)
+	copy(tabs, s.tabs)
+	for i := len(s.tabs); i < cols; i++ {
+		tabs[i] = i%8 == 0
+	}
+	s.tabs = tabs
+
+	s.rows, s.cols = rows, cols
+	s.top, s.bottom = 0, rows-1
+	s.x = clamp(s.x, 0, cols-1)
+	s.y = clamp(s.y, 0, rows-1)
+	s.wrapPending = false
+}
+
+// Snapshot renders the screen as plain text, or with SGR colors and attributes when ansi is set
+func (s *Screen) Snapshot(ansi bool) *ScreenSnapshot {
+	snapshot := &ScreenSnapshot{
+		Rows:          s.rows,
+		Cols:          s.cols,
+		CursorRow:     s.y,
+		CursorCol:     s.x,
+		CursorVisible: s.cursorVisible,
+		AltScreen:     s.altActive,
+		Title:         s.title,
+	}
+
+	rendered := make([]string, len(s.lines))
+	for i, line := range s.lines {
+		if ansi {
+			rendered[i] = renderANSI(line)
+		} else {
+			rendered[i] = renderPlain(line)
+		}
+	}
+
+	// Blank lines below the last output carry no information
+	end := len(rendered)
+	for end > 0 && (rendered[end-1] == "" || rendered[end-1] == sgrReset) {
+		end--
+	}
+	snapshot.Content = strings.Join(rendered[:end], "\n")
+	if end > 0 {
+		snapshot.Content += "\n"
+	}
+	return snapshot
+}
+
+// CursorLine returns the text left of the cursor on its line, such as a shell prompt
+func (s *Screen) CursorLine() string {
+	end := s.x
+	if s.wrapPending {
+		end++
+	}
+
+	var b strings.Builder
+	for _, c := range s.lines[s.y][:end] {
+		if c.width == 0 {
+			continue
+		}
+		if c.ch == 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(c.ch)
+		b.WriteString(c.comb)
+	}
+	return b.String()
+}
+
+// reset returns the screen to its power-on state (RIS)
+func (s *Screen) reset() {
+	s.primary = makeLines(s.rows, s.cols, cellAttr{})
+	s.alternate = makeLines(s.rows, s.cols, cellAttr{})
+	s.lines = s.primary
+	s.altActive = false
+	s.x, s.y = 0, 0
+	s.wrapPending = false
+	s.attr = cellAttr{}
+	s.saved = savedCursor{}
+	s.top, s.bottom = 0, s.rows-1
+	s.autowrap = true
+	s.originMode = false
+	s.insertMode = false
+	s.cursorVisible = true
+	s.tabs = make([]bool, s.cols)
+	for i := range s.tabs {
+		s.tabs[i] = i%8 == 0
+	}
+	s.title = ""
+}
+
+// print writes a character at the cursor, wrapping and handling wide and combining characters
+func (s *Screen) print(r rune) {
+	w := runeWidth(r)
+	if w == 0 {
+		s.combine(r)
+		return
+	}
+
+	if s.wrapPending {
+		s.x = 0
+		s.lineFeed()
+		s.wrapPending = false
+	}
+	if w == 2 && s.x == s.cols-1 {
+		// A wide character never straddles the right margin
+		if !s.autowrap {
+			return
+		}
+		s.setCell(s.y, s.x, blankCell(s.attr))
+		s.x = 0
+		s.lineFeed()
+	}
+	if w > s.cols {
+		return
+	}
+
+	if s.insertMode {
+		s.insertCells(w)
+	}
+	s.setCell(s.y, s.x, cell{ch: r, width: uint8(w), attr: s.attr})
+	if w == 2 {
+		s.setCell(s.y, s.x+1, cell{width: 0, attr: s.attr})
+	}
+	s.lastChar = r
+
+	if s.x+w >= s.cols {
+		s.x = s.cols - 1
+		s.wrapPending = s.autowrap
+	} else {
+		s.x += w
+	}
+}
+
+// combine attaches a zero-width character to the one before the cursor
+func (s *Screen) combine(r rune) {
+	x := s.x
+	if !s.wrapPending {
+		x--
+	}
+	if x < 0 {
+		return
+	}
+	line := s.lines[s.y]
+	if line[x].width == 0 && x > 0 {
+		x--
+	}
+	if line[x].ch != 0 {
+		line[x].comb += string(r)
+	}
+}
+
+// setCell writes a cell, blanking the other half of any wide character it overwrites
+func (s *Screen) setCell(y, x int, c cell) {
+	line := s.lines[y]
+	if line[x].width == 0 && x > 0 && line[x-1].width == 2 {
+		line[x-1] = blankCell(line[x-1].attr)
+	}
+	if line[x].width == 2 && x+1 < len(line) && c.width != 2 {
+		line[x+1] = blankCell(line[x+1].attr)
+	}
+	line[x] = c
+}
+
+// lineFeed moves the cursor down, scrolling the region when it is at the bottom margin
+func (s *Screen) lineFeed() {
+	if s.y == s.bottom {
+		s.scrollUp(1)
+	} else if s.y < s.rows-1 {
+		s.y++
+	}
+}
+
+// reverseIndex moves the cursor up, scrolling the region down when it is at the top margin
+func (s *Screen) reverseIndex() {
+	if s.y == s.top {
+		s.scrollDown(1)
+	} else if s.y > 0 {
+		s.y--
+	}
+}
+
+// scrollUp moves the lines of the scroll region up by n, blanking the bottom
+func (s *Screen) scrollUp(n int) {
+	s.scrollRegionUp(s.top, s.bottom, n)
+}
+
+// scrollDown moves the lines of the scroll region down by n, blanking the top
+func (s *Screen) scrollDown(n int) {
+	s.scrollRegionDown(s.top, s.bottom, n)
+}
+
+// scrollRegionUp moves lines top..bottom up by n
+func (s *Screen) scrollRegionUp(top, bottom, n int) {
+	n = clamp(n, 0, bottom-top+1)
+	copy(s.lines[top:bottom+1], s.lines[top+n:bottom+1])
+	for i := bottom - n + 1; i <= bottom; i++ {
+		s.lines[i] = makeLine(s.cols, s.attr)
+	}
+}
+
+// scrollRegionDown moves lines top..bottom down by n
+func (s *Screen) scrollRegionDown(top, bottom, n int) {
+	n = clamp(n, 0, bottom-top+1)
+	copy(s.lines[top+n:bottom+1], s.lines[top:bottom+1-n])
+	for i := top; i < top+n; i++ {
+		s.lines[i] = makeLine(s.cols, s.attr)
+	}
+}
+
+// moveTo places the cursor, honouring origin mode for the row
+func (s *Screen) moveTo(y, x int) {
+	if s.originMode {
+		y = clamp(y+s.top, s.top, s.bottom)
+	}
+	s.y = clamp(y, 0, s.rows-1)
+	s.x = clamp(x, 0, s.cols-1)
+	s.wrapPending = false
+}
+
+// moveVertical moves the cursor up (negative) or down, stopping at the margins when inside them
+func (s *Screen) moveVertical(n int) {
+	minY, maxY := 0, s.rows-1
+	if s.y >= s.top && s.y <= s.bottom {
+		minY, maxY = s.top, s.bottom
+	}
+	s.y = clamp(s.y+n, minY, maxY)
+	s.wrapPending = false
+}
+
+// eraseInLine blanks columns from..to (inclusive) of a line
+func (s *Screen) eraseInLine(y, from, to int) {
+	from = clamp(from, 0, s.cols-1)
+	to = clamp(to, 0, s.cols-1)
+	for x := from; x <= to; x++ {
+		s.setCell(y, x, blankCell(s.attr))
+	}
+}
+
+// eraseDisplay implements ED
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseInLine(s.y, s.x, s.cols-1)
+		for y := s.y + 1; y < s.rows; y++ {
+			s.lines[y] = makeLine(s.cols, s.attr)
+		}
+	case 1:
+		for y := 0; y < s.y; y++ {
+			s.lines[y] = makeLine(s.cols, s.attr)
+		}
+		s.eraseInLine(s.y, 0, s.x)
+	case 2, 3:
+		for y := range s.lines {
+			s.lines[y] = makeLine(s.cols, s.attr)
+		}
+	}
+}
+
+// insertCells shifts the rest of the line right by n blank cells (ICH)
+func (s *Screen) insertCells(n int) {
+	line := s.lines[s.y]
+	n = clamp(n, 0, s.cols-s.x)
+	copy(line[s.x+n:], line[s.x:s.cols-n])
+	for x := s.x; x < s.x+n; x++ {
+		line[x] = blankCell(s.attr)
+	}
+	s.fixWideEdge(line)
+}
+
+// deleteCells shifts the rest of the line left by n, blanking the right end (DCH)
+func (s *Screen) deleteCells(n int) {
+	line := s.lines[s.y]
+	n = clamp(n, 0, s.cols-s.x)
+	copy(line[s.x:], line[s.x+n:])
+	for x := s.cols - n; x < s.cols; x++ {
+		line[x] = blankCell(s.attr)
+	}
+	if line[s.x].width == 0 {
+		line[s.x] = blankCell(s.attr)
+	}
+	s.fixWideEdge(line)
+}
+
+// fixWideEdge blanks a wide character cut in half at the right margin
+func (s *Screen) fixWideEdge(line []cell) {
+	if last := len(line) - 1; last >= 0 && line[last].width == 2 {
+		line[last] = blankCell(line[last].attr)
+	}
+}
+
+// setAltScreen switches between the primary and alternate screens.
+// With saveCursor (mode 1049) the cursor is saved on entry, restored on exit and the alternate screen cleared.
+func (s *Screen) setAltScreen(on, saveCursor, clear bool) {
+	if on == s.altActive {
+		return
+	}
+
+	if on {
+		if saveCursor {
+			s.altSaved = s.cursorState()
+		}
+		s.altActive = true
+		s.lines = s.alternate
+		if clear {
+			s.eraseDisplay(2)
+		}
+		return
+	}
+
+	if clear {
+		s.eraseDisplay(2)
+	}
+	s.altActive = false
+	s.lines = s.primary
+	if saveCursor {
+		s.restoreCursorState(s.altSaved)
+	}
+}
+
+// cursorState captures the cursor for DECSC
+func (s *Screen) cursorState() savedCursor {
+	return savedCursor{x: s.x, y: s.y, attr: s.attr, originMode: s.originMode, wrapPending: s.wrapPending}
+}
+
+// restoreCursorState puts back a cursor saved by DECSC
+func (s *Screen) restoreCursorState(saved savedCursor) {
+	s.x = clamp(saved.x, 0, s.cols-1)
+	s.y = clamp(saved.y, 0, s.rows-1)
+	s.attr = saved.attr
+	s.originMode = saved.originMode
+	s.wrapPending = saved.wrapPending
+}
+
+// tab moves the cursor to the next tab stop, or the right margin
+func (s *Screen) tab(n int) {
+	for ; n > 0 && s.x < s.cols-1; n-- {
+		s.x++
+		for s.x < s.cols-1 && !s.tabs[s.x] {
+			s.x++
+		}
+	}
+	s.wrapPending = false
+}
+
+// backTab moves the cursor to the previous tab stop, or the left margin
+func (s *Screen) backTab(n int) {
+	for ; n > 0 && s.x > 0; n-- {
+		s.x--
+		for s.x > 0 && !s.tabs[s.x] {
+			s.x--
+		}
+	}
+	s.wrapPending = false
+}
+
+// runeWidth returns how many columns a character occupies: 0 for combining marks, 2 for wide CJK
+func runeWidth(r rune) int {
+	if r == 0x200B || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// makeLine returns a blank line
+func makeLine(cols int, attr cellAttr) []cell {
+	line := make([]cell, cols)
+	for i := range line {
+		line[i] = blankCell(attr)
+	}
+	return line
+}
+
+// makeLines returns n blank lines
+func makeLines(n, cols int, attr cellAttr) [][]cell {
+	lines := make([][]cell, n)
+	for i := range lines {
+		lines[i] = makeLine(cols, attr)
+	}
+	return lines
+}
+
+// resizeLines truncates or pads a screen buffer to a new size
+func resizeLines(lines [][]cell, rows, cols int) [][]cell {
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	for i, line := range lines {
+		switch {
+		case len(line) > cols:
+			line = line[:cols]
+			if line[cols-1].width == 2 {
+				line[cols-1] = blankCell(line[cols-1].attr)
+			}
+		case len(line) < cols:
+			line = append(line, makeLine(cols-len(line), cellAttr{})...)
+		}
+		lines[i] = line
+	}
+	for len(lines) < rows {
+		lines = append(lines, makeLine(cols, cellAttr{}))
+	}
+	return lines
+}
+
+// clamp limits v to lo..hi
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// sgrReset ends every ANSI-rendered line
+const sgrReset = "\x1b[0m"
+
+// renderPlain renders a line as text without trailing spaces
+func renderPlain(line []cell) string {
+	var b strings.Builder
+	for _, c := range line {
+		if c.width == 0 {
+			continue
+		}
+		if c.ch == 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(c.ch)
+		b.WriteString(c.comb)
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// renderANSI renders a line with SGR sequences, dropping trailing unstyled blanks
+func renderANSI(line []cell) string {
+	end := len(line)
+	for end > 0 && line[end-1].ch == 0 && line[end-1].attr == (cellAttr{}) {
+		end--
+	}
+
+	var b strings.Builder
+	current := cellAttr{}
+	for _, c := range line[:end] {
+		if c.width == 0 {
+			continue
+		}
+		if c.attr != current {
+			b.WriteString(sgrFor(c.attr))
+			current = c.attr
+		}
+		if c.ch == 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(c.ch)
+		b.WriteString(c.comb)
+	}
+	if current != (cellAttr{}) {
+		b.WriteString(sgrReset)
+	}
+	return b.String()
+}
+
+// sgrFor returns the SGR sequence that selects attr from a reset state
+func sgrFor(attr cellAttr) string {
+	params := []string{"0"}
+	flagCodes := []struct {
+		flag uint8
+		code string
+	}{
+		{attrBold, "1"}, {attrDim, "2"}, {attrItalic, "3"}, {attrUnderline, "4"},
+		{attrBlink, "5"}, {attrReverse, "7"}, {attrHidden, "8"}, {attrStrike, "9"},
+	}
+	for _, fc := range flagCodes {
+		if attr.flags&fc.flag != 0 {
+			params = append(params, fc.code)
+		}
+	}
+	params = append(params, colorParams(attr.fg, 30)...)
+	params = append(params, colorParams(attr.bg, 40)...)
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// colorParams returns the SGR parameters for a color; base is 30 for foreground and 40 for background
+func colorParams(c color, base int) []string {
+	switch c.kind {
+	case colorIndexed:
+		switch {
+		case c.value < 8:
+			return []string{strconv.Itoa(base + int(c.value))}
+		case c.value < 16:
+			return []string{strconv.Itoa(base + 60 + int(c.value) - 8)}
+		default:
+			return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(int(c.value))}
+		}
+	case colorRGB:
+		return []string{
+			strconv.Itoa(base + 8), "2",
+			strconv.Itoa(int(c.value >> 16 & 0xff)),
+			strconv.Itoa(int(c.value >> 8 & 0xff)),
+			strconv.Itoa(int(c.value & 0xff)),
+		}
+	}
+	return nil
+}
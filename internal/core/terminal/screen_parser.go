@@ -0,0 +1,488 @@
+package terminal
+
+import "unicode/utf8"
+
+// parserState is where the escape sequence parser is within its input
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeCharset
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString
+	stateStringEscape
+)
+
+// maxParams bounds the parameters kept for one control sequence
+const maxParams = 32
+
+// maxOSCLength bounds the operating system command text kept for one sequence
+const maxOSCLength = 4096
+
+// screenParser holds the parser state carried between writes
+type screenParser struct {
+	state        parserState
+	params       []int
+	current      int
+	hasCurrent   bool
+	private      byte
+	intermediate byte
+	osc          []byte
+	utf8         []byte
+}
+
+// Write feeds terminal output to the screen. It never fails.
+func (s *Screen) Write(p []byte) (int, error) {
+	for _, b := range p {
+		s.feed(b)
+	}
+	return len(p), nil
+}
+
+// feed advances the parser by one byte
+func (s *Screen) feed(b byte) {
+	p := &s.parser
+
+	switch p.state {
+	case stateGround:
+		s.feedGround(b)
+
+	case stateEscape:
+		s.escape(b)
+
+	case stateEscapeCharset:
+		// The charset designator is consumed; character sets are not emulated
+		p.state = stateGround
+
+	case stateCSI:
+		s.feedCSI(b)
+
+	case stateOSC:
+		switch b {
+		case 0x07:
+			s.osc()
+			p.state = stateGround
+		case 0x1b:
+			p.state = stateOSCEscape
+		default:
+			if len(p.osc) < maxOSCLength {
+				p.osc = append(p.osc, b)
+			}
+		}
+
+	case stateOSCEscape:
+		if b == '\\' {
+			s.osc()
+			p.state = stateGround
+			return
+		}
+		s.osc()
+		p.state = stateEscape
+		s.escape(b)
+
+	case stateString:
+		if b == 0x1b {
+			p.state = stateStringEscape
+		} else if b == 0x07 {
+			p.state = stateGround
+		}
+
+	case stateStringEscape:
+		if b == '\\' {
+			p.state = stateGround
+			return
+		}
+		p.state = stateEscape
+		s.escape(b)
+	}
+}
+
+// feedGround prints text and executes control characters
+func (s *Screen) feedGround(b byte) {
+	p := &s.parser
+
+	if len(p.utf8) > 0 {
+		if b&0xc0 == 0x80 {
+			p.utf8 = append(p.utf8, b)
+			if utf8.FullRune(p.utf8) {
+				r, _ := utf8.DecodeRune(p.utf8)
+				p.utf8 = p.utf8[:0]
+				s.print(r)
+			}
+			return
+		}
+		// A sequence cut short by another byte becomes a replacement character
+		p.utf8 = p.utf8[:0]
+		s.print(utf8.RuneError)
+	}
+
+	switch {
+	case b == 0x1b:
+		p.state = stateEscape
+	case b < 0x20 || b == 0x7f:
+		s.execute(b)
+	case b < 0x80:
+		s.print(rune(b))
+	case b >= 0xc2 && b <= 0xf4:
+		p.utf8 = append(p.utf8, b)
+	default:
+		s.print(utf8.RuneError)
+	}
+}
+
+// feedCSI collects the parameters of a control sequence and dispatches it on its final byte
+func (s *Screen) feedCSI(b byte) {
+	p := &s.parser
+
+	switch {
+	case b == 0x1b:
+		p.state = stateEscape
+	case b < 0x20:
+		s.execute(b)
+	case b >= '0' && b <= '9':
+		if p.current < 100000 {
+			p.current = p.current*10 + int(b-'0')
+		}
+		p.hasCurrent = true
+	case b == ';' || b == ':':
+		p.pushParam()
+	case b >= '<' && b <= '?':
+		p.private = b
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediate = b
+	case b >= 0x40 && b <= 0x7e:
+		p.pushParam()
+		p.state = stateGround
+		s.csi(b)
+	default:
+		p.state = stateGround
+	}
+}
+
+// pushParam ends the parameter being collected; an empty parameter is stored as -1
+func (p *screenParser) pushParam() {
+	if len(p.params) < maxParams {
+		if p.hasCurrent {
+			p.params = append(p.params, p.current)
+		} else {
+			p.params = append(p.params, -1)
+		}
+	}
+	p.current = 0
+	p.hasCurrent = false
+}
+
+// param returns parameter i, or def when it is missing or zero
+func (p *screenParser) param(i, def int) int {
+	if i >= len(p.params) || p.params[i] <= 0 {
+		return def
+	}
+	return p.params[i]
+}
+
+// execute runs a C0 control character
+func (s *Screen) execute(b byte) {
+	switch b {
+	case '\b':
+		if s.wrapPending {
+			s.wrapPending = false
+		} else if s.x > 0 {
+			s.x--
+		}
+	case '\t':
+		s.tab(1)
+	case '\n', '\v', '\f':
+		s.lineFeed()
+		s.wrapPending = false
+	case '\r':
+		s.x = 0
+		s.wrapPending = false
+	}
+}
+
+// escape handles the byte after ESC
+func (s *Screen) escape(b byte) {
+	p := &s.parser
+	p.state = stateGround
+
+	switch b {
+	case '[':
+		p.state = stateCSI
+		p.params = p.params[:0]
+		p.current = 0
+		p.hasCurrent = false
+		p.private = 0
+		p.intermediate = 0
+	case ']':
+		p.state = stateOSC
+		p.osc = p.osc[:0]
+	case 'P', 'X', '^', '_':
+		p.state = stateString
+	case '(', ')', '*', '+', '-', '.', '/', '#', '%', ' ':
+		p.state = stateEscapeCharset
+	case '7':
+		s.saved = s.cursorState()
+	case '8':
+		s.restoreCursorState(s.saved)
+	case 'D':
+		s.lineFeed()
+		s.wrapPending = false
+	case 'E':
+		s.x = 0
+		s.lineFeed()
+		s.wrapPending = false
+	case 'M':
+		s.reverseIndex()
+		s.wrapPending = false
+	case 'H':
+		s.tabs[s.x] = true
+	case 'c':
+		s.reset()
+	case 0x1b:
+		p.state = stateEscape
+	}
+}
+
+// osc handles a complete operating system command; only the window title is kept
+func (s *Screen) osc() {
+	text := string(s.parser.osc)
+	code, title, ok := cutByte(text, ';')
+	if !ok {
+		return
+	}
+	if code == "0" || code == "2" {
+		s.title = title
+	}
+}
+
+// cutByte splits text around the first sep
+func cutByte(text string, sep byte) (string, string, bool) {
+	for i := 0; i < len(text); i++ {
+		if text[i] == sep {
+			return text[:i], text[i+1:], true
+		}
+	}
+	return text, "", false
+}
+
+// csi dispatches a complete control sequence
+func (s *Screen) csi(final byte) {
+	p := &s.parser
+
+	if p.private == '?' {
+		if final == 'h' || final == 'l' {
+			s.setPrivateModes(final == 'h')
+		}
+		return
+	}
+	if p.private != 0 || p.intermediate != 0 {
+		return
+	}
+
+	n := p.param(0, 1)
+	switch final {
+	case 'A':
+		s.moveVertical(-n)
+	case 'B', 'e':
+		s.moveVertical(n)
+	case 'C', 'a':
+		s.x = clamp(s.x+n, 0, s.cols-1)
+		s.wrapPending = false
+	case 'D':
+		s.x = clamp(s.x-n, 0, s.cols-1)
+		s.wrapPending = false
+	case 'E':
+		s.moveVertical(n)
+		s.x = 0
+	case 'F':
+		s.moveVertical(-n)
+		s.x = 0
+	case 'G', '`':
+		s.x = clamp(n-1, 0, s.cols-1)
+		s.wrapPending = false
+	case 'H', 'f':
+		s.moveTo(p.param(0, 1)-1, p.param(1, 1)-1)
+	case 'd':
+		s.moveTo(n-1, s.x)
+	case 'I':
+		s.tab(n)
+	case 'Z':
+		s.backTab(n)
+	case 'J':
+		s.eraseDisplay(max(p.param(0, 0), 0))
+	case 'K':
+		switch p.param(0, 0) {
+		case 0:
+			s.eraseInLine(s.y, s.x, s.cols-1)
+		case 1:
+			s.eraseInLine(s.y, 0, s.x)
+		case 2:
+			s.eraseInLine(s.y, 0, s.cols-1)
+		}
+		s.wrapPending = false
+	case 'L':
+		if s.y >= s.top && s.y <= s.bottom {
+			s.scrollRegionDown(s.y, s.bottom, n)
+			s.x = 0
+		}
+	case 'M':
+		if s.y >= s.top && s.y <= s.bottom {
+			s.scrollRegionUp(s.y, s.bottom, n)
+			s.x = 0
+		}
+	case 'P':
+		s.deleteCells(n)
+		s.wrapPending = false
+	case '@':
+		s.insertCells(n)
+		s.wrapPending = false
+	case 'X':
+		s.eraseInLine(s.y, s.x, s.x+n-1)
+		s.wrapPending = false
+	case 'S':
+		s.scrollUp(n)
+	case 'T':
+		s.scrollDown(n)
+	case 'b':
+		if s.lastChar != 0 {
+			for i := 0; i < min(n, s.rows*s.cols); i++ {
+				s.print(s.lastChar)
+			}
+		}
+	case 'g':
+		switch p.param(0, 0) {
+		case 0:
+			s.tabs[s.x] = false
+		case 3:
+			for i := range s.tabs {
+				s.tabs[i] = false
+			}
+		}
+	case 'r':
+		top, bottom := p.param(0, 1)-1, p.param(1, s.rows)-1
+		if bottom > s.rows-1 {
+			bottom = s.rows - 1
+		}
+		if top < bottom {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saved = s.cursorState()
+	case 'u':
+		s.restoreCursorState(s.saved)
+	case 'h', 'l':
+		for _, mode := range p.params {
+			if mode == 4 {
+				s.insertMode = final == 'h'
+			}
+		}
+	case 'm':
+		s.selectGraphicRendition()
+	}
+}
+
+// setPrivateModes handles DECSET and DECRST
+func (s *Screen) setPrivateModes(on bool) {
+	for _, mode := range s.parser.params {
+		switch mode {
+		case 6:
+			s.originMode = on
+			s.moveTo(0, 0)
+		case 7:
+			s.autowrap = on
+			if !on {
+				s.wrapPending = false
+			}
+		case 25:
+			s.cursorVisible = on
+		case 47, 1047:
+			s.setAltScreen(on, false, mode == 1047 && !on)
+		case 1049:
+			s.setAltScreen(on, true, true)
+		}
+	}
+}
+
+// selectGraphicRendition applies SGR parameters to the current attributes
+func (s *Screen) selectGraphicRendition() {
+	params := s.parser.params
+	if len(params) == 0 {
+		params = []int{0}
+	}
+
+	for i := 0; i < len(params); i++ {
+		code := params[i]
+		switch {
+		case code <= 0:
+			s.attr = cellAttr{}
+		case code >= 1 && code <= 9 && code != 6:
+			s.attr.flags |= sgrFlags[code]
+		case code == 21 || code == 22:
+			s.attr.flags &^= attrBold | attrDim
+		case code == 23:
+			s.attr.flags &^= attrItalic
+		case code == 24:
+			s.attr.flags &^= attrUnderline
+		case code == 25:
+			s.attr.flags &^= attrBlink
+		case code == 27:
+			s.attr.flags &^= attrReverse
+		case code == 28:
+			s.attr.flags &^= attrHidden
+		case code == 29:
+			s.attr.flags &^= attrStrike
+		case code >= 30 && code <= 37:
+			s.attr.fg = color{kind: colorIndexed, value: uint32(code - 30)}
+		case code == 38:
+			s.attr.fg, i = extendedColor(params, i)
+		case code == 39:
+			s.attr.fg = color{}
+		case code >= 40 && code <= 47:
+			s.attr.bg = color{kind: colorIndexed, value: uint32(code - 40)}
+		case code == 48:
+			s.attr.bg, i = extendedColor(params, i)
+		case code == 49:
+			s.attr.bg = color{}
+		case code >= 90 && code <= 97:
+			s.attr.fg = color{kind: colorIndexed, value: uint32(code - 90 + 8)}
+		case code >= 100 && code <= 107:
+			s.attr.bg = color{kind: colorIndexed, value: uint32(code - 100 + 8)}
+		}
+	}
+}
+
+// sgrFlags maps SGR codes 1-9 to attribute flags
+var sgrFlags = [10]uint8{
+	1: attrBold, 2: attrDim, 3: attrItalic, 4: attrUnderline,
+	5: attrBlink, 7: attrReverse, 8: attrHidden, 9: attrStrike,
+}
+
+// extendedColor parses "38;5;n" or "38;2;r;g;b" starting at params[i] and returns the index of its last parameter
+func extendedColor(params []int, i int) (color, int) {
+	if i+1 >= len(params) {
+		return color{}, i
+	}
+	switch params[i+1] {
+	case 5:
+		if i+2 < len(params) {
+			return color{kind: colorIndexed, value: uint32(clamp(params[i+2], 0, 255))}, i + 2
+		}
+	case 2:
+		if i+4 < len(params) {
+			r := uint32(clamp(params[i+2], 0, 255))
+			g := uint32(clamp(params[i+3], 0, 255))
+			b := uint32(clamp(params[i+4], 0, 255))
+			return color{kind: colorRGB, value: r<<16 | g<<8 | b}, i + 4
+		}
+	}
+	return color{}, len(params)
+}
+
+// String renders the screen as plain text
+func (s *Screen) String() string {
+	return s.Snapshot(false).Content
+}
@@ -0,0 +1,221 @@
+package terminal
+
+import (
+	"strings"
+	"testing"
+)
+
+// screenWith feeds input to a fresh screen of the given size
+func screenWith(rows, cols int, input string) *Screen {
+	s := NewScreen(rows, cols)
+	s.Write([]byte(input))
+	return s
+}
+
+func TestScreenSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantRow int
+		wantCol int
+	}{
+		// Text and C0 controls
+		{name: "text and CRLF", input: "ab\r\ncd", want: "ab\ncd\n", wantRow: 1, wantCol: 2},
+		{name: "LF keeps the column", input: "ab\ncd", want: "ab\n  cd\n", wantRow: 1, wantCol: 4},
+		{name: "backspace", input: "abc\b\bX", want: "aXc\n", wantRow: 0, wantCol: 2},
+		{name: "tab stops", input: "a\tb", want: "a       b\n", wantRow: 0, wantCol: 9},
+		{name: "empty", input: "", want: "", wantRow: 0, wantCol: 0},
+
+		// Cursor addressing
+		{name: "CUP", input: "\x1b[3;4Hx", want: "\n\n   x\n", wantRow: 2, wantCol: 4},
+		{name: "CUP defaults to home", input: "abc\x1b[Hx", want: "xbc\n", wantRow: 0, wantCol: 1},
+		{name: "CUP clamps to the screen", input: "\x1b[99;99Hx", want: "\n\n\n\n         x\n", wantRow: 4, wantCol: 9},
+		{name: "CUB", input: "abc\x1b[2DX", want: "aXc\n", wantRow: 0, wantCol: 2},
+		{name: "CUD CHA CUU", input: "\x1b[3B\x1b[5Gz\x1b[2Ay", want: "\n     y\n\n    z\n", wantRow: 1, wantCol: 6},
+		{name: "CUF stops at the margin", input: "\x1b[20Cx", want: "         x\n", wantRow: 0, wantCol: 9},
+		{name: "VPA", input: "ab\x1b[3dc", want: "ab\n\n  c\n", wantRow: 2, wantCol: 3},
+		{name: "DECSC and DECRC", input: "ab\x1b7\x1b[3;3Hx\x1b8y", want: "aby\n\n  x\n", wantRow: 0, wantCol: 3},
+		{name: "SCOSC and SCORC", input: "ab\x1b[s\x1b[3;3Hx\x1b[uy", want: "aby\n\n  x\n", wantRow: 0, wantCol: 3},
+
+		// Wrapping
+		{name: "wraps at the margin", input: "0123456789ab", want: "0123456789\nab\n", wantRow: 1, wantCol: 2},
+		{name: "wrap waits for the next character", input: "0123456789", want: "0123456789\n", wantRow: 0, wantCol: 9},
+		{name: "CR cancels a pending wrap", input: "0123456789\rX", want: "X123456789\n", wantRow: 0, wantCol: 1},
+		{name: "no autowrap overwrites the last column", input: "\x1b[?7l0123456789ab", want: "012345678b\n", wantRow: 0, wantCol: 9},
+
+		// Wide and combining characters
+		{name: "wide characters take two columns", input: "a世界b", want: "a世界b\n", wantRow: 0, wantCol: 6},
+		{name: "wide character wraps instead of straddling the margin", input: "012345678世", want: "012345678\n世\n", wantRow: 1, wantCol: 2},
+		{name: "overwriting a wide character's first half", input: "世界\x1b[1Gx", want: "x 界\n", wantRow: 0, wantCol: 1},
+		{name: "overwriting a wide character's second half", input: "世\x1b[2Gx", want: " x\n", wantRow: 0, wantCol: 2},
+		{name: "combining mark joins the character before it", input: "e\u0301x", want: "e\u0301x\n", wantRow: 0, wantCol: 2},
+
+		// Erasing
+		{name: "EL to the end", input: "abcdef\x1b[3G\x1b[K", want: "ab\n", wantRow: 0, wantCol: 2},
+		{name: "EL to the start", input: "abcdef\x1b[3G\x1b[1K", want: "   def\n", wantRow: 0, wantCol: 2},
+		{name: "EL the whole line", input: "abc\r\ndef\x1b[2K", want: "abc\n", wantRow: 1, wantCol: 3},
+		{name: "ED below", input: "aaa\r\nbbb\r\nccc\x1b[2;2H\x1b[J", want: "aaa\nb\n", wantRow: 1, wantCol: 1},
+		{name: "ED above", input: "aaa\r\nbbb\r\nccc\x1b[2;2H\x1b[1J", want: "\n  b\nccc\n", wantRow: 1, wantCol: 1},
+		{name: "ED all keeps the cursor", input: "aaa\r\nbbb\x1b[2J", want: "", wantRow: 1, wantCol: 3},
+		{name: "ECH", input: "abcdef\x1b[2G\x1b[3X", want: "a   ef\n", wantRow: 0, wantCol: 1},
+		{name: "ICH", input: "abcdef\x1b[2G\x1b[2@", want: "a  bcdef\n", wantRow: 0, wantCol: 1},
+		{name: "DCH", input: "abcdef\x1b[2G\x1b[2P", want: "adef\n", wantRow: 0, wantCol: 1},
+		{name: "insert mode", input: "abc\x1b[1G\x1b[4hX", want: "Xabc\n", wantRow: 0, wantCol: 1},
+		{name: "REP", input: "a\x1b[3b", want: "aaaa\n", wantRow: 0, wantCol: 4},
+		{name: "RIS", input: "abc\r\ndef\x1bc", want: "", wantRow: 0, wantCol: 0},
+
+		// Scrolling
+		{name: "LF at the bottom scrolls", input: "1\r\n2\r\n3\r\n4\r\n5\r\n6", want: "2\n3\n4\n5\n6\n", wantRow: 4, wantCol: 1},
+		{name: "scroll region keeps the lines outside it",
+			input:   "\x1b[2;4rtop\x1b[5;1Hbottom\x1b[2;1Ha\r\nb\r\nc\r\nd",
+			want:    "top\nb\nc\nd\nbottom\n",
+			wantRow: 3, wantCol: 1},
+		{name: "RI at the region's top scrolls it down",
+			input:   "\x1b[2;4r\x1b[2;1Ha\r\nb\x1b[2;1H\x1bMz",
+			want:    "\nz\na\nb\n",
+			wantRow: 1, wantCol: 1},
+		{name: "IL", input: "1\r\n2\r\n3\x1b[2;1H\x1b[L", want: "1\n\n2\n3\n", wantRow: 1, wantCol: 0},
+		{name: "DL", input: "1\r\n2\r\n3\x1b[1;1H\x1b[M", want: "2\n3\n", wantRow: 0, wantCol: 0},
+		{name: "SU and SD", input: "1\r\n2\r\n3\x1b[2S\x1b[T", want: "\n3\n", wantRow: 2, wantCol: 1},
+		{name: "origin mode addresses the region", input: "\x1b[2;4r\x1b[?6h\x1b[1;1Hx\x1b[9;1Hy", want: "\nx\n\ny\n", wantRow: 3, wantCol: 1},
+
+		// Alternate screen
+		{name: "1049 clears the alternate screen and keeps the cursor", input: "main\x1b[?1049halt", want: "    alt\n", wantRow: 0, wantCol: 7},
+		{name: "1049 restores the primary screen and cursor", input: "main\x1b[?1049halt\x1b[5;5Hx\x1b[?1049l", want: "main\n", wantRow: 0, wantCol: 4},
+		{name: "47 keeps the alternate screen's content", input: "\x1b[?47hx\x1b[?47l\x1b[?47h", want: "x\n", wantRow: 0, wantCol: 1},
+		{name: "1047 clears the alternate screen on exit", input: "\x1b[?1047hx\x1b[?1047l\x1b[?47h", want: "", wantRow: 0, wantCol: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := screenWith(5, 10, tt.input).Snapshot(false)
+			if snapshot.Content != tt.want {
+				t.Errorf("content = %q, want %q", snapshot.Content, tt.want)
+			}
+			if snapshot.CursorRow != tt.wantRow || snapshot.CursorCol != tt.wantCol {
+				t.Errorf("cursor = %d,%d, want %d,%d", snapshot.CursorRow, snapshot.CursorCol, tt.wantRow, tt.wantCol)
+			}
+		})
+	}
+}
+
+func TestScreenSnapshotState(t *testing.T) {
+	s := screenWith(5, 10, "\x1b]0;my title\x07\x1b[?25l\x1b[?1049h")
+	snapshot := s.Snapshot(false)
+	if snapshot.Title != "my title" || snapshot.CursorVisible || !snapshot.AltScreen {
+		t.Errorf("title = %q, cursor visible = %v, alt screen = %v; want my title, false, true",
+			snapshot.Title, snapshot.CursorVisible, snapshot.AltScreen)
+	}
+
+	s.Write([]byte("\x1b]2;other\x1b\\\x1b[?25h\x1b[?1049l"))
+	snapshot = s.Snapshot(false)
+	if snapshot.Title != "other" || !snapshot.CursorVisible || snapshot.AltScreen {
+		t.Errorf("title = %q, cursor visible = %v, alt screen = %v; want other, true, false",
+			snapshot.Title, snapshot.CursorVisible, snapshot.AltScreen)
+	}
+}
+
+func TestScreenSnapshotANSI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain text has no SGR", input: "plain", want: "plain\n"},
+		{name: "attributes end before unstyled text", input: "\x1b[1;31mred\x1b[0m plain", want: "\x1b[0;1;31mred\x1b[0m plain\n"},
+		{name: "styled line ends with a reset", input: "\x1b[4mu", want: "\x1b[0;4mu\x1b[0m\n"},
+		{name: "bright colors", input: "\x1b[91;102mz", want: "\x1b[0;91;102mz\x1b[0m\n"},
+		{name: "256 and RGB colors", input: "\x1b[38;5;200mx\x1b[48;2;1;2;3my", want: "\x1b[0;38;5;200mx\x1b[0;38;5;200;48;2;1;2;3my\x1b[0m\n"},
+		{name: "attributes switched off one by one", input: "\x1b[1;3ma\x1b[22mb\x1b[23mc", want: "\x1b[0;1;3ma\x1b[0;3mb\x1b[0mc\n"},
+		{name: "erase uses the current background", input: "\x1b[44m\x1b[K", want: "\x1b[0;44m          \x1b[0m\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := screenWith(2, 10, tt.input).Snapshot(true).Content; got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenSplitWrites(t *testing.T) {
+	input := "\x1b[1;31mred\x1b[0m \x1b]0;title\x07世界\x1b[2;3Hx"
+	whole := screenWith(5, 10, input).Snapshot(true)
+
+	s := NewScreen(5, 10)
+	for _, b := range []byte(input) {
+		s.Write([]byte{b})
+	}
+	if split := s.Snapshot(true); *split != *whole {
+		t.Errorf("byte by byte = %+v, want %+v", split, whole)
+	}
+}
+
+func TestScreenCursorLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "prompt with a trailing space", input: "motd\r\nhost:~$ ", want: "host:~$ "},
+		{name: "text right of the cursor is left out", input: "abcdef\x1b[3D", want: "abc"},
+		{name: "pending wrap includes the last column", input: "0123456789", want: "0123456789"},
+		{name: "wide characters", input: "密码: ", want: "密码: "},
+		{name: "gaps read as spaces", input: "a\x1b[4Gb", want: "a  b"},
+		{name: "empty line after CRLF", input: "done\r\n", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := screenWith(5, 10, tt.input).CursorLine(); got != tt.want {
+				t.Errorf("CursorLine = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenResize(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		rows, cols int
+		after      string
+		want       string
+		wantRow    int
+		wantCol    int
+	}{
+		{name: "shrinking keeps the cursor's line in view", input: "1\r\n2\r\n3\r\n4\r\n5", rows: 3, cols: 10, want: "3\n4\n5\n", wantRow: 2, wantCol: 1},
+		{name: "shrinking above the cursor drops the bottom", input: "1\r\n2\r\n3\x1b[1;1H", rows: 2, cols: 10, want: "1\n2\n", wantRow: 0, wantCol: 0},
+		{name: "narrowing truncates lines and clamps the cursor", input: "0123456789", rows: 5, cols: 5, want: "01234\n", wantRow: 0, wantCol: 4},
+		{name: "narrowing blanks a wide character cut in half", input: "0123世", rows: 5, cols: 5, want: "0123\n", wantRow: 0, wantCol: 4},
+		{name: "widening makes new columns addressable", input: "ab", rows: 6, cols: 20, after: "\x1b[6;20Hz", want: "ab\n\n\n\n\n                   z\n", wantRow: 5, wantCol: 19},
+		{name: "widening adds tab stops", input: "", rows: 5, cols: 20, after: "\t\tx", want: "                x\n", wantRow: 0, wantCol: 17},
+		{name: "resizing resets the scroll region", input: "\x1b[2;3r", rows: 4, cols: 10, after: "1\r\n2\r\n3\r\n4\r\n5", want: "2\n3\n4\n5\n", wantRow: 3, wantCol: 1},
+		{name: "the primary screen survives a resize on the alternate screen",
+			input: "main\x1b[?1049halt", rows: 3, cols: 6, after: "\x1b[?1049l",
+			want: "main\n", wantRow: 0, wantCol: 4},
+		{name: "the alternate screen is resized too", input: "\x1b[?1049h0123456789", rows: 5, cols: 4, want: "0123\n", wantRow: 0, wantCol: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := screenWith(5, 10, tt.input)
+			s.Resize(tt.rows, tt.cols)
+			s.Write([]byte(tt.after))
+
+			snapshot := s.Snapshot(false)
+			if snapshot.Rows != tt.rows || snapshot.Cols != tt.cols {
+				t.Errorf("size = %dx%d, want %dx%d", snapshot.Rows, snapshot.Cols, tt.rows, tt.cols)
+			}
+			if snapshot.Content != tt.want {
+				t.Errorf("content = %q, want %q", snapshot.Content, tt.want)
+			}
+			if snapshot.CursorRow != tt.wantRow || snapshot.CursorCol != tt.wantCol {
+				t.Errorf("cursor = %d,%d, want %d,%d", snapshot.CursorRow, snapshot.CursorCol, tt.wantRow, tt.wantCol)
+			}
+			for i, line := range strings.Split(strings.TrimSuffix(snapshot.Content, "\n"), "\n") {
+				if width := len([]rune(line)); width > tt.cols {
+					t.Errorf("line %d is %d columns wide on a %d column screen", i, width, tt.cols)
+				}
+			}
+		})
+	}
+}
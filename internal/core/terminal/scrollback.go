@@ -0,0 +1,55 @@
+package terminal
+
+// DefaultScrollbackSize is the number of output bytes kept per session when not configured
+const DefaultScrollbackSize = 256 * 1024
+
+// ringBuffer keeps the most recent bytes written to it, up to a fixed capacity
+type ringBuffer struct {
+	data   []byte
+	pos    int
+	filled bool
+}
+
+// newRingBuffer creates a ring buffer holding at most size bytes
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{data: make([]byte, size)}
+}
+
+// Write appends p, overwriting the oldest bytes once the buffer is full
+func (rb *ringBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	size := len(rb.data)
+	if size == 0 {
+		return n, nil
+	}
+
+	// Only the tail of an oversized write can be kept
+	if n >= size {
+		copy(rb.data, p[n-size:])
+		rb.pos = 0
+		rb.filled = true
+		return n, nil
+	}
+
+	copied := copy(rb.data[rb.pos:], p)
+	if copied < n {
+		copy(rb.data, p[copied:])
+	}
+	if rb.pos+n >= size {
+		rb.filled = true
+	}
+	rb.pos = (rb.pos + n) % size
+
+	return n, nil
+}
+
+// Bytes returns a copy of the buffered output, oldest first
+func (rb *ringBuffer) Bytes() []byte {
+	if !rb.filled {
+		return append([]byte(nil), rb.data[:rb.pos]...)
+	}
+
+	result := make([]byte, 0, len(rb.data))
+	result = append(result, rb.data[rb.pos:]...)
+	return append(result, rb.data[:rb.pos]...)
+}
@@ -1,6 +1,7 @@
 package terminal
 
 import (
+	"io"
 	"os"
 	"time"
 )
@@ -10,18 +11,28 @@ type Server interface {
 	// CreateSession creates a new PTY session
 	CreateSession(sessionID, shell string, args []string) (Session, error)
 
-	// KillSession terminates a session
+	// CreateSessionWithConfig creates a new PTY session from a full session configuration
+	CreateSessionWithConfig(config SessionConfig) (Session, error)
+
+	// KillSession terminates a session using its grace period
 	KillSession(sessionID string) error
 
+	// StopSession sends SIGHUP and SIGTERM to the session's process group, waits up to grace
+	// (zero uses the session's grace period) and then sends SIGKILL
+	StopSession(sessionID string, grace time.Duration) (*StopResult, error)
+
 	// ResizeSession updates the terminal size for a session
 	ResizeSession(sessionID string, rows, cols uint16) error
 
 	// GetSession returns session information
 	GetSession(sessionID string) (Session, error)
 
-	// ListSessions returns all active sessions
+	// ListSessions returns all running sessions and the most recently ended ones
 	ListSessions() map[string]Session
 
+	// Events returns the bus on which session lifecycle events are published
+	Events() *EventBus
+
 	// Shutdown gracefully shuts down the server
 	Shutdown() error
 }
@@ -40,6 +51,15 @@ type Session interface {
 	// IsRunning returns true if the session is currently running
 	IsRunning() bool
 
+	// GetExitStatus returns how the session ended, or nil while it is running
+	GetExitStatus() *ExitStatus
+
+	// Done returns a channel that is closed once the session has ended and its output is drained
+	Done() <-chan struct{}
+
+	// Wait blocks until the session ends; it returns an *ExitError unless the process exited with status 0
+	Wait() error
+
 	// GetInfo returns session information as a map
 	GetInfo() map[string]interface{}
 
@@ -48,9 +68,88 @@ type Session interface {
 
 	// GetPTYMaster returns the PTY master file for direct I/O operations
 	GetPTYMaster() *os.File
+
+	// AddOutput registers a writer that receives everything the PTY prints
+	AddOutput(id string, w io.Writer)
+
+	// AddClient attaches a client; it receives the scrollback unless skipped, then live output and resize events.
+	// A session accepts any number of read-only clients but only one client that can write.
+	AddClient(config ClientConfig) error
+
+	// RemoveClient detaches a client once its pending output has been delivered
+	RemoveClient(clientID string)
+
+	// GetClients returns the clients currently attached to the session
+	GetClients() []ClientInfo
+
+	// SetClientSize records a client's terminal size and resizes the PTY according to the session's policy
+	SetClientSize(clientID string, rows, cols uint16) error
+
+	// MarkClientActive records that a client just typed, for the "latest" resize policy
+	MarkClientActive(clientID string)
+
+	// GetResizePolicy returns the policy used when several clients are attached
+	GetResizePolicy() ResizePolicy
+
+	// GetScrollback returns a copy of the session's recent output
+	GetScrollback() []byte
+
+	// GetScreen renders what the session's terminal currently shows, as plain text or with ANSI attributes
+	GetScreen(ansi bool) *ScreenSnapshot
+
+	// RemoveOutput unregisters a writer previously added with AddOutput
+	RemoveOutput(id string)
 }
 
 // ServerConfig holds configuration for terminal server
 type ServerConfig struct {
 	DefaultShell string
+	// ScrollbackSize is the number of output bytes kept per session; zero uses DefaultScrollbackSize
+	ScrollbackSize int
+	// ResizePolicy sizes sessions with several attached clients; empty uses DefaultResizePolicy
+	ResizePolicy ResizePolicy
+	// EndedSessions is how many ended sessions are kept for inspection; zero uses DefaultEndedSessions
+	EndedSessions int
+	// StopGracePeriod is how long stopped sessions get before SIGKILL; zero uses DefaultStopGracePeriod
+	StopGracePeriod time.Duration
+	// LogDir is the root of session output logs, one directory per target; required for SessionConfig.Log
+	LogDir string
+}
+
+// DefaultEndedSessions is the number of ended sessions kept when not configured
+const DefaultEndedSessions = 50
+
+// SessionConfig holds configuration for a single PTY session
+type SessionConfig struct {
+	ID       string   `json:"id"`
+	Target   string   `json:"target,omitempty"`
+	Shell    string   `json:"shell,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Env      []string `json:"env,omitempty"`
+	Dir      string   `json:"dir,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	// ScrollbackSize overrides the server's scrollback size for this session
+	ScrollbackSize int `json:"scrollback_size,omitempty"`
+	// ResizePolicy overrides the server's resize policy for this session
+	ResizePolicy ResizePolicy `json:"resize_policy,omitempty"`
+	// StopGracePeriod overrides the server's stop grace period for this session
+	StopGracePeriod time.Duration `json:"stop_grace_period,omitempty"`
+	// Log writes the session's output to a rotating log under the server's LogDir when set
+	Log *LogConfig `json:"log,omitempty"`
+	// Dispatch controls when each command is sent; nil waits for a default prompt or quiet output
+	Dispatch *DispatchConfig `json:"dispatch,omitempty"`
+	// Expect answers interactive prompts that appear in the session's output
+	Expect []ExpectRule `json:"expect,omitempty"`
+	// Steps replace Commands with a startup that can also send keys, wait for output, sleep
+	// and mark checkpoints
+	Steps []Step `json:"steps,omitempty"`
+	// Health probes decide when the session is ready and whether it stays healthy
+	Health *HealthConfig `json:"health,omitempty"`
+	// Restart starts the session's process again when it ends on its own; nil never restarts it
+	Restart *RestartConfig `json:"restart,omitempty"`
+	// SSH runs the shell on a remote host instead of locally; Shell, Args and Dir are then unused
+	SSH *SSHConfig `json:"ssh,omitempty"`
+	// Forwards tunnel ports through the SSH connection; they need SSH. Local ports are taken
+	// before the session starts and kept across restarts.
+	Forwards []Forward `json:"forwards,omitempty"`
 }
@@ -2,33 +2,88 @@ package terminal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
-
-	"github.com/creack/pty"
 )
 
 // terminalServer implements Server interface
 type terminalServer struct {
 	mu       sync.RWMutex
 	sessions map[string]*ptySession
+	starting map[string]*ptySession // reserved while their first run connects, without ts.mu
 	ctx      context.Context
 	cancel   context.CancelFunc
 	config   ServerConfig
+	events   *EventBus
 }
 
+// outputDrainTimeout bounds how long a finished session waits for its remaining PTY output
+const outputDrainTimeout = 500 * time.Millisecond
+
 // ptySession implements Session interface
 type ptySession struct {
-	id        string
-	cmd       *exec.Cmd
-	ptyMaster *os.File
-	mu        sync.RWMutex
-	ctx       context.Context
-	cancel    context.CancelFunc
-	startTime time.Time
+	id         string
+	target     string
+	proc       process
+	ssh        *sshTarget
+	forwards   []*forwarder
+	mu         sync.RWMutex
+	ctx        context.Context
+	cancel     context.CancelFunc
+	startTime  time.Time
+	outputs    map[string]io.Writer
+	clients    map[string]*attachedClient
+	scrollback *ringBuffer
+	screen     *Screen
+	transcript *transcript
+	checkpoint string
+	health     HealthState
+	healthErr  string
+	probed     bool
+	stepsDone  chan struct{}
+	shell      string
+	args       []string
+	cmdEnv     []string
+	steps      []*startupStep
+	dispatch   *dispatcher
+	checker    *healthChecker
+	restart    *RestartConfig
+	restarts   int
+	attempts   int
+	runStart   time.Time
+	// pid is the current process's ID, readable without s.mu, which publish runs under
+	pid        atomic.Int64
+	runCtx     context.Context
+	runCancel  context.CancelFunc
+	runDone    chan struct{}
+	restarting bool
+	stopping   bool
+	stopReq    chan struct{}
+	outputSeq  uint64
+	outputAt   time.Time
+	outputWake chan struct{}
+	rows, cols uint16
+	policy     ResizePolicy
+	stopStep   StopStep
+	failure    string
+	expect     []*expectRule
+	env        []string
+	dir        string
+	grace      time.Duration
+	exitStatus *ExitStatus
+	events     *EventBus
+	log        *sessionLog
+	pumpDone   chan struct{}
+	done       chan struct{}
 }
 
 // NewTerminalServer creates a new terminal server
@@ -41,60 +96,177 @@ func NewTerminalServerWithConfig(config ServerConfig) Server {
 	if config.DefaultShell == "" {
 		config.DefaultShell = "/bin/bash"
 	}
+	if config.ScrollbackSize == 0 {
+		config.ScrollbackSize = DefaultScrollbackSize
+	}
+	if config.ResizePolicy == "" {
+		config.ResizePolicy = DefaultResizePolicy
+	}
+	if config.EndedSessions == 0 {
+		config.EndedSessions = DefaultEndedSessions
+	}
+	if config.StopGracePeriod == 0 {
+		config.StopGracePeriod = DefaultStopGracePeriod
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	return &terminalServer{
 		sessions: make(map[string]*ptySession),
+		starting: make(map[string]*ptySession),
 		ctx:      ctx,
 		cancel:   cancel,
 		config:   config,
+		events:   NewEventBus(),
 	}
 }
 
 // CreateSession creates a new PTY session
 func (ts *terminalServer) CreateSession(sessionID, shell string, args []string) (Session, error) {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-
-	// Check if session already exists
-	if _, exists := ts.sessions[sessionID]; exists {
-		return nil, fmt.Errorf("session %s already exists", sessionID)
-	}
+	return ts.CreateSessionWithConfig(SessionConfig{
+		ID:    sessionID,
+		Shell: shell,
+		Args:  args,
+	})
+}
 
+// CreateSessionWithConfig creates a new PTY session from a full session configuration
+func (ts *terminalServer) CreateSessionWithConfig(config SessionConfig) (Session, error) {
 	// Use default shell if not provided
+	shell := config.Shell
 	if shell == "" {
 		shell = os.Getenv("SHELL")
 		if shell == "" {
-			shell = "/bin/bash"
+			shell = ts.config.DefaultShell
 		}
 	}
 
-	// Create command
-	cmd := exec.Command(shell, args...)
-	ptyMaster, err := pty.Start(cmd)
+	dispatch, err := newDispatcher(config.Dispatch)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	expect, err := compileExpectRules(config.Expect)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	stepConfigs := config.Steps
+	if len(stepConfigs) == 0 {
+		stepConfigs = CommandSteps(config.Commands)
+	}
+	steps, err := compileSteps(stepConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	health, err := newHealthChecker(config.Health)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	restart, err := newRestartConfig(config.Restart)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	env := sessionEnviron(config.Env)
+	ssh, err := newSSHTarget(config.SSH, env)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start PTY: %w", err)
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	forwards, err := newForwarders(config.Forwards)
+	if err != nil {
+		return nil, fmt.Errorf("session %s: %w", config.ID, err)
+	}
+	if len(forwards) > 0 && ssh == nil {
+		return nil, fmt.Errorf("session %s: forwards need an ssh connection", config.ID)
+	}
+
+	// Open the output log first so a misconfigured log fails the session before it starts
+	var log *sessionLog
+	if config.Log != nil {
+		if ts.config.LogDir == "" {
+			return nil, fmt.Errorf("session %s wants an output log but the server has no log directory", config.ID)
+		}
+		if log, err = openSessionLog(LogDir(ts.config.LogDir, config.Target), config.ID, *config.Log, ts.isRunning); err != nil {
+			return nil, err
+		}
 	}
 
-	// Set default terminal size
-	if err := pty.Setsize(ptyMaster, &pty.Winsize{
-		Rows: 24,
-		Cols: 80,
-	}); err != nil {
-		fmt.Printf("Warning: failed to set PTY size: %v\n", err)
+	scrollbackSize := config.ScrollbackSize
+	if scrollbackSize == 0 {
+		scrollbackSize = ts.config.ScrollbackSize
+	}
+	policy := config.ResizePolicy
+	if policy == "" {
+		policy = ts.config.ResizePolicy
+	}
+	grace := config.StopGracePeriod
+	if grace == 0 {
+		grace = ts.config.StopGracePeriod
 	}
 
 	ctx, cancel := context.WithCancel(ts.ctx)
 	session := &ptySession{
-		id:        sessionID,
-		cmd:       cmd,
-		ptyMaster: ptyMaster,
-		ctx:       ctx,
-		cancel:    cancel,
-		startTime: time.Now(),
+		id:         config.ID,
+		target:     config.Target,
+		shell:      shell,
+		args:       config.Args,
+		cmdEnv:     config.Env,
+		steps:      steps,
+		dispatch:   dispatch,
+		checker:    health,
+		restart:    restart,
+		ssh:        ssh,
+		forwards:   forwards,
+		ctx:        ctx,
+		cancel:     cancel,
+		startTime:  time.Now(),
+		outputs:    make(map[string]io.Writer),
+		clients:    make(map[string]*attachedClient),
+		scrollback: newRingBuffer(scrollbackSize),
+		screen:     NewScreen(24, 80),
+		transcript: newTranscript(transcriptSize),
+		probed:     health != nil,
+		stopReq:    make(chan struct{}),
+		expect:     expect,
+		env:        env,
+		dir:        config.Dir,
+		outputWake: make(chan struct{}),
+		rows:       24,
+		cols:       80,
+		policy:     policy,
+		grace:      grace,
+		events:     ts.events,
+		log:        log,
+		done:       make(chan struct{}),
+	}
+
+	if err := ts.reserveSession(session); err != nil {
+		cancel()
+		if log != nil {
+			log.Close()
+		}
+		return nil, err
+	}
+
+	// Connecting may take a while over SSH, so it runs without ts.mu
+	err = session.applyForwardVars()
+	if err == nil {
+		err = session.startRun()
 	}
 
-	ts.sessions[sessionID] = session
+	ts.mu.Lock()
+	delete(ts.starting, config.ID)
+	if err != nil {
+		ts.mu.Unlock()
+		cancel()
+		closeForwards(forwards)
+		if log != nil {
+			log.Close()
+		}
+		return nil, err
+	}
+	ts.sessions[config.ID] = session
+	ts.mu.Unlock()
+
+	session.serveLocalForwards()
+	session.publish(Event{Type: EventStarted})
 
 	// Start session management
 	go ts.manageSession(session)
@@ -102,31 +274,164 @@ func (ts *terminalServer) CreateSession(sessionID, shell string, args []string)
 	return session, nil
 }
 
-// KillSession terminates a session
-func (ts *terminalServer) KillSession(sessionID string) error {
+// reserveSession claims a new session's ID and the local ports of its forwards until its first run
+// has started or failed. An ended session's ID is free, as a quick restart reuses it.
+func (ts *terminalServer) reserveSession(session *ptySession) error {
 	ts.mu.Lock()
 	defer ts.mu.Unlock()
 
+	if _, starting := ts.starting[session.id]; starting {
+		return fmt.Errorf("session %s already exists", session.id)
+	}
+	if existing, exists := ts.sessions[session.id]; exists && existing.GetExitStatus() == nil {
+		return fmt.Errorf("session %s already exists", session.id)
+	}
+	if err := ts.openForwards(session.id, session.forwards); err != nil {
+		return fmt.Errorf("session %s: %w", session.id, err)
+	}
+	ts.starting[session.id] = session
+	return nil
+}
+
+// isRunning reports whether a session is starting or running
+func (ts *terminalServer) isRunning(sessionID string) bool {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+
+	if _, starting := ts.starting[sessionID]; starting {
+		return true
+	}
 	session, exists := ts.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session %s not found", sessionID)
+	return exists && session.GetExitStatus() == nil
+}
+
+// sessionEnviron is the environment a session's shell and helper commands run with
+func sessionEnviron(env []string) []string {
+	cmd := exec.Command("/bin/sh")
+	if len(env) > 0 {
+		cmd.Env = env
 	}
+	return cmd.Environ()
+}
 
-	// Cancel session context
-	session.cancel()
+// environValue returns the value of name in env, or empty if it is not set
+func environValue(env []string, name string) string {
+	for _, entry := range env {
+		if key, value, ok := strings.Cut(entry, "="); ok && key == name {
+			return value
+		}
+	}
+	return ""
+}
 
-	// Close PTY and kill process
-	if session.ptyMaster != nil {
-		session.ptyMaster.Close()
+// startRun starts the session's shell on a fresh PTY, sized like the last one, together with
+// the goroutines that type its startup steps, answer its prompts and probe its health
+func (s *ptySession) startRun() error {
+	s.mu.RLock()
+	stopping, rows, cols := s.stopping, s.rows, s.cols
+	s.mu.RUnlock()
+	if stopping {
+		return errSessionStopping
 	}
-	if session.cmd != nil && session.cmd.Process != nil {
-		session.cmd.Process.Kill()
+
+	// Each run gets fresh expect counts, shared by an SSH login and the shell's own prompts
+	rules := freshExpectRules(s.expect)
+
+	// Connecting to an SSH server can take a while, so the session stays usable meanwhile
+	proc, err := s.startProcess(rows, cols, rules)
+	if err != nil {
+		return err
 	}
 
-	delete(ts.sessions, sessionID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopping {
+		proc.Signal(syscall.SIGKILL)
+		proc.Close()
+		go proc.Wait()
+		return errSessionStopping
+	}
+	if s.rows != rows || s.cols != cols {
+		_ = proc.Resize(s.rows, s.cols)
+	}
+
+	runCtx, runCancel := context.WithCancel(s.ctx)
+	s.proc = proc
+	s.pid.Store(int64(proc.PID()))
+	s.runCtx, s.runCancel = runCtx, runCancel
+	s.runStart = time.Now()
+	s.runDone = make(chan struct{})
+	s.pumpDone = make(chan struct{})
+	s.stepsDone = make(chan struct{})
+	s.stopStep, s.failure = "", ""
+	s.checkpoint = ""
+	s.health, s.healthErr = HealthStarting, ""
+	s.restarting = false
+
+	// Each run gets fresh probe state, looking only at its own output
+	mark := s.transcript.Offset()
+	start := runOrigin{seq: s.outputSeq, at: s.runStart, mark: mark, stepsDone: s.stepsDone}
+	go s.pumpOutput(proc, s.pumpDone)
+	go s.runSteps(runCtx, s.steps, s.dispatch, start)
+	if len(rules) > 0 {
+		go s.watchExpect(runCtx, rules)
+	}
+	if s.checker != nil {
+		go s.watchHealth(runCtx, s.checker.forRun(mark))
+	}
 	return nil
 }
 
+// runOrigin is where a run's output began, for its startup steps
+type runOrigin struct {
+	seq       uint64
+	at        time.Time
+	mark      int64
+	stepsDone chan struct{}
+}
+
+// startProcess starts the session's shell locally or, for an SSH session, on the remote host,
+// answering its login prompts with the run's expect rules
+func (s *ptySession) startProcess(rows, cols uint16, rules []*expectRule) (process, error) {
+	if s.ssh != nil {
+		var answers loginAnswers
+		if len(rules) > 0 {
+			answers = expectLogin{session: s, rules: rules}
+		}
+		proc, err := startSSHProcess(s.ssh, s.env, rows, cols, answers)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.listenRemote(proc); err != nil {
+			proc.Close()
+			return nil, err
+		}
+		return proc, nil
+	}
+	return startLocalProcess(s.shell, s.args, s.cmdEnv, s.dir, rows, cols)
+}
+
+// KillSession terminates a session using its grace period
+func (ts *terminalServer) KillSession(sessionID string) error {
+	_, err := ts.StopSession(sessionID, 0)
+	return err
+}
+
+// StopSession sends SIGHUP and SIGTERM to the session's process group, waits up to grace
+// (zero uses the session's grace period) and then sends SIGKILL.
+// The session stays listed with its exit status once manageSession has reaped it.
+func (ts *terminalServer) StopSession(sessionID string, grace time.Duration) (*StopResult, error) {
+	ts.mu.RLock()
+	session, exists := ts.sessions[sessionID]
+	ts.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("session %s not found", sessionID)
+	}
+
+	return session.stop(grace), nil
+}
+
 // ResizeSession updates the terminal size for a session
 func (ts *terminalServer) ResizeSession(sessionID string, rows, cols uint16) error {
 	ts.mu.RLock()
@@ -137,14 +442,14 @@ func (ts *terminalServer) ResizeSession(sessionID string, rows, cols uint16) err
 		return fmt.Errorf("session %s not found", sessionID)
 	}
 
-	if session.ptyMaster == nil {
-		return fmt.Errorf("session %s has no active PTY", sessionID)
-	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.resizeLocked(rows, cols)
+}
 
-	return pty.Setsize(session.ptyMaster, &pty.Winsize{
-		Rows: rows,
-		Cols: cols,
-	})
+// Events returns the bus on which session lifecycle events are published
+func (ts *terminalServer) Events() *EventBus {
+	return ts.events
 }
 
 // GetSession returns session information
@@ -175,54 +480,212 @@ func (ts *terminalServer) ListSessions() map[string]Session {
 
 // Shutdown gracefully shuts down the server
 func (ts *terminalServer) Shutdown() error {
-	ts.cancel()
-
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
+	ts.mu.RLock()
+	sessions := make([]*ptySession, 0, len(ts.sessions))
+	for _, session := range ts.sessions {
+		sessions = append(sessions, session)
+	}
+	ts.mu.RUnlock()
 
-	// Kill all sessions
-	for sessionID := range ts.sessions {
-		if err := ts.KillSession(sessionID); err != nil {
-			fmt.Printf("Warning: failed to kill session %s: %v\n", sessionID, err)
-		}
+	// Stop all sessions still running, in parallel so each gets its full grace period
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *ptySession) {
+			defer wg.Done()
+			session.stop(0)
+		}(session)
 	}
+	wg.Wait()
 
+	ts.cancel()
 	return nil
 }
 
-// manageSession handles the lifecycle of a PTY session
+// manageSession runs a session until it ends for good, restarting its process as its restart
+// policy says, and then records how it ended
 func (ts *terminalServer) manageSession(session *ptySession) {
-	defer func() {
-		// Cleanup on session end
-		if session.ptyMaster != nil {
-			session.ptyMaster.Close()
+	var status *ExitStatus
+	for {
+		status = session.waitRun()
+		if !session.restartAfter(status) {
+			break
 		}
-		if session.cmd != nil && session.cmd.Process != nil {
-			session.cmd.Process.Kill()
+		if err := session.startRun(); err != nil {
+			if !errors.Is(err, errSessionStopping) {
+				status.Error = fmt.Sprintf("restart failed: %v", err)
+			}
+			break
 		}
-	}()
+		session.publish(Event{Type: EventStarted})
+	}
+
+	// Cleanup on session end
+	session.cancel()
+	closeForwards(session.forwards)
+	if session.log != nil {
+		if err := session.log.Close(); err != nil {
+			fmt.Printf("Warning: failed to close log of session %s: %v\n", session.id, err)
+		}
+	}
+
+	session.mu.Lock()
+	session.exitStatus = status
+	session.mu.Unlock()
+	close(session.done)
+	session.publish(Event{Type: EventExited, Exit: status})
+
+	switch {
+	case status.Reason == EndReasonFailed:
+		fmt.Printf("Session %s failed: %s\n", session.id, status.Error)
+	case status.Stop != "":
+		fmt.Printf("Session %s stopped at the %s step (status %d)\n", session.id, status.Stop, status.Code)
+	case status.Signal != "":
+		fmt.Printf("Session %s %s by %s\n", session.id, status.Reason, status.Signal)
+	case status.Code != 0:
+		fmt.Printf("Session %s %s with status %d\n", session.id, status.Reason, status.Code)
+	default:
+		fmt.Printf("Session %s %s normally\n", session.id, status.Reason)
+	}
+
+	ts.pruneEndedSessions()
+}
+
+// waitRun waits for the session's current process to end, drains its output and returns how it ended
+func (s *ptySession) waitRun() *ExitStatus {
+	s.mu.RLock()
+	proc, pumpDone, runDone := s.proc, s.pumpDone, s.runDone
+	runCtx, runCancel := s.runCtx, s.runCancel
+	s.mu.RUnlock()
 
 	// Wait for command to finish or context cancellation
 	cmdDone := make(chan error, 1)
 	go func() {
-		cmdDone <- session.cmd.Wait()
+		cmdDone <- proc.Wait()
 	}()
 
+	reason := EndReasonExited
+	var err error
 	select {
-	case err := <-cmdDone:
-		if err != nil {
-			fmt.Printf("Session %s ended with error: %v\n", session.id, err)
-		} else {
-			fmt.Printf("Session %s ended normally\n", session.id)
+	case err = <-cmdDone:
+	case <-runCtx.Done():
+		reason = EndReasonCancelled
+		proc.Close()
+		s.signalGroup(syscall.SIGKILL)
+		err = <-cmdDone
+	}
+
+	// Let the pump drain what the process printed last, unless a leftover child keeps the PTY open
+	select {
+	case <-pumpDone:
+	case <-time.After(outputDrainTimeout):
+	}
+	proc.Close()
+	<-pumpDone
+
+	// The run's startup steps, expect rules and probes end with it
+	runCancel()
+
+	s.mu.Lock()
+	if s.stopStep != "" {
+		reason = EndReasonKilled
+	}
+	if s.failure != "" {
+		reason = EndReasonFailed
+	}
+	status := newExitStatus(reason, err)
+	status.Stop = s.stopStep
+	if s.failure != "" {
+		status.Error = s.failure
+	}
+	s.restarting = true
+	s.mu.Unlock()
+	close(runDone)
+
+	return status
+}
+
+// restartAfter decides whether the session's process is started again after it ended with status
+// and waits out the backoff if so. It returns false once the session is over.
+func (s *ptySession) restartAfter(status *ExitStatus) bool {
+	if s.restart == nil || s.ctx.Err() != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	if s.stopping || !s.restart.applies(status) {
+		s.mu.Unlock()
+		return false
+	}
+	// A process that ran for a while was not part of a crash loop, so the backoff starts over
+	if time.Since(s.runStart) >= restartResetAfter {
+		s.attempts = 0
+	}
+	if s.restart.MaxAttempts > 0 && s.attempts >= s.restart.MaxAttempts {
+		giveUp := fmt.Sprintf("gave up after %d restart attempts", s.attempts)
+		if status.Error != "" {
+			giveUp = status.Error + "; " + giveUp
 		}
-	case <-session.ctx.Done():
-		fmt.Printf("Session %s cancelled\n", session.id)
+		status.Error = giveUp
+		s.emitLocked([]byte(fmt.Sprintf("\r\n[hama-shell] %s\r\n", giveUp)))
+		s.mu.Unlock()
+		return false
+	}
+	s.attempts++
+	s.restarts++
+	attempt := s.restarts
+	delay := s.restart.delay(s.attempts)
+	s.emitLocked([]byte(fmt.Sprintf("\r\n[hama-shell] %s; restarting in %s (restart %d)\r\n", describeExit(status), delay, attempt)))
+	s.mu.Unlock()
+
+	fmt.Printf("Session %s %s; restarting in %s (restart %d)\n", s.id, describeExit(status), delay, attempt)
+	s.publish(Event{Type: EventRestarting, Exit: status, Restarts: attempt, Delay: delay})
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-s.stopReq:
+		status.Reason, status.Stop = EndReasonKilled, StopStepTerminate
+	case <-s.ctx.Done():
+		status.Reason = EndReasonCancelled
+	}
+	status.EndTime = time.Now()
+	return false
+}
+
+// describeExit says how a process ended in a few words
+func describeExit(status *ExitStatus) string {
+	switch {
+	case status.Reason == EndReasonFailed:
+		return "failed: " + status.Error
+	case status.Signal != "":
+		return fmt.Sprintf("%s by %s", status.Reason, status.Signal)
+	default:
+		return fmt.Sprintf("%s with status %d", status.Reason, status.Code)
 	}
+}
 
-	// Clean up session from server
+// pruneEndedSessions forgets the oldest ended sessions beyond the configured limit
+func (ts *terminalServer) pruneEndedSessions() {
 	ts.mu.Lock()
-	delete(ts.sessions, session.id)
-	ts.mu.Unlock()
+	defer ts.mu.Unlock()
+
+	var ended []*ptySession
+	for _, session := range ts.sessions {
+		if session.GetExitStatus() != nil {
+			ended = append(ended, session)
+		}
+	}
+	if len(ended) <= ts.config.EndedSessions {
+		return
+	}
+
+	sort.Slice(ended, func(i, j int) bool {
+		return ended[i].GetExitStatus().EndTime.Before(ended[j].GetExitStatus().EndTime)
+	})
+	for _, session := range ended[:len(ended)-ts.config.EndedSessions] {
+		delete(ts.sessions, session.id)
+	}
 }
 
 // Session interface implementation for ptySession
@@ -239,29 +702,382 @@ func (s *ptySession) GetStartTime() time.Time {
 
 // GetPID returns the process ID if available
 func (s *ptySession) GetPID() int {
-	if s.cmd != nil && s.cmd.Process != nil {
-		return s.cmd.Process.Pid
-	}
-	return 0
+	return int(s.pid.Load())
 }
 
 // IsRunning returns true if the session is currently running
 func (s *ptySession) IsRunning() bool {
-	return s.cmd != nil && s.cmd.ProcessState == nil
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Done returns a channel that is closed once the session has ended and its output is drained
+func (s *ptySession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the session ends; it returns an *ExitError unless the process exited with status 0
+func (s *ptySession) Wait() error {
+	<-s.done
+
+	status := s.GetExitStatus()
+	if status.Code == 0 && status.Reason == EndReasonExited {
+		return nil
+	}
+	return &ExitError{SessionID: s.id, Status: status}
+}
+
+// stop ends the session's process group: SIGHUP and SIGTERM first, SIGKILL once grace runs out
+func (s *ptySession) stop(grace time.Duration) *StopResult {
+	if grace == 0 {
+		grace = s.grace
+	}
+	result := &StopResult{SessionID: s.id}
+	start := time.Now()
+
+	s.mu.Lock()
+	if s.exitStatus != nil {
+		s.mu.Unlock()
+		result.Step = StopStepAlreadyEnded
+		result.Exit = s.GetExitStatus()
+		return result
+	}
+	if s.stopStep == "" {
+		s.stopStep = StopStepTerminate
+	}
+	if !s.stopping {
+		s.stopping = true
+		close(s.stopReq)
+	}
+	restarting := s.restarting
+	s.mu.Unlock()
+
+	// SIGHUP is what a shell expects when its terminal goes away; interactive shells pass it on
+	// to their background jobs, which run in process groups of their own.
+	// Between restarts there is no process to signal.
+	if !restarting {
+		s.signalGroup(syscall.SIGHUP)
+		s.signalGroup(syscall.SIGTERM)
+	}
+
+	result.Step = StopStepTerminate
+	select {
+	case <-s.done:
+	case <-time.After(grace):
+		result.Step = StopStepKill
+		s.mu.Lock()
+		s.stopStep = StopStepKill
+		s.mu.Unlock()
+		s.signalGroup(syscall.SIGKILL)
+		// Cancelling makes manageSession close the PTY even if something still holds it open
+		s.cancel()
+		<-s.done
+	}
+
+	result.Elapsed = time.Since(start)
+	result.Exit = s.GetExitStatus()
+	return result
+}
+
+// terminateRun ends the session's current process the way stop does but leaves the session to
+// its restart policy, and returns once the process has been reaped
+func (s *ptySession) terminateRun(grace time.Duration) {
+	s.mu.Lock()
+	if s.restarting {
+		s.mu.Unlock()
+		return
+	}
+	if s.stopStep == "" {
+		s.stopStep = StopStepTerminate
+	}
+	runDone, runCancel := s.runDone, s.runCancel
+	s.mu.Unlock()
+
+	s.signalGroup(syscall.SIGHUP)
+	s.signalGroup(syscall.SIGTERM)
+
+	select {
+	case <-runDone:
+	case <-time.After(grace):
+		s.mu.Lock()
+		s.stopStep = StopStepKill
+		s.mu.Unlock()
+		s.signalGroup(syscall.SIGKILL)
+		runCancel()
+		<-runDone
+	}
+}
+
+// signalGroup sends sig to everything the session's current process started
+func (s *ptySession) signalGroup(sig syscall.Signal) {
+	s.mu.RLock()
+	proc := s.proc
+	s.mu.RUnlock()
+
+	if proc != nil {
+		proc.Signal(sig)
+	}
+}
+
+// GetExitStatus returns how the session ended, or nil while it is running
+func (s *ptySession) GetExitStatus() *ExitStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.exitStatus
 }
 
 // WriteInput writes data to the session's PTY
 func (s *ptySession) WriteInput(data []byte) error {
-	if s.ptyMaster == nil {
+	s.mu.RLock()
+	proc := s.proc
+	s.mu.RUnlock()
+
+	if proc == nil {
 		return fmt.Errorf("session has no active PTY")
 	}
-	_, err := s.ptyMaster.Write(data)
+	_, err := proc.Write(data)
 	return err
 }
 
-// GetPTYMaster returns the PTY master file for direct I/O operations
+// GetPTYMaster returns the PTY master file for direct I/O operations; remote sessions have none
 func (s *ptySession) GetPTYMaster() *os.File {
-	return s.ptyMaster
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.proc == nil {
+		return nil
+	}
+	return s.proc.File()
+}
+
+// AddOutput registers a writer that receives everything the PTY prints
+func (s *ptySession) AddOutput(id string, w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outputs[id] = w
+}
+
+// AddClient attaches a client; it receives the scrollback unless skipped, then live output and resize events.
+// Holding the lock while queueing the backlog guarantees nothing is lost or repeated in between.
+func (s *ptySession) AddClient(config ClientConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.clients[config.ClientID]; exists {
+		return fmt.Errorf("client %s already attached to session %s", config.ClientID, s.id)
+	}
+	if !config.ReadOnly {
+		for _, existing := range s.clients {
+			if !existing.info.ReadOnly {
+				return fmt.Errorf("session %s already has a read-write client (%s); attach read-only to watch it", s.id, existing.info.ID)
+			}
+		}
+	}
+
+	client := newAttachedClient(config)
+	if backlog := s.scrollback.Bytes(); len(backlog) > 0 && !config.SkipScrollback {
+		client.enqueue(clientEvent{data: backlog})
+	}
+	client.enqueue(clientEvent{rows: s.rows, cols: s.cols})
+
+	s.clients[config.ClientID] = client
+	go client.run()
+	s.publish(Event{Type: EventAttached, ClientID: config.ClientID, ReadOnly: config.ReadOnly})
+
+	return nil
+}
+
+// RemoveClient detaches a client once its pending output has been delivered
+func (s *ptySession) RemoveClient(clientID string) {
+	s.mu.Lock()
+	client, exists := s.clients[clientID]
+	if exists {
+		delete(s.clients, clientID)
+		client.close()
+		s.publish(Event{Type: EventDetached, ClientID: clientID, ReadOnly: client.info.ReadOnly})
+		// The departing client may have been the one constraining the size
+		s.applyPolicyLocked()
+	}
+	s.mu.Unlock()
+
+	if exists {
+		<-client.done
+	}
+}
+
+// GetClients returns the clients currently attached to the session
+func (s *ptySession) GetClients() []ClientInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]ClientInfo, 0, len(s.clients))
+	for _, client := range s.clients {
+		result = append(result, client.info)
+	}
+	return result
+}
+
+// SetClientSize records a client's terminal size and resizes the PTY according to the session's policy
+func (s *ptySession) SetClientSize(clientID string, rows, cols uint16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[clientID]
+	if !exists {
+		return fmt.Errorf("client %s not attached to session %s", clientID, s.id)
+	}
+
+	client.rows, client.cols = rows, cols
+	client.info.Rows, client.info.Cols = rows, cols
+	client.lastActive = time.Now()
+	return s.applyPolicyLocked()
+}
+
+// MarkClientActive records that a client just typed, for the "latest" resize policy
+func (s *ptySession) MarkClientActive(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[clientID]
+	if !exists {
+		return
+	}
+
+	client.lastActive = time.Now()
+	if s.policy == ResizeLatest {
+		_ = s.applyPolicyLocked()
+	}
+}
+
+// GetResizePolicy returns the policy used when several clients are attached
+func (s *ptySession) GetResizePolicy() ResizePolicy {
+	return s.policy
+}
+
+// applyPolicyLocked resizes the PTY to what the policy picks from the attached clients.
+// The caller must hold s.mu.
+func (s *ptySession) applyPolicyLocked() error {
+	rows, cols, ok := s.policy.computeSize(s.clients)
+	if !ok || (rows == s.rows && cols == s.cols) {
+		return nil
+	}
+	return s.resizeLocked(rows, cols)
+}
+
+// resizeLocked sets the PTY size and tells every attached client about it.
+// The caller must hold s.mu.
+func (s *ptySession) resizeLocked(rows, cols uint16) error {
+	if s.proc == nil {
+		return fmt.Errorf("session %s has no active PTY", s.id)
+	}
+
+	if err := s.proc.Resize(rows, cols); err != nil {
+		return err
+	}
+
+	s.rows, s.cols = rows, cols
+	s.screen.Resize(int(rows), int(cols))
+	for _, client := range s.clients {
+		s.deliverLocked(client, clientEvent{rows: rows, cols: cols})
+	}
+	s.publish(Event{Type: EventResized, Rows: rows, Cols: cols})
+	return nil
+}
+
+// deliverLocked queues an event for a client, dropping the client if it fell too far behind;
+// it no longer counts as attached and is told to disconnect. The caller must hold s.mu.
+func (s *ptySession) deliverLocked(client *attachedClient, event clientEvent) {
+	if !client.enqueue(event) {
+		fmt.Printf("Session %s: dropping client %s, it is not keeping up with output\n", s.id, client.info.ID)
+		delete(s.clients, client.info.ID)
+		client.drop()
+		s.publish(Event{Type: EventDetached, ClientID: client.info.ID, ReadOnly: client.info.ReadOnly})
+		if client.onDrop != nil {
+			go client.onDrop("client too slow")
+		}
+	}
+}
+
+// GetScrollback returns a copy of the session's recent output
+func (s *ptySession) GetScrollback() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scrollback.Bytes()
+}
+
+// GetScreen renders what the session's terminal currently shows
+func (s *ptySession) GetScreen(ansi bool) *ScreenSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.screen.Snapshot(ansi)
+}
+
+// RemoveOutput unregisters a writer previously added with AddOutput
+func (s *ptySession) RemoveOutput(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.outputs, id)
+}
+
+// pumpOutput drains the PTY into the scrollback and screen and copies its output to every writer and client.
+// The PTY is read even when nobody is attached so the child never blocks on a full buffer.
+func (s *ptySession) pumpOutput(proc process, done chan struct{}) {
+	defer close(done)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := proc.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			s.emitLocked(buf[:n])
+			_, _ = s.transcript.Write(buf[:n])
+			s.outputSeq++
+			s.outputAt = time.Now()
+			close(s.outputWake)
+			s.outputWake = make(chan struct{})
+			s.mu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// emitLocked records output in the scrollback, screen and log and copies it to every writer and client.
+// The caller must hold s.mu.
+func (s *ptySession) emitLocked(data []byte) {
+	_, _ = s.scrollback.Write(data)
+	_, _ = s.screen.Write(data)
+	if s.log != nil {
+		if _, err := s.log.Write(data); err != nil {
+			fmt.Printf("Warning: session %s stopped logging: %v\n", s.id, err)
+			s.log.Close()
+			s.log = nil
+		}
+	}
+	for id, w := range s.outputs {
+		if _, werr := w.Write(data); werr != nil {
+			delete(s.outputs, id)
+		}
+	}
+	if len(s.clients) > 0 {
+		chunk := append([]byte(nil), data...)
+		for _, client := range s.clients {
+			s.deliverLocked(client, clientEvent{data: chunk})
+		}
+	}
+}
+
+// publish stamps an event with the session's identity and sends it on the server's bus
+func (s *ptySession) publish(event Event) {
+	event.SessionID = s.id
+	event.Target = s.target
+	event.PID = s.GetPID()
+	s.events.Publish(event)
 }
 
 // GetInfo returns session information as a map
@@ -271,9 +1087,37 @@ func (s *ptySession) GetInfo() map[string]interface{} {
 
 	info := map[string]interface{}{
 		"id":         s.id,
+		"target":     s.target,
+		"command":    s.proc.Describe(),
 		"start_time": s.startTime,
 		"running":    s.IsRunning(),
+		"clients":    len(s.clients),
+		"rows":       s.rows,
+		"cols":       s.cols,
+		"policy":     string(s.policy),
+		"grace":      s.grace,
 		"pid":        s.GetPID(),
+		"checkpoint": s.checkpoint,
+		"health":     string(s.health),
+		"restarts":   s.restarts,
+		"restarting": s.restarting && s.exitStatus == nil,
+	}
+	if s.healthErr != "" {
+		info["health_error"] = s.healthErr
+	}
+	if len(s.forwards) > 0 {
+		forwards := make([]ForwardInfo, len(s.forwards))
+		for i, f := range s.forwards {
+			forwards[i] = f.info()
+		}
+		info["forwards"] = forwards
+	}
+
+	if s.exitStatus != nil {
+		info["exit_code"] = s.exitStatus.Code
+		info["signal"] = s.exitStatus.Signal
+		info["end_time"] = s.exitStatus.EndTime
+		info["end_reason"] = string(s.exitStatus.Reason)
 	}
 
 	return info
@@ -0,0 +1,189 @@
+package terminal
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultSSHPort is the port an SSH stage connects to when it names none
+const DefaultSSHPort = 22
+
+// DefaultSSHConnectTimeout bounds connecting to an SSH server and completing the handshake
+const DefaultSSHConnectTimeout = 10 * time.Second
+
+// defaultServerAliveCountMax is how many keepalives may go unanswered before the connection is
+// considered lost, as in OpenSSH
+const defaultServerAliveCountMax = 3
+
+// Host key checking modes, named after OpenSSH's StrictHostKeyChecking values
+const (
+	hostKeyCheckYes       = "yes"
+	hostKeyCheckNo        = "no"
+	hostKeyCheckAcceptNew = "accept-new"
+)
+
+// SSHConfig runs a session's shell on a remote host over SSH instead of a local PTY
+type SSHConfig struct {
+	Host string `json:"host"`
+	// Port is the server's port; zero uses DefaultSSHPort
+	Port int `json:"port,omitempty"`
+	// User is the remote user; empty uses USER from the session's environment or the local user
+	User string `json:"user,omitempty"`
+	// IdentityFile is a private key tried before the ssh-agent and the default ~/.ssh/id_* keys
+	IdentityFile string `json:"identity_file,omitempty"`
+	// Options takes a subset of OpenSSH's client options: StrictHostKeyChecking, UserKnownHostsFile,
+	// ConnectTimeout, ServerAliveInterval and ServerAliveCountMax
+	Options map[string]string `json:"options,omitempty"`
+	// Via lists the jump hosts to tunnel through, in order, each with its own credentials and options;
+	// a jump host cannot have jump hosts of its own
+	Via []SSHConfig `json:"via,omitempty"`
+}
+
+// sshTarget is an SSHConfig with its defaults applied and its options parsed
+type sshTarget struct {
+	host           string
+	port           int
+	user           string
+	identityFile   string
+	hostKeyCheck   string
+	knownHosts     []string
+	connectTimeout time.Duration
+	aliveInterval  time.Duration
+	aliveCountMax  int
+	via            []*sshTarget
+}
+
+// newSSHTarget validates an SSH configuration for a session with environment env;
+// it returns nil when the session runs locally
+func newSSHTarget(config *SSHConfig, env []string) (*sshTarget, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Host == "" {
+		return nil, fmt.Errorf("ssh stage has no host")
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		return nil, fmt.Errorf("invalid ssh port %d", config.Port)
+	}
+
+	target := &sshTarget{
+		host:           config.Host,
+		port:           config.Port,
+		user:           config.User,
+		identityFile:   config.IdentityFile,
+		hostKeyCheck:   hostKeyCheckYes,
+		connectTimeout: DefaultSSHConnectTimeout,
+		aliveCountMax:  defaultServerAliveCountMax,
+	}
+	if target.port == 0 {
+		target.port = DefaultSSHPort
+	}
+	if target.user == "" {
+		target.user = environValue(env, "USER")
+	}
+	if target.user == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("ssh stage has no user and the local user is unknown: %w", err)
+		}
+		target.user = current.Username
+	}
+
+	for name, value := range config.Options {
+		var err error
+		switch strings.ToLower(name) {
+		case "stricthostkeychecking":
+			switch strings.ToLower(value) {
+			case hostKeyCheckYes, hostKeyCheckNo, hostKeyCheckAcceptNew:
+				target.hostKeyCheck = strings.ToLower(value)
+			default:
+				err = fmt.Errorf("want yes, no or accept-new")
+			}
+		case "userknownhostsfile":
+			target.knownHosts = strings.Fields(value)
+		case "connecttimeout":
+			target.connectTimeout, err = parseSSHSeconds(value)
+		case "serveraliveinterval":
+			target.aliveInterval, err = parseSSHSeconds(value)
+		case "serveralivecountmax":
+			target.aliveCountMax, err = strconv.Atoi(value)
+			if err == nil && target.aliveCountMax < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported ssh option %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid ssh option %s %q: %w", name, value, err)
+		}
+	}
+
+	for i := range config.Via {
+		hop := &config.Via[i]
+		if len(hop.Via) > 0 {
+			return nil, fmt.Errorf("jump host %d (%s) cannot have jump hosts of its own", i+1, hop.Host)
+		}
+		jump, err := newSSHTarget(hop, env)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %d: %w", i+1, err)
+		}
+		target.via = append(target.via, jump)
+	}
+	return target, nil
+}
+
+// parseSSHSeconds reads a time option as OpenSSH does, in seconds, also accepting Go durations
+func parseSSHSeconds(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("want a number of seconds or a duration")
+	}
+	return d, nil
+}
+
+// address is the host and port to dial
+func (t *sshTarget) address() string {
+	return net.JoinHostPort(t.host, strconv.Itoa(t.port))
+}
+
+// String names the target as user@host:port
+func (t *sshTarget) String() string {
+	return fmt.Sprintf("%s@%s", t.user, t.address())
+}
+
+// Route names the target and the jump hosts it is reached through
+func (t *sshTarget) Route() string {
+	if len(t.via) == 0 {
+		return t.String()
+	}
+	jumps := make([]string, len(t.via))
+	for i, jump := range t.via {
+		jumps[i] = jump.String()
+	}
+	return fmt.Sprintf("%s via %s", t, strings.Join(jumps, ", "))
+}
+
+// remoteSignals maps the signal names SSH uses (RFC 4254) to the local signals they stand for
+var remoteSignals = map[string]syscall.Signal{
+	"ABRT": syscall.SIGABRT,
+	"ALRM": syscall.SIGALRM,
+	"FPE":  syscall.SIGFPE,
+	"HUP":  syscall.SIGHUP,
+	"ILL":  syscall.SIGILL,
+	"INT":  syscall.SIGINT,
+	"KILL": syscall.SIGKILL,
+	"PIPE": syscall.SIGPIPE,
+	"QUIT": syscall.SIGQUIT,
+	"SEGV": syscall.SIGSEGV,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
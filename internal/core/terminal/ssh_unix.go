@@ -0,0 +1,494 @@
+package terminal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultIdentityFiles are the keys under ~/.ssh tried when they exist, as OpenSSH does
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshProcess is a shell running on a remote PTY over an SSH connection
+type sshProcess struct {
+	target  *sshTarget
+	jumps   []*ssh.Client
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	output  *io.PipeReader
+	outputW *io.PipeWriter
+	agent   net.Conn
+	done    chan struct{}
+
+	agentSigners func() ([]ssh.Signer, error)
+	answers      loginAnswers
+	// loginErr is why the hop being connected could not be given a login answer
+	loginErr error
+
+	mu     sync.Mutex
+	lost   error
+	killed bool
+}
+
+// loginAnswers answers the password and keyboard-interactive prompts of an SSH login
+type loginAnswers interface {
+	// Has reports whether prompt has an answer
+	Has(prompt string) bool
+	// Answer returns the answer to prompt
+	Answer(prompt string) (string, error)
+}
+
+// sshKilled is how a remote process ends when hama-shell drops its connection to kill it
+type sshKilled struct{}
+
+// Error describes the kill
+func (sshKilled) Error() string { return "ssh connection closed to kill the remote shell" }
+
+// ExitStatus is unknown, as the remote side never reported one
+func (sshKilled) ExitStatus() int { return -1 }
+
+// Signal names the signal the connection was dropped for
+func (sshKilled) Signal() string { return "KILL" }
+
+// startSSHProcess connects to target, through its jump hosts in order, and starts a login shell
+// on a remote PTY of the given size. The session's environment supplies HOME, TERM and SSH_AUTH_SOCK.
+// Hosts that ask for a password or keyboard-interactive answers get them from answers, if not nil.
+func startSSHProcess(target *sshTarget, env []string, rows, cols uint16, answers loginAnswers) (*sshProcess, error) {
+	p := &sshProcess{target: target, done: make(chan struct{}), answers: answers}
+	p.openAgent(env)
+
+	var through *ssh.Client
+	for i, jump := range target.via {
+		client, err := p.connect(jump, through, env)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("jump host %d (%s): %w", i+1, jump, err)
+		}
+		p.jumps = append(p.jumps, client)
+		through = client
+	}
+
+	client, err := p.connect(target, through, env)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("%s: %w", target, err)
+	}
+	p.client = client
+
+	if err := p.startShell(env, rows, cols); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if target.aliveInterval > 0 {
+		go p.keepAlive()
+	}
+	return p, nil
+}
+
+// connect reaches hop directly or, given a jump host's client, through it, then authenticates
+// and checks the host key, all within the hop's connect timeout
+func (p *sshProcess) connect(hop *sshTarget, through *ssh.Client, env []string) (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	if through == nil {
+		conn, err = net.DialTimeout("tcp", hop.address(), hop.connectTimeout)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), hop.connectTimeout)
+		conn, err = through.DialContext(ctx, "tcp", hop.address())
+		cancel()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	auth, err := p.authMethods(hop, env)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	hostKeys, err := hop.hostKeyCallback(env)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            hop.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+	}
+
+	// Tunnelled connections take no deadlines, so a stalled handshake is cut off by closing the connection
+	p.loginErr = nil
+	timer := time.AfterFunc(hop.connectTimeout, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, hop.address(), config)
+	if !timer.Stop() {
+		conn.Close()
+		return nil, fmt.Errorf("failed to log in: timed out after %s", hop.connectTimeout)
+	}
+	if err != nil {
+		conn.Close()
+		if p.loginErr != nil && !errors.Is(err, p.loginErr) {
+			return nil, fmt.Errorf("failed to log in: %w; %v", err, p.loginErr)
+		}
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// openAgent connects to the ssh-agent named by SSH_AUTH_SOCK, if it is running, for every hop to use
+func (p *sshProcess) openAgent(env []string) {
+	socket := environValue(env, "SSH_AUTH_SOCK")
+	if socket == "" {
+		return
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		p.agent = conn
+		p.agentSigners = agent.NewClient(conn).Signers
+	}
+}
+
+// authMethods offers hop's identity file, then the ssh-agent's keys, then the default keys, and
+// after them answers to password and keyboard-interactive prompts
+func (p *sshProcess) authMethods(hop *sshTarget, env []string) ([]ssh.AuthMethod, error) {
+	var signers []ssh.Signer
+	if hop.identityFile != "" {
+		signer, err := loadIdentity(expandHome(hop.identityFile, env))
+		if err != nil {
+			return nil, err
+		}
+		signers = append(signers, signer)
+	}
+
+	var defaults []ssh.Signer
+	if hop.identityFile == "" {
+		for _, name := range defaultIdentityFiles {
+			path := filepath.Join(environValue(env, "HOME"), ".ssh", name)
+			// Missing and passphrase-protected default keys are skipped, as the agent may hold them
+			if signer, err := loadIdentity(path); err == nil {
+				defaults = append(defaults, signer)
+			}
+		}
+	}
+
+	// The client tries a single public key method, so every source goes into one callback
+	methods := []ssh.AuthMethod{ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		all := append([]ssh.Signer(nil), signers...)
+		if p.agentSigners != nil {
+			if fromAgent, err := p.agentSigners(); err == nil {
+				all = append(all, fromAgent...)
+			}
+		}
+		return append(all, defaults...), nil
+	})}
+	if p.answers == nil {
+		return methods, nil
+	}
+
+	// Answers go through the encrypted login rather than the terminal, so secrets are never echoed
+	return append(methods,
+		ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+			// Giving up halfway would leave the server waiting for answers, so when one cannot be
+			// answered all are sent blank, failing this method but letting the next be tried
+			blank := make([]string, len(questions))
+			for _, question := range questions {
+				if !p.answers.Has(question) {
+					p.loginErr = fmt.Errorf("no expect rule answers the login prompt %q", question)
+					return blank, nil
+				}
+			}
+			answers := make([]string, len(questions))
+			for i, question := range questions {
+				response, err := p.answers.Answer(question)
+				if err != nil {
+					p.loginErr = err
+					return blank, nil
+				}
+				answers[i] = response
+			}
+			return answers, nil
+		}),
+		// Servers send no prompt for password logins, so one is made up as OpenSSH shows it
+		ssh.PasswordCallback(func() (string, error) {
+			prompt := fmt.Sprintf("%s@%s's password: ", hop.user, hop.host)
+			if !p.answers.Has(prompt) {
+				p.loginErr = fmt.Errorf("no expect rule answers the login prompt %q", prompt)
+				return "", p.loginErr
+			}
+			response, err := p.answers.Answer(prompt)
+			if err != nil {
+				p.loginErr = err
+			}
+			return response, err
+		}),
+	), nil
+}
+
+// loadIdentity reads an unencrypted private key
+func loadIdentity(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var passphraseErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) {
+		return nil, fmt.Errorf("identity file %s is protected by a passphrase; add it to ssh-agent instead", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback checks host keys against the known hosts files as StrictHostKeyChecking says.
+// A changed key is always refused.
+func (t *sshTarget) hostKeyCallback(env []string) (ssh.HostKeyCallback, error) {
+	if t.hostKeyCheck == hostKeyCheckNo {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	var files, existing []string
+	for _, file := range t.knownHosts {
+		files = append(files, expandHome(file, env))
+	}
+	if len(files) == 0 {
+		files = []string{filepath.Join(environValue(env, "HOME"), ".ssh", "known_hosts")}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+
+	check := func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }
+	if len(existing) > 0 {
+		var err error
+		if check, err = knownhosts.New(existing...); err != nil {
+			return nil, fmt.Errorf("failed to read known hosts: %w", err)
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		switch {
+		case err == nil:
+			return nil
+		case !errors.As(err, &keyErr):
+			return err
+		case len(keyErr.Want) > 0:
+			return fmt.Errorf("host key of %s does not match %s; it may have been replaced or the connection intercepted",
+				hostname, keyErr.Want[0].Filename)
+		case t.hostKeyCheck == hostKeyCheckAcceptNew:
+			return addKnownHost(files[0], hostname, key)
+		default:
+			return fmt.Errorf("host key of %s is not in %s; add it or set StrictHostKeyChecking to accept-new",
+				hostname, strings.Join(files, ", "))
+		}
+	}, nil
+}
+
+// addKnownHost records a host's key in a known hosts file, creating the file if needed
+func addKnownHost(file, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create known hosts directory: %w", err)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known hosts file: %w", err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("failed to add host key to %s: %w", file, err)
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~/ with HOME from env
+func expandHome(path string, env []string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(environValue(env, "HOME"), rest)
+	}
+	return path
+}
+
+// startShell opens a session with a PTY and starts the user's login shell in it
+func (p *sshProcess) startShell(env []string, rows, cols uint16) error {
+	session, err := p.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open ssh session: %w", err)
+	}
+	p.session = session
+
+	term := environValue(env, "TERM")
+	if term == "" {
+		term = "xterm-256color"
+	}
+	if err := session.RequestPty(term, int(rows), int(cols), ssh.TerminalModes{}); err != nil {
+		return fmt.Errorf("failed to request remote PTY: %w", err)
+	}
+
+	if p.stdin, err = session.StdinPipe(); err != nil {
+		return fmt.Errorf("failed to open remote input: %w", err)
+	}
+	// With a PTY the remote side sends everything on stdout; stderr is merged in case it does not
+	p.output, p.outputW = io.Pipe()
+	session.Stdout = p.outputW
+	session.Stderr = p.outputW
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start remote shell: %w", err)
+	}
+	return nil
+}
+
+// keepAlive sends a keepalive every ServerAliveInterval and drops the connection once
+// ServerAliveCountMax of them in a row went unanswered
+func (p *sshProcess) keepAlive() {
+	ticker := time.NewTicker(p.target.aliveInterval)
+	defer ticker.Stop()
+
+	replies := make(chan error, 1)
+	pending, missed := false, 0
+	for {
+		select {
+		case <-p.done:
+			return
+		case err := <-replies:
+			pending = false
+			if err == nil {
+				missed = 0
+			}
+		case <-ticker.C:
+			if pending {
+				missed++
+				if missed >= p.target.aliveCountMax {
+					p.mu.Lock()
+					p.lost = fmt.Errorf("%s did not answer %d keepalives", p.target.address(), missed)
+					p.mu.Unlock()
+					p.client.Close()
+					return
+				}
+				continue
+			}
+			pending = true
+			go func() {
+				_, _, err := p.client.SendRequest("keepalive@openssh.com", true, nil)
+				replies <- err
+			}()
+		}
+	}
+}
+
+// Read returns the remote PTY's output
+func (p *sshProcess) Read(b []byte) (int, error) {
+	return p.output.Read(b)
+}
+
+// Write types into the remote PTY
+func (p *sshProcess) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+// Resize sets the remote PTY size
+func (p *sshProcess) Resize(rows, cols uint16) error {
+	return p.session.WindowChange(int(rows), int(cols))
+}
+
+// Wait blocks until the remote shell exits or the connection is lost
+func (p *sshProcess) Wait() error {
+	err := p.session.Wait()
+	close(p.done)
+	p.outputW.Close()
+
+	p.mu.Lock()
+	lost, killed := p.lost, p.killed
+	p.mu.Unlock()
+
+	var missing *ssh.ExitMissingError
+	switch {
+	case err == nil:
+		return nil
+	case killed:
+		return sshKilled{}
+	case lost != nil:
+		return fmt.Errorf("ssh connection lost: %w", lost)
+	case errors.As(err, &missing):
+		return fmt.Errorf("ssh connection to %s closed without an exit status", p.target.address())
+	}
+	return err
+}
+
+// Signal forwards sig to the remote shell. Servers may ignore signal requests, so SIGKILL
+// drops the connection instead, which hangs up the remote session.
+func (p *sshProcess) Signal(sig syscall.Signal) {
+	if sig == syscall.SIGKILL {
+		p.mu.Lock()
+		p.killed = true
+		p.mu.Unlock()
+		p.client.Close()
+		return
+	}
+
+	for name, remote := range remoteSignals {
+		if remote == sig {
+			_ = p.session.Signal(ssh.Signal(name))
+			return
+		}
+	}
+}
+
+// Close drops the connection and those to the jump hosts, ending pending reads
+func (p *sshProcess) Close() error {
+	if p.output != nil {
+		p.output.Close()
+	}
+	if p.agent != nil {
+		p.agent.Close()
+	}
+
+	var err error
+	if p.client != nil {
+		err = p.client.Close()
+	}
+	for i := len(p.jumps) - 1; i >= 0; i-- {
+		p.jumps[i].Close()
+	}
+	return err
+}
+
+// PID is 0, as the shell runs remotely
+func (p *sshProcess) PID() int {
+	return 0
+}
+
+// Describe names the remote target and its jump hosts
+func (p *sshProcess) Describe() string {
+	return "ssh " + p.target.Route()
+}
+
+// EchoEnabled cannot be answered for a remote PTY, whose settings SSH does not report
+func (p *sshProcess) EchoEnabled() (bool, error) {
+	return false, errEchoUnknown
+}
+
+// File is nil, as there is no local PTY
+func (p *sshProcess) File() *os.File {
+	return nil
+}
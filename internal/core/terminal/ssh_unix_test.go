@@ -0,0 +1,634 @@
+package terminal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is an in-process SSH server whose shell reads one command per line: "exit N"
+// exits with status N, "kill NAME" ends with signal NAME and anything else prints "ok".
+// It also tunnels direct-tcpip channels, so it can serve as a jump host.
+type testSSHServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mu       sync.Mutex
+	users    []string
+	tunnels  []string
+	commands []string
+}
+
+// newTestSSHServer starts a server on a free local port that accepts the client key
+func newTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	return newTestSSHServerWithAuth(t, func(s *testSSHServer, config *ssh.ServerConfig) {
+		config.PublicKeyCallback = func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, fmt.Errorf("unknown key for %s", meta.User())
+			}
+			s.loggedIn(meta.User())
+			return nil, nil
+		}
+	})
+}
+
+// newTestSSHServerWithAuth starts a server on a free local port whose login methods auth sets up
+func newTestSSHServerWithAuth(t *testing.T, auth func(*testSSHServer, *ssh.ServerConfig)) *testSSHServer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate host key: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("failed to load host key: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &testSSHServer{listener: listener, hostKey: hostKey}
+	config := &ssh.ServerConfig{}
+	auth(s, config)
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+// port is the port the server listens on
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// knownHostsLine is the server's entry for a known hosts file
+func (s *testSSHServer) knownHostsLine() string {
+	return knownhosts.Line([]string{knownhosts.Normalize(s.listener.Addr().String())}, s.hostKey.PublicKey())
+}
+
+// loggedIn records a user that logged in
+func (s *testSSHServer) loggedIn(user string) {
+	s.mu.Lock()
+	s.users = append(s.users, user)
+	s.mu.Unlock()
+}
+
+// seen returns the users that logged in, the addresses tunnelled to and the commands run
+func (s *testSSHServer) seen() (users, tunnels, commands []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.users...), append([]string(nil), s.tunnels...), append([]string(nil), s.commands...)
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go s.serveSession(channel, requests)
+		case "direct-tcpip":
+			go s.serveTunnel(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *testSSHServer) serveSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "pty-req", "window-change", "env":
+			req.Reply(req.WantReply, nil)
+		case "shell":
+			req.Reply(true, nil)
+			go s.runShell(channel)
+		case "signal":
+			var msg struct{ Signal string }
+			ssh.Unmarshal(req.Payload, &msg)
+			exitSignal(channel, msg.Signal)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// runShell prompts for commands until one ends the shell
+func (s *testSSHServer) runShell(channel ssh.Channel) {
+	io.WriteString(channel, "$ ")
+	var line []byte
+	buf := make([]byte, 256)
+	for {
+		n, err := channel.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			if b != '\r' && b != '\n' {
+				line = append(line, b)
+				continue
+			}
+			command := strings.TrimSpace(string(line))
+			line = line[:0]
+			if command == "" {
+				continue
+			}
+			s.mu.Lock()
+			s.commands = append(s.commands, command)
+			s.mu.Unlock()
+
+			name, arg, _ := strings.Cut(command, " ")
+			switch name {
+			case "exit":
+				code, _ := strconv.Atoi(arg)
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+				channel.Close()
+				return
+			case "kill":
+				exitSignal(channel, arg)
+				return
+			default:
+				io.WriteString(channel, command+"\r\nok\r\n$ ")
+			}
+		}
+	}
+}
+
+// exitSignal ends a session as if its shell was killed by the signal
+func exitSignal(channel ssh.Channel, signal string) {
+	channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+		Signal     string
+		CoreDumped bool
+		Error      string
+		Lang       string
+	}{Signal: signal}))
+	channel.Close()
+}
+
+// serveTunnel connects a direct-tcpip channel to the address it asks for
+func (s *testSSHServer) serveTunnel(newChannel ssh.NewChannel) {
+	var msg struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &msg); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "bad direct-tcpip request")
+		return
+	}
+	address := net.JoinHostPort(msg.Host, strconv.Itoa(int(msg.Port)))
+	s.mu.Lock()
+	s.tunnels = append(s.tunnels, address)
+	s.mu.Unlock()
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, conn)
+		channel.CloseWrite()
+	}()
+	io.Copy(conn, channel)
+	conn.Close()
+}
+
+// sshTestClient is a home directory holding a client key for the test servers to accept
+type sshTestClient struct {
+	home     string
+	identity string
+	key      ssh.PublicKey
+}
+
+func newSSHTestClient(t *testing.T) *sshTestClient {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate client key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("failed to encode client key: %v", err)
+	}
+	home := t.TempDir()
+	identity := filepath.Join(home, "id_test")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write client key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("failed to read client key: %v", err)
+	}
+	return &sshTestClient{home: home, identity: identity, key: key}
+}
+
+// knownHosts is the default known hosts file under the client's home
+func (c *sshTestClient) knownHosts() string {
+	return filepath.Join(c.home, ".ssh", "known_hosts")
+}
+
+// writeKnownHosts writes lines to a known hosts file
+func writeKnownHosts(t *testing.T, file string, lines ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(file), err)
+	}
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", file, err)
+	}
+}
+
+// config is an SSH configuration for server as user, with the given options
+func (c *sshTestClient) config(server *testSSHServer, user string, options map[string]string) SSHConfig {
+	return SSHConfig{
+		Host:         "127.0.0.1",
+		Port:         server.port(),
+		User:         user,
+		IdentityFile: c.identity,
+		Options:      options,
+	}
+}
+
+// run starts a session over SSH that runs commands, and waits for it to end
+func (c *sshTestClient) run(t *testing.T, config SSHConfig, commands ...string) (Session, error) {
+	t.Helper()
+	ts := NewTerminalServer()
+	t.Cleanup(func() { ts.Shutdown() })
+
+	session, err := ts.CreateSessionWithConfig(SessionConfig{
+		ID:       "ssh-test",
+		Env:      []string{"HOME=" + c.home, "USER=nobody", "PATH=/usr/bin:/bin"},
+		Commands: commands,
+		Dispatch: &DispatchConfig{QuietPeriod: -1, StepTimeout: 5 * time.Second},
+		SSH:      &config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-session.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("ssh session running %v did not end", commands)
+	}
+	return session, nil
+}
+
+func TestSSHSessionConnects(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+
+	session, err := client.run(t, client.config(server, "deploy", map[string]string{"StrictHostKeyChecking": "no"}),
+		"echo hello", "exit 0")
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+
+	users, _, commands := server.seen()
+	if strings.Join(users, ",") != "deploy" {
+		t.Errorf("logged in users = %v, want [deploy]", users)
+	}
+	if strings.Join(commands, ",") != "echo hello,exit 0" {
+		t.Errorf("commands = %v, want [echo hello, exit 0]", commands)
+	}
+	info := session.GetInfo()
+	if info["exit_code"] != 0 || info["end_reason"] != string(EndReasonExited) {
+		t.Errorf("exit_code = %v, end_reason = %v, want 0 and %s", info["exit_code"], info["end_reason"], EndReasonExited)
+	}
+	if command, _ := info["command"].(string); !strings.Contains(command, fmt.Sprintf("deploy@127.0.0.1:%d", server.port())) {
+		t.Errorf("command = %q, want it to name the target", command)
+	}
+}
+
+func TestSSHHostKeyChecking(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+	other := newTestSSHServer(t, client.key)
+	// A line for the server's address with another server's key, as if the host key changed
+	changed := knownhosts.Line([]string{knownhosts.Normalize(server.listener.Addr().String())}, other.hostKey.PublicKey())
+	custom := filepath.Join(client.home, "custom_hosts")
+
+	tests := []struct {
+		name       string
+		options    map[string]string
+		knownHosts []string
+		hostsFile  string
+		wantErr    string
+	}{
+		{
+			name:    "strict refuses an unknown host",
+			wantErr: "is not in",
+		},
+		{
+			name:       "strict accepts a known host",
+			knownHosts: []string{server.knownHostsLine()},
+		},
+		{
+			name:    "accept-new adds an unknown host",
+			options: map[string]string{"StrictHostKeyChecking": "accept-new"},
+		},
+		{
+			name:       "accept-new refuses a changed key",
+			options:    map[string]string{"StrictHostKeyChecking": "accept-new"},
+			knownHosts: []string{changed},
+			wantErr:    "does not match",
+		},
+		{
+			name:       "UserKnownHostsFile replaces the default file",
+			options:    map[string]string{"UserKnownHostsFile": custom},
+			knownHosts: []string{server.knownHostsLine()},
+			hostsFile:  custom,
+		},
+		{
+			name:       "UserKnownHostsFile ignores the default file",
+			options:    map[string]string{"UserKnownHostsFile": custom},
+			knownHosts: []string{server.knownHostsLine()},
+			hostsFile:  client.knownHosts(),
+			wantErr:    "is not in " + custom,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(filepath.Join(client.home, ".ssh"))
+			os.Remove(custom)
+			hostsFile := tt.hostsFile
+			if hostsFile == "" {
+				hostsFile = client.knownHosts()
+			}
+			if tt.knownHosts != nil {
+				writeKnownHosts(t, hostsFile, tt.knownHosts...)
+			}
+
+			_, err := client.run(t, client.config(server, "deploy", tt.options), "exit 0")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSessionWithConfig: %v", err)
+			}
+		})
+	}
+
+	// accept-new recorded the key, so strict checking now accepts the host
+	os.RemoveAll(filepath.Join(client.home, ".ssh"))
+	if _, err := client.run(t, client.config(server, "deploy", map[string]string{"StrictHostKeyChecking": "accept-new"}), "exit 0"); err != nil {
+		t.Fatalf("accept-new: %v", err)
+	}
+	if _, err := client.run(t, client.config(server, "deploy", nil), "exit 0"); err != nil {
+		t.Fatalf("strict after accept-new: %v", err)
+	}
+}
+
+func TestSSHExitStatus(t *testing.T) {
+	client := newSSHTestClient(t)
+	server := newTestSSHServer(t, client.key)
+	options := map[string]string{"StrictHostKeyChecking": "no"}
+
+	tests := []struct {
+		command    string
+		wantCode   int
+		wantSignal string
+		wantReason EndReason
+	}{
+		{command: "exit 0", wantCode: 0, wantReason: EndReasonExited},
+		{command: "exit 7", wantCode: 7, wantReason: EndReasonExited},
+		{command: "kill TERM", wantCode: 128 + 15, wantSignal: "SIGTERM", wantReason: EndReasonSignaled},
+		{command: "kill KILL", wantCode: 128 + 9, wantSignal: "SIGKILL", wantReason: EndReasonSignaled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			session, err := client.run(t, client.config(server, "deploy", options), tt.command)
+			if err != nil {
+				t.Fatalf("CreateSessionWithConfig: %v", err)
+			}
+			info := session.GetInfo()
+			if info["exit_code"] != tt.wantCode || info["signal"] != tt.wantSignal || info["end_reason"] != string(tt.wantReason) {
+				t.Errorf("exit_code = %v, signal = %q, end_reason = %v; want %d, %q and %s",
+					info["exit_code"], info["signal"], info["end_reason"], tt.wantCode, tt.wantSignal, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSSHViaJumpHosts(t *testing.T) {
+	client := newSSHTestClient(t)
+	first := newTestSSHServer(t, client.key)
+	second := newTestSSHServer(t, client.key)
+	target := newTestSSHServer(t, client.key)
+	options := map[string]string{"StrictHostKeyChecking": "accept-new"}
+
+	config := client.config(target, "app", options)
+	config.Via = []SSHConfig{
+		client.config(first, "jump1", options),
+		client.config(second, "jump2", options),
+	}
+	session, err := client.run(t, config, "exit 4")
+	if err != nil {
+		t.Fatalf("CreateSessionWithConfig: %v", err)
+	}
+	if info := session.GetInfo(); info["exit_code"] != 4 {
+		t.Errorf("exit_code = %v, want 4", info["exit_code"])
+	}
+
+	// Each hop is logged into as its own user and tunnels to the next
+	for _, hop := range []struct {
+		name       string
+		server     *testSSHServer
+		wantUser   string
+		wantTunnel *testSSHServer
+	}{
+		{"first jump host", first, "jump1", second},
+		{"second jump host", second, "jump2", target},
+		{"target", target, "app", nil},
+	} {
+		users, tunnels, _ := hop.server.seen()
+		if strings.Join(users, ",") != hop.wantUser {
+			t.Errorf("%s: logged in users = %v, want [%s]", hop.name, users, hop.wantUser)
+		}
+		wantTunnels := ""
+		if hop.wantTunnel != nil {
+			wantTunnels = hop.wantTunnel.listener.Addr().String()
+		}
+		if strings.Join(tunnels, ",") != wantTunnels {
+			t.Errorf("%s: tunnels = %v, want [%s]", hop.name, tunnels, wantTunnels)
+		}
+	}
+	if _, _, commands := target.seen(); strings.Join(commands, ",") != "exit 4" {
+		t.Errorf("target commands = %v, want [exit 4]", commands)
+	}
+
+	// All three host keys were recorded under the address each was reached at
+	data, err := os.ReadFile(client.knownHosts())
+	if err != nil {
+		t.Fatalf("failed to read known hosts: %v", err)
+	}
+	for _, server := range []*testSSHServer{first, second, target} {
+		if !strings.Contains(string(data), server.knownHostsLine()) {
+			t.Errorf("known hosts lacks %s", server.listener.Addr())
+		}
+	}
+}
+
+func TestSSHViaUnreachableHop(t *testing.T) {
+	client := newSSHTestClient(t)
+	jump := newTestSSHServer(t, client.key)
+	target := newTestSSHServer(t, client.key)
+	options := map[string]string{"StrictHostKeyChecking": "no"}
+
+	// Nothing listens on the port of a closed listener
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	config := client.config(target, "app", options)
+	config.Via = []SSHConfig{
+		client.config(jump, "jump", options),
+		{Host: "127.0.0.1", Port: closedPort, User: "jump", IdentityFile: client.identity, Options: options},
+	}
+	_, err = client.run(t, config, "exit 0")
+	if err == nil || !strings.Contains(err.Error(), "jump host 2") {
+		t.Fatalf("error = %v, want one naming jump host 2", err)
+	}
+	if users, _, _ := target.seen(); len(users) != 0 {
+		t.Errorf("target was logged into by %v", users)
+	}
+}
+
+func TestSSHLoginPromptsAnsweredByExpectRules(t *testing.T) {
+	client := newSSHTestClient(t)
+	// The server takes no keys; it wants a password, or a PIN and a one-time code
+	server := newTestSSHServerWithAuth(t, func(s *testSSHServer, config *ssh.ServerConfig) {
+		config.PasswordCallback = func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "hunter2" {
+				return nil, fmt.Errorf("wrong password")
+			}
+			s.loggedIn(meta.User() + " by password")
+			return nil, nil
+		}
+		config.KeyboardInteractiveCallback = func(meta ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"PIN: ", "Verification code: "}, []bool{false, false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) != 2 || answers[0] != "1234" || answers[1] != "654321" {
+				return nil, fmt.Errorf("wrong answers")
+			}
+			s.loggedIn(meta.User() + " by code")
+			return nil, nil
+		}
+	})
+	options := map[string]string{"StrictHostKeyChecking": "no"}
+
+	tests := []struct {
+		name     string
+		rules    []ExpectRule
+		wantUser string
+		wantErr  string
+	}{
+		{
+			name: "keyboard-interactive",
+			rules: []ExpectRule{
+				{Pattern: `^PIN: $`, ResponseEnv: "TEST_PIN"},
+				{Pattern: `^Verification code: $`, ResponseCommand: "echo 654321"},
+			},
+			wantUser: "deploy by code",
+		},
+		{
+			name:     "password",
+			rules:    []ExpectRule{{Pattern: `deploy@127\.0\.0\.1's password: $`, ResponseEnv: "TEST_PASSWORD"}},
+			wantUser: "deploy by password",
+		},
+		{
+			name:    "no rule for the prompt",
+			rules:   []ExpectRule{{Pattern: `^Passphrase: $`, Response: "nope"}},
+			wantErr: `no expect rule answers the login prompt "deploy@127.0.0.1's password: "`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _, _ := server.seen()
+			ts := NewTerminalServer()
+			defer ts.Shutdown()
+
+			config := client.config(server, "deploy", options)
+			config.IdentityFile = ""
+			session, err := ts.CreateSessionWithConfig(SessionConfig{
+				ID:       "ssh-login",
+				Env:      []string{"HOME=" + client.home, "PATH=/usr/bin:/bin", "TEST_PIN=1234", "TEST_PASSWORD=hunter2"},
+				Commands: []string{"exit 0"},
+				Dispatch: &DispatchConfig{QuietPeriod: -1, StepTimeout: 5 * time.Second},
+				Expect:   tt.rules,
+				SSH:      &config,
+			})
+			if tt.wantErr != "" {
+				if err == nil || strings.Count(err.Error(), tt.wantErr) != 1 {
+					t.Fatalf("error = %v, want one containing %q once", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSessionWithConfig: %v", err)
+			}
+			<-session.Done()
+
+			users, _, _ := server.seen()
+			if got := strings.Join(users[len(before):], ","); got != tt.wantUser {
+				t.Errorf("logged in = %q, want %q", got, tt.wantUser)
+			}
+			// The secrets went through the login, never through the terminal
+			for _, secret := range []string{"1234", "654321", "hunter2"} {
+				if strings.Contains(string(session.GetScrollback()), secret) {
+					t.Errorf("scrollback contains %q", secret)
+				}
+			}
+		})
+	}
+}
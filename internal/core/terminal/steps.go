@@ -0,0 +1,128 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// StepType names what a startup step does
+type StepType string
+
+// Startup step types
+const (
+	// StepRun types a command into the shell once it is ready for it
+	StepRun StepType = "run"
+	// StepSend types text and keys straight away, without waiting for a prompt
+	StepSend StepType = "send"
+	// StepWaitFor waits until the output since the previous input matches a pattern
+	StepWaitFor StepType = "wait_for"
+	// StepSleep pauses for a fixed time
+	StepSleep StepType = "sleep"
+	// StepCheckpoint records how far the session's startup has got
+	StepCheckpoint StepType = "checkpoint"
+)
+
+// Step is one action of a session's startup
+type Step struct {
+	Type StepType `json:"type"`
+	// Text is the command of a run step, the text of a send step, the pattern of a wait_for step
+	// and the label of a checkpoint
+	Text string `json:"text,omitempty"`
+	// Keys are named keys a send step types after its text, such as "C-c" or "Up,Enter"
+	Keys string `json:"keys,omitempty"`
+	// Duration is how long a sleep step pauses
+	Duration time.Duration `json:"duration,omitempty"`
+	// Timeout overrides the dispatch step timeout for run and wait_for steps
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// CommandSteps turns plain startup commands into run steps
+func CommandSteps(commands []string) []Step {
+	steps := make([]Step, 0, len(commands))
+	for _, command := range commands {
+		steps = append(steps, Step{Type: StepRun, Text: command})
+	}
+	return steps
+}
+
+// String describes the step for progress and error messages
+func (s Step) String() string {
+	switch s.Type {
+	case StepRun:
+		return s.Text
+	case StepSend:
+		if s.Keys == "" {
+			return fmt.Sprintf("send '%s'", s.Text)
+		}
+		if s.Text == "" {
+			return fmt.Sprintf("send %s", s.Keys)
+		}
+		return fmt.Sprintf("send '%s' + %s", s.Text, s.Keys)
+	case StepWaitFor:
+		return fmt.Sprintf("wait_for '%s'", s.Text)
+	case StepSleep:
+		return fmt.Sprintf("sleep %s", s.Duration)
+	case StepCheckpoint:
+		return fmt.Sprintf("checkpoint '%s'", s.Text)
+	default:
+		return string(s.Type)
+	}
+}
+
+// startupStep is a Step with its pattern compiled and its input resolved
+type startupStep struct {
+	Step
+	re    *regexp.Regexp
+	input []byte
+}
+
+// compileSteps validates a session's startup steps
+func compileSteps(steps []Step) ([]*startupStep, error) {
+	compiled := make([]*startupStep, 0, len(steps))
+	for i, step := range steps {
+		c := &startupStep{Step: step}
+		switch step.Type {
+		case StepRun:
+			c.input = []byte(step.Text + "\n")
+		case StepSend:
+			c.input = []byte(step.Text)
+			if step.Keys != "" {
+				keys, err := ParseKeySequence(step.Keys)
+				if err != nil {
+					return nil, fmt.Errorf("step %d: %w", i+1, err)
+				}
+				c.input = append(c.input, keys...)
+			}
+			if len(c.input) == 0 {
+				return nil, fmt.Errorf("step %d sends nothing", i+1)
+			}
+		case StepWaitFor:
+			re, err := regexp.Compile(step.Text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid wait_for pattern %q: %w", step.Text, err)
+			}
+			c.re = re
+		case StepSleep:
+			if step.Duration <= 0 {
+				return nil, fmt.Errorf("step %d sleeps for no time", i+1)
+			}
+		case StepCheckpoint:
+			if step.Text == "" {
+				return nil, fmt.Errorf("step %d has an empty checkpoint label", i+1)
+			}
+		default:
+			return nil, fmt.Errorf("step %d has unknown type %q", i+1, step.Type)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// timeout is how long a run or wait_for step may wait
+func (s *startupStep) timeout(d *dispatcher) time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return d.timeout
+}
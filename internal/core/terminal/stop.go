@@ -0,0 +1,27 @@
+package terminal
+
+import "time"
+
+// DefaultStopGracePeriod is how long a stopped session gets to exit before it is killed
+const DefaultStopGracePeriod = 5 * time.Second
+
+// StopStep names the step of a graceful stop that ended a session
+type StopStep string
+
+// Steps of a graceful stop, in the order they are tried
+const (
+	// StopStepAlreadyEnded means the session had ended before it was stopped
+	StopStepAlreadyEnded StopStep = "already-ended"
+	// StopStepTerminate means the process group exited after SIGHUP and SIGTERM
+	StopStepTerminate StopStep = "terminate"
+	// StopStepKill means the grace period ran out and the process group was sent SIGKILL
+	StopStepKill StopStep = "kill"
+)
+
+// StopResult describes how a stopped session ended
+type StopResult struct {
+	SessionID string        `json:"session_id"`
+	Step      StopStep      `json:"step"`
+	Elapsed   time.Duration `json:"elapsed"`
+	Exit      *ExitStatus   `json:"exit,omitempty"`
+}
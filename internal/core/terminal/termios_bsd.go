@@ -0,0 +1,8 @@
+//go:build darwin || freebsd || netbsd || openbsd || dragonfly
+
+package terminal
+
+import "golang.org/x/sys/unix"
+
+// ioctlGetTermios reads a terminal's settings
+const ioctlGetTermios = unix.TIOCGETA
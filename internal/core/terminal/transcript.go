@@ -0,0 +1,48 @@
+package terminal
+
+// transcriptSize is how much plain output text a session keeps for wait_for steps
+const transcriptSize = 64 * 1024
+
+// transcript keeps a session's recent output as plain text, without escape sequences,
+// carriage returns or other control characters, so patterns can be matched against it
+type transcript struct {
+	text   *ringBuffer
+	total  int64
+	filter EscapeFilter
+}
+
+// newTranscript creates a transcript keeping at most size bytes of text
+func newTranscript(size int) *transcript {
+	return &transcript{text: newRingBuffer(size)}
+}
+
+// Write filters terminal output into the transcript
+func (t *transcript) Write(p []byte) (int, error) {
+	plain := make([]byte, 0, len(p))
+	for _, b := range p {
+		if t.filter.Text(b) && (b == '\n' || b == '\t' || (b >= 0x20 && b != 0x7f)) {
+			plain = append(plain, b)
+		}
+	}
+
+	_, _ = t.text.Write(plain)
+	t.total += int64(len(plain))
+	return len(p), nil
+}
+
+// Offset is how much text has been written so far, marking the transcript's current end
+func (t *transcript) Offset() int64 {
+	return t.total
+}
+
+// Since returns the text written after offset, or all that is kept when some has been dropped,
+// along with the offset of its first byte
+func (t *transcript) Since(offset int64) ([]byte, int64) {
+	text := t.text.Bytes()
+	start := t.total - int64(len(text))
+	if offset > start {
+		text = text[offset-start:]
+		start = offset
+	}
+	return text, start
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"hama-shell/internal/daemon/infra"
+	"hama-shell/internal/daemon/model"
+)
+
+// DaemonAPI provides high-level daemon operations
+type DaemonAPI struct {
+	daemonMgr *infra.DaemonManager
+}
+
+// NewDaemonAPI creates a new DaemonAPI instance
+func NewDaemonAPI() *DaemonAPI {
+	return &DaemonAPI{
+		daemonMgr: infra.NewDaemonManager(),
+	}
+}
+
+// RunDaemon runs the daemon in the foreground
+func (api *DaemonAPI) RunDaemon(opts model.DaemonOptions) error {
+	return api.daemonMgr.Run(opts)
+}
+
+// StartDaemon starts the daemon in the background
+func (api *DaemonAPI) StartDaemon(opts model.DaemonOptions) error {
+	pid, err := api.daemonMgr.Start(opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Daemon running (pid %d)\n", pid)
+	return nil
+}
+
+// StopDaemon stops the daemon and every session it owns
+func (api *DaemonAPI) StopDaemon() error {
+	if err := api.daemonMgr.Stop(); err != nil {
+		return err
+	}
+
+	fmt.Println("🛑 Daemon stopped")
+	return nil
+}
+
+// ShowStatus displays whether the daemon is running
+func (api *DaemonAPI) ShowStatus() error {
+	status := api.daemonMgr.Status()
+
+	if !status.Running {
+		fmt.Println("Daemon is not running.")
+		fmt.Printf("Socket: %s\n", status.SocketPath)
+		return nil
+	}
+
+	fmt.Printf("Daemon is running (pid %d)\n", status.PID)
+	fmt.Printf("Socket:   %s\n", status.SocketPath)
+	fmt.Printf("Log:      %s\n", status.LogPath)
+	fmt.Printf("Sessions: %d\n", status.Sessions)
+
+	return nil
+}
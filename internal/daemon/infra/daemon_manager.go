@@ -0,0 +1,161 @@
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"hama-shell/internal/core/daemon"
+	"hama-shell/internal/core/terminal"
+	"hama-shell/internal/daemon/model"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DaemonManager handles daemon process operations
+type DaemonManager struct {
+	client daemon.Client
+}
+
+// NewDaemonManager creates a new DaemonManager instance
+func NewDaemonManager() *DaemonManager {
+	return &DaemonManager{
+		client: daemon.NewClient(),
+	}
+}
+
+// Run serves clients in the foreground until the daemon is stopped or signalled
+func (dm *DaemonManager) Run(opts model.DaemonOptions) error {
+	policy, err := terminal.ParseResizePolicy(opts.ResizePolicy)
+	if err != nil {
+		return err
+	}
+
+	server := daemon.NewServerWithConfig(daemon.ServerConfig{
+		Terminal: terminal.ServerConfig{
+			ScrollbackSize:  opts.ScrollbackSize,
+			ResizePolicy:    policy,
+			StopGracePeriod: opts.StopGracePeriod,
+		},
+	})
+
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Ignore(syscall.SIGHUP)
+	go func() {
+		<-sigChan
+		_ = server.Shutdown()
+	}()
+
+	fmt.Printf("hama-shell daemon listening on %s (pid %d)\n", server.GetSocketPath(), os.Getpid())
+	return server.Serve()
+}
+
+// Start launches the daemon in the background and waits until it answers
+func (dm *DaemonManager) Start(opts model.DaemonOptions) (int, error) {
+	if pid, err := dm.client.Ping(); err == nil {
+		return pid, nil
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate hama-shell executable: %w", err)
+	}
+
+	logFile, err := dm.openLog()
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	args := []string{"daemon"}
+	if opts.ScrollbackSize > 0 {
+		args = append(args, "--scrollback-size", strconv.Itoa(opts.ScrollbackSize))
+	}
+	if opts.ResizePolicy != "" {
+		args = append(args, "--resize-policy", opts.ResizePolicy)
+	}
+	if opts.StopGracePeriod > 0 {
+		args = append(args, "--stop-grace-period", opts.StopGracePeriod.String())
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start daemon: %w", err)
+	}
+	_ = cmd.Process.Release()
+
+	// Wait for the socket to come up
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if pid, err := dm.client.Ping(); err == nil {
+			return pid, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return 0, fmt.Errorf("daemon did not start, see %s", dm.logPath())
+}
+
+// EnsureRunning starts the daemon unless it is already running
+func (dm *DaemonManager) EnsureRunning() error {
+	_, err := dm.Start(model.DaemonOptions{})
+	return err
+}
+
+// Stop asks a running daemon to terminate its sessions and exit
+func (dm *DaemonManager) Stop() error {
+	if err := dm.client.Shutdown(); err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return err
+		}
+		return fmt.Errorf("failed to stop daemon: %w", err)
+	}
+	return nil
+}
+
+// Status reports whether the daemon is running
+func (dm *DaemonManager) Status() *model.DaemonStatus {
+	status := &model.DaemonStatus{
+		SocketPath: dm.client.GetSocketPath(),
+		LogPath:    dm.logPath(),
+	}
+
+	pid, err := dm.client.Ping()
+	if err != nil {
+		return status
+	}
+	status.Running = true
+	status.PID = pid
+
+	if sessions, err := dm.client.ListSessions(); err == nil {
+		status.Sessions = len(sessions)
+	}
+
+	return status
+}
+
+// logPath returns the file the background daemon writes its output to
+func (dm *DaemonManager) logPath() string {
+	return filepath.Join(daemon.StateDir(), "daemon.log")
+}
+
+// openLog opens the daemon log file for appending
+func (dm *DaemonManager) openLog() (*os.File, error) {
+	if err := os.MkdirAll(daemon.StateDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	logFile, err := os.OpenFile(dm.logPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	return logFile, nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// DaemonStatus represents the state of the background daemon
+type DaemonStatus struct {
+	Running    bool
+	PID        int
+	SocketPath string
+	LogPath    string
+	Sessions   int
+}
+
+// DaemonOptions holds settings for a daemon process
+type DaemonOptions struct {
+	// ScrollbackSize is the number of output bytes kept per session; zero uses the default
+	ScrollbackSize int
+	// ResizePolicy sizes sessions shared by several clients: smallest, largest or latest
+	ResizePolicy string
+	// StopGracePeriod is how long stopped sessions get before SIGKILL; zero uses the default
+	StopGracePeriod time.Duration
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	infra2 "hama-shell/internal/service/infra"
 	"hama-shell/internal/service/model"
+	"slices"
+	"strings"
 )
 
 // ServiceAPI provides high-level service operations
@@ -20,28 +23,53 @@ func NewServiceAPI() *ServiceAPI {
 	}
 }
 
-// StartService starts a service by project, service, and stage name
-func (api *ServiceAPI) StartService(projectName, serviceName, stageName string) error {
+// StartService starts a service by project, service, and stage name and returns the session's exit code
+func (api *ServiceAPI) StartService(projectName, serviceName, stageName string, opts model.StartOptions) (int, error) {
 	// Get service configuration
 	service, err := api.configReader.GetService(projectName, serviceName, stageName)
 	if err != nil {
-		return fmt.Errorf("failed to get service '%s.%s.%s': %w", projectName, serviceName, stageName, err)
+		return 0, fmt.Errorf("failed to get service '%s.%s.%s': %w", projectName, serviceName, stageName, err)
 	}
 
 	// Print service information
 	fmt.Printf("🚀 Starting service: %s\n", service.GetFullName())
-	fmt.Printf("📋 Commands to execute:\n")
-	for i, cmd := range service.Commands {
-		fmt.Printf("  [%d] %s\n", i+1, cmd)
+	printSSH(service.SSH, "")
+	if steps := service.GetSteps(); len(steps) > 0 {
+		fmt.Printf("📋 Steps to execute:\n")
+		for i, step := range steps {
+			fmt.Printf("  [%d] %s\n", i+1, step)
+		}
 	}
+	printExpectRules(service.Expect, "  ")
+	printProbes(service.Health, "  ")
+	printRestart(service.Restart, "  ")
+	printForwards(service.Forwards, "  ")
 	fmt.Printf("\n🔗 Connecting to interactive terminal...\n\n")
 
 	// Start interactive terminal session
-	if err := api.terminalMgr.StartInteractiveSession(service); err != nil {
-		return fmt.Errorf("failed to start terminal session: %w", err)
+	exitCode, err := api.terminalMgr.StartInteractiveSession(service, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start terminal session: %w", err)
 	}
 
-	return nil
+	return exitCode, nil
+}
+
+// RestartService stops the running sessions of a service and starts a fresh one, returning its exit code
+func (api *ServiceAPI) RestartService(projectName, serviceName, stageName string, opts model.StartOptions) (int, error) {
+	service, err := api.configReader.GetService(projectName, serviceName, stageName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get service '%s.%s.%s': %w", projectName, serviceName, stageName, err)
+	}
+
+	fmt.Printf("🔄 Restarting service: %s\n", service.GetFullName())
+	stopped, err := api.terminalMgr.StopServiceSessions(service)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Printf("🛑 Stopped %d running session(s)\n", stopped)
+
+	return api.StartService(projectName, serviceName, stageName, opts)
 }
 
 // ListServices returns all available services
@@ -81,9 +109,18 @@ func (api *ServiceAPI) ListServices() error {
 				fmt.Printf("  🔧 Service: %s\n", serviceName)
 				for _, stage := range stages {
 					fmt.Printf("    📋 %s\n", stage.GetFullName())
-					for i, command := range stage.Commands {
-						fmt.Printf("      [%d] %s\n", i+1, command)
+					if stage.ConfigError != nil {
+						fmt.Printf("      ❌ invalid: %v\n", stage.ConfigError)
+						continue
 					}
+					printSSH(stage.SSH, "      ")
+					for i, step := range stage.GetSteps() {
+						fmt.Printf("      [%d] %s\n", i+1, step)
+					}
+					printExpectRules(stage.Expect, "      ")
+					printProbes(stage.Health, "      ")
+					printRestart(stage.Restart, "      ")
+					printForwards(stage.Forwards, "      ")
 				}
 			}
 		}
@@ -97,6 +134,136 @@ func (api *ServiceAPI) ListServices() error {
 	return nil
 }
 
+// ServiceInfo shows a service's configuration and its running sessions with their forwards
+func (api *ServiceAPI) ServiceInfo(projectName, serviceName, stageName string) error {
+	service, err := api.configReader.GetService(projectName, serviceName, stageName)
+	if err != nil {
+		return fmt.Errorf("failed to get service '%s.%s.%s': %w", projectName, serviceName, stageName, err)
+	}
+
+	fmt.Printf("📋 Service: %s\n", service.GetFullName())
+	printSSH(service.SSH, "  ")
+	for i, step := range service.GetSteps() {
+		fmt.Printf("  [%d] %s\n", i+1, step)
+	}
+	printExpectRules(service.Expect, "  ")
+	printProbes(service.Health, "  ")
+	printRestart(service.Restart, "  ")
+	printForwards(service.Forwards, "  ")
+
+	sessions, err := api.terminalMgr.ListServiceSessions(service)
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("\n💤 No running sessions")
+		return nil
+	}
+
+	fmt.Println("\n🟢 Running sessions:")
+	for _, session := range sessions {
+		fmt.Printf("  %s (%s since %s)\n", session.ID, session.Status, session.StartTime.Format("2006-01-02 15:04:05"))
+		for _, forward := range session.Forwards {
+			fmt.Printf("    🔀 %s\n", forward)
+			if forward.Error != "" {
+				fmt.Printf("       ⚠️  %s\n", forward.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// ServiceEnv prints the variables describing a running session, found by its ID or its target,
+// in the given format so scripts can eval them
+func (api *ServiceAPI) ServiceEnv(ref, format string) error {
+	if !slices.Contains(model.EnvFormats, format) {
+		return model.ErrInvalidEnvFormat
+	}
+
+	// Everything comes from the daemon, so a session outlives changes to its stage's configuration
+	session, err := api.terminalMgr.FindRunningSession(ref)
+	if err != nil {
+		return err
+	}
+	return printEnv(session.Env(), format)
+}
+
+// printEnv writes variables as shell exports, a dotenv file, a JSON object or fish commands
+func printEnv(vars []model.EnvVar, format string) error {
+	if format == "json" {
+		values := make(map[string]string, len(vars))
+		for _, v := range vars {
+			values[v.Name] = v.Value
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode variables: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, v := range vars {
+		switch format {
+		case "dotenv":
+			fmt.Printf("%s=%s\n", v.Name, dotenvQuote(v.Value))
+		case "fish":
+			fmt.Printf("set -gx %s '%s'\n", v.Name, strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v.Value))
+		default:
+			fmt.Printf("export %s='%s'\n", v.Name, strings.ReplaceAll(v.Value, "'", `'\''`))
+		}
+	}
+	return nil
+}
+
+// dotenvQuote leaves plain values bare and double-quotes the rest
+func dotenvQuote(value string) string {
+	if value != "" && strings.Trim(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:/@") == "" {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`).Replace(value) + `"`
+}
+
+// printSSH shows the remote host of an SSH service
+func printSSH(ssh *model.SSHOptions, indent string) {
+	if ssh == nil {
+		return
+	}
+	fmt.Printf("%s🔐 ssh %s\n", indent, ssh)
+}
+
+// printExpectRules lists a service's expect rules; responses are never shown, only their source
+func printExpectRules(rules []model.ExpectRule, indent string) {
+	for _, rule := range rules {
+		fmt.Printf("%s🔑 expect '%s' → %s\n", indent, rule.Pattern, rule.GetSource())
+	}
+}
+
+// printProbes lists a service's health probes
+func printProbes(health *model.HealthOptions, indent string) {
+	if health == nil {
+		return
+	}
+	for _, probe := range health.Probes {
+		fmt.Printf("%s🩺 probe %s\n", indent, probe)
+	}
+}
+
+// printRestart shows a service's restart policy unless it is never restarted
+func printRestart(restart *model.RestartOptions, indent string) {
+	if restart == nil || restart.Policy == "never" {
+		return
+	}
+	fmt.Printf("%s🔁 restart %s\n", indent, restart)
+}
+
+// printForwards lists a service's port forwards as configured
+func printForwards(forwards []model.Forward, indent string) {
+	for _, forward := range forwards {
+		fmt.Printf("%s🔀 forward %s\n", indent, forward)
+	}
+}
+
 // Shutdown gracefully shuts down the API and its dependencies
 func (api *ServiceAPI) Shutdown() error {
 	return api.terminalMgr.Shutdown()
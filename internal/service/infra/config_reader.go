@@ -1,6 +1,9 @@
 package infra
 
 import (
+	"fmt"
+	"time"
+
 	config "hama-shell/internal/configuration/infra"
 	configModel "hama-shell/internal/configuration/model"
 	model2 "hama-shell/internal/service/model"
@@ -42,11 +45,9 @@ func (c *ConfigReader) GetService(projectName, serviceName, stageName string) (*
 	}
 
 	// Create service model
-	service := &model2.Service{
-		ProjectName: projectName,
-		ServiceName: serviceName,
-		StageName:   stageName,
-		Commands:    stageConfig.Commands,
+	service, err := newService(projectName, serviceName, stageName, stageConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate service
@@ -57,7 +58,8 @@ func (c *ConfigReader) GetService(projectName, serviceName, stageName string) (*
 	return service, nil
 }
 
-// ListAllServices returns all available services
+// ListAllServices returns all available services; a stage whose configuration is invalid is
+// listed with its ConfigError set rather than failing the whole listing
 func (c *ConfigReader) ListAllServices() ([]model2.Service, error) {
 	cfg := c.manager
 	var services []model2.Service
@@ -65,13 +67,19 @@ func (c *ConfigReader) ListAllServices() ([]model2.Service, error) {
 	for projectName, project := range cfg.Projects {
 		for serviceName, serviceConfig := range project.Services {
 			for stageName, stageConfig := range serviceConfig.Stages {
-				service := model2.Service{
-					ProjectName: projectName,
-					ServiceName: serviceName,
-					StageName:   stageName,
-					Commands:    stageConfig.Commands,
+				service, err := newService(projectName, serviceName, stageName, stageConfig)
+				if err == nil {
+					err = service.Validate()
 				}
-				services = append(services, service)
+				if err != nil {
+					service = &model2.Service{
+						ProjectName: projectName,
+						ServiceName: serviceName,
+						StageName:   stageName,
+						ConfigError: err,
+					}
+				}
+				services = append(services, *service)
 			}
 		}
 	}
@@ -79,6 +87,228 @@ func (c *ConfigReader) ListAllServices() ([]model2.Service, error) {
 	return services, nil
 }
 
+// newService maps a stage configuration onto the service model
+func newService(projectName, serviceName, stageName string, stageConfig *configModel.Stage) (*model2.Service, error) {
+	service := &model2.Service{
+		ProjectName:  projectName,
+		ServiceName:  serviceName,
+		StageName:    stageName,
+		Commands:     stageConfig.Commands,
+		ResizePolicy: stageConfig.ResizePolicy,
+	}
+
+	for i, stageStep := range stageConfig.Steps {
+		step, err := newStep(stageStep)
+		if err != nil {
+			return nil, fmt.Errorf("%s: step %d: %w", service.GetFullName(), i+1, err)
+		}
+		service.Steps = append(service.Steps, step)
+	}
+
+	if stageConfig.StopGracePeriod != "" {
+		grace, err := time.ParseDuration(stageConfig.StopGracePeriod)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid stop_grace_period: %w", service.GetFullName(), err)
+		}
+		service.StopGracePeriod = grace
+	}
+
+	if stageConfig.Log != nil && stageConfig.Log.Enabled {
+		service.Log = &model2.LogOptions{
+			MaxSize:     int64(stageConfig.Log.MaxSizeMB) * 1024 * 1024,
+			MaxFiles:    stageConfig.Log.MaxFiles,
+			MaxSessions: stageConfig.Log.MaxSessions,
+		}
+		if stageConfig.Log.MaxAge != "" {
+			maxAge, err := time.ParseDuration(stageConfig.Log.MaxAge)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid log max_age: %w", service.GetFullName(), err)
+			}
+			service.Log.MaxAge = maxAge
+		}
+	}
+
+	for _, rule := range stageConfig.Expect {
+		service.Expect = append(service.Expect, model2.ExpectRule{
+			Pattern:         rule.Pattern,
+			Response:        rule.Response,
+			ResponseEnv:     rule.ResponseEnv,
+			ResponseCommand: rule.ResponseCommand,
+			MaxResponses:    rule.MaxResponses,
+		})
+	}
+
+	if stageConfig.Restart != "" {
+		restart, err := newRestartOptions(stageConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.GetFullName(), err)
+		}
+		service.Restart = restart
+	}
+
+	if stageConfig.SSH != nil {
+		ssh := newSSHOptions(stageConfig.SSH)
+		service.SSH = &ssh
+	}
+
+	for _, forward := range stageConfig.Forwards {
+		service.Forwards = append(service.Forwards, model2.Forward{
+			Name:   forward.Name,
+			Type:   forward.Type,
+			Local:  forward.Local,
+			Remote: forward.Remote,
+		})
+	}
+
+	if stageConfig.Health != nil && len(stageConfig.Health.Probes) > 0 {
+		health, err := newHealthOptions(stageConfig.Health)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.GetFullName(), err)
+		}
+		service.Health = health
+	}
+
+	if stageConfig.Dispatch != nil {
+		dispatch, err := newDispatchOptions(stageConfig.Dispatch)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", service.GetFullName(), err)
+		}
+		service.Dispatch = dispatch
+	}
+
+	return service, nil
+}
+
+// newStep parses a stage step's durations
+func newStep(stageStep configModel.StageStep) (model2.Step, error) {
+	step := model2.Step{
+		Run:        stageStep.Run,
+		Send:       stageStep.Send,
+		Keys:       stageStep.Keys,
+		WaitFor:    stageStep.WaitFor,
+		Checkpoint: stageStep.Checkpoint,
+	}
+
+	if stageStep.Sleep != "" {
+		sleep, err := time.ParseDuration(stageStep.Sleep)
+		if err != nil {
+			return step, fmt.Errorf("invalid sleep: %w", err)
+		}
+		step.Sleep = sleep
+	}
+
+	if stageStep.Timeout != "" {
+		timeout, err := time.ParseDuration(stageStep.Timeout)
+		if err != nil {
+			return step, fmt.Errorf("invalid timeout: %w", err)
+		}
+		step.Timeout = timeout
+	}
+
+	return step, nil
+}
+
+// newSSHOptions maps a stage's ssh settings and those of its jump hosts
+func newSSHOptions(stageSSH *configModel.StageSSH) model2.SSHOptions {
+	ssh := model2.SSHOptions{
+		Host:         stageSSH.Host,
+		Port:         stageSSH.Port,
+		User:         stageSSH.User,
+		IdentityFile: stageSSH.IdentityFile,
+		Options:      stageSSH.Options,
+	}
+	for i := range stageSSH.Via {
+		ssh.Via = append(ssh.Via, newSSHOptions(&stageSSH.Via[i]))
+	}
+	return ssh
+}
+
+// newRestartOptions parses a stage's restart settings
+func newRestartOptions(stageConfig *configModel.Stage) (*model2.RestartOptions, error) {
+	restart := &model2.RestartOptions{
+		Policy:      stageConfig.Restart,
+		MaxAttempts: stageConfig.RestartMaxAttempts,
+	}
+
+	if stageConfig.RestartBackoff != "" {
+		backoff, err := time.ParseDuration(stageConfig.RestartBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid restart_backoff: %w", err)
+		}
+		restart.Backoff = backoff
+	}
+
+	if stageConfig.RestartMaxBackoff != "" {
+		maxBackoff, err := time.ParseDuration(stageConfig.RestartMaxBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("invalid restart_max_backoff: %w", err)
+		}
+		restart.MaxBackoff = maxBackoff
+	}
+
+	return restart, nil
+}
+
+// newHealthOptions parses a stage's probe settings
+func newHealthOptions(stageHealth *configModel.StageHealth) (*model2.HealthOptions, error) {
+	health := &model2.HealthOptions{FailureThreshold: stageHealth.FailureThreshold}
+	for _, probe := range stageHealth.Probes {
+		health.Probes = append(health.Probes, model2.Probe{
+			TCP:     probe.TCP,
+			Command: probe.Command,
+			Output:  probe.Output,
+		})
+	}
+
+	if stageHealth.Interval != "" {
+		interval, err := time.ParseDuration(stageHealth.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health interval: %w", err)
+		}
+		health.Interval = interval
+	}
+
+	if stageHealth.Timeout != "" {
+		timeout, err := time.ParseDuration(stageHealth.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health timeout: %w", err)
+		}
+		health.Timeout = timeout
+	}
+
+	return health, nil
+}
+
+// newDispatchOptions parses a stage's dispatch settings; a quiet period of "off" disables it
+func newDispatchOptions(stageDispatch *configModel.StageDispatch) (*model2.DispatchOptions, error) {
+	dispatch := &model2.DispatchOptions{PromptPatterns: stageDispatch.Prompts}
+
+	switch stageDispatch.QuietPeriod {
+	case "":
+	case "off":
+		dispatch.QuietPeriod = -1
+	default:
+		quiet, err := time.ParseDuration(stageDispatch.QuietPeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dispatch quiet_period: %w", err)
+		}
+		if quiet <= 0 {
+			return nil, fmt.Errorf("invalid dispatch quiet_period %q: use a positive duration or off", stageDispatch.QuietPeriod)
+		}
+		dispatch.QuietPeriod = quiet
+	}
+
+	if stageDispatch.StepTimeout != "" {
+		timeout, err := time.ParseDuration(stageDispatch.StepTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dispatch step_timeout: %w", err)
+		}
+		dispatch.StepTimeout = timeout
+	}
+
+	return dispatch, nil
+}
+
 // GetConfigFilePath returns the configuration file path
 func (c *ConfigReader) GetConfigFilePath() string {
 	configManager := config.GetInstance()
@@ -1,148 +1,409 @@
 package infra
 
 import (
+	"errors"
 	"fmt"
 	"hama-shell/internal/service/model"
-	"io"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/creack/pty"
 	"golang.org/x/term"
 
+	"hama-shell/internal/core/daemon"
 	"hama-shell/internal/core/terminal"
+	daemonInfra "hama-shell/internal/daemon/infra"
 )
 
 // TerminalManager handles terminal session operations
 type TerminalManager struct {
-	server terminal.Server
+	client    daemon.Client
+	daemonMgr *daemonInfra.DaemonManager
 }
 
 // NewTerminalManager creates a new TerminalManager instance
 func NewTerminalManager() *TerminalManager {
 	return &TerminalManager{
-		server: terminal.NewTerminalServer(),
+		client:    daemon.NewClient(),
+		daemonMgr: daemonInfra.NewDaemonManager(),
 	}
 }
 
-// StartInteractiveSession starts an interactive terminal session for a service
-func (t *TerminalManager) StartInteractiveSession(service *model.Service) error {
+// StartInteractiveSession starts a service session in the daemon and attaches the current terminal to it.
+// It returns the session's exit code, or zero when the terminal detached and the session keeps running.
+func (t *TerminalManager) StartInteractiveSession(service *model.Service, opts model.StartOptions) (int, error) {
 	sessionID := fmt.Sprintf("%s-%d", service.GetFullName(), time.Now().Unix())
 
-	// Save original terminal state
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	detachKeys, err := daemon.ParseDetachKeys(opts.DetachKeys)
 	if err != nil {
-		return fmt.Errorf("failed to set raw mode: %w", err)
+		return 0, err
 	}
 
-	// Setup cleanup function
-	cleanup := func() {
-		_ = term.Restore(int(os.Stdin.Fd()), oldState)
-		_ = t.server.KillSession(sessionID)
+	// Make sure the daemon that owns sessions is up
+	if err := t.daemonMgr.EnsureRunning(); err != nil {
+		return 0, err
 	}
-	defer cleanup()
-
-	// Set up signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		cleanup()
-		os.Exit(0)
-	}()
 
 	// Create terminal session
-	session, err := t.createSession(sessionID)
+	if err := t.createSession(sessionID, service); err != nil {
+		return 0, err
+	}
+
+	stream, err := t.client.Attach(sessionID, daemon.AttachOptions{})
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to attach to session: %w", err)
 	}
+	defer stream.Close()
 
-	// Setup terminal I/O
-	if err := t.setupTerminalIO(sessionID, session); err != nil {
-		return err
+	attachConfig := daemon.AttachConfig{DetachKeys: detachKeys}
+	if opts.Record {
+		recording, err := t.startRecording(sessionID, service, opts.RecordFile)
+		if err != nil {
+			return 0, err
+		}
+		defer recording.close()
+		attachConfig.Recorder = recording.recorder
 	}
 
-	// Execute service commands
-	go t.executeCommands(session, service.Commands)
+	result, err := daemon.AttachTerminal(stream, attachConfig)
+	if err != nil {
+		return 0, err
+	}
 
-	// Wait for session to finish
-	for session.IsRunning() {
-		time.Sleep(100 * time.Millisecond)
+	if result.Detached {
+		fmt.Printf("\n🔌 Detached from session %s (reattach with: hs attach %s)\n", sessionID, sessionID)
+		return 0, nil
 	}
 
-	// Restore terminal before final output
-	_ = term.Restore(int(os.Stdin.Fd()), oldState)
-	fmt.Printf("\n✅ Session ended normally\n")
+	exit := result.Exit
+	switch {
+	case exit == nil:
+		fmt.Printf("\n✅ Session ended\n")
+		return 0, nil
+	case exit.Reason == terminal.EndReasonFailed:
+		fmt.Printf("\n❌ Session failed: %s\n", exit.Error)
+		if exit.Code == 0 {
+			return 1, nil
+		}
+	case exit.Signal != "":
+		fmt.Printf("\n❌ Session %s by %s\n", exit.Reason, exit.Signal)
+	case exit.Code != 0:
+		fmt.Printf("\n❌ Session %s with status %d\n", exit.Reason, exit.Code)
+	default:
+		fmt.Printf("\n✅ Session ended normally\n")
+	}
+	return exit.Code, nil
+}
 
-	return nil
+// StopServiceSessions stops every running session of the service and returns how many were stopped
+func (t *TerminalManager) StopServiceSessions(service *model.Service) (int, error) {
+	sessions, err := t.client.ListSessions()
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to query daemon: %w", err)
+	}
+
+	stopped := 0
+	for _, session := range sessions {
+		if session.Target != service.GetFullName() || !session.Running {
+			continue
+		}
+		if _, err := t.client.StopSession(session.ID, 0); err != nil {
+			return stopped, fmt.Errorf("failed to stop session %s: %w", session.ID, err)
+		}
+		stopped++
+	}
+
+	return stopped, nil
 }
 
-// createSession creates a new terminal session
-func (t *TerminalManager) createSession(sessionID string) (terminal.Session, error) {
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/bash"
+// FindRunningSession resolves a session ID, or a target with a single running session, to the
+// session as the daemon runs it. Its service carries only the names from its target, as the
+// configuration may have changed since it started.
+func (t *TerminalManager) FindRunningSession(ref string) (*model.ServiceSession, error) {
+	sessions, err := t.client.ListSessions()
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return nil, fmt.Errorf("no running session matches %q", ref)
+		}
+		return nil, fmt.Errorf("failed to query daemon: %w", err)
+	}
+
+	var matched []daemon.SessionInfo
+	for _, session := range sessions {
+		if !session.Running {
+			continue
+		}
+		if session.ID == ref {
+			matched = []daemon.SessionInfo{session}
+			break
+		}
+		if session.Target == ref {
+			matched = append(matched, session)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("no running session matches %q", ref)
+	case 1:
+	default:
+		ids := make([]string, len(matched))
+		for i, session := range matched {
+			ids[i] = session.ID
+		}
+		return nil, fmt.Errorf("%d sessions of %s are running (%s); pass a session ID instead",
+			len(matched), ref, strings.Join(ids, ", "))
+	}
+
+	session := matched[0]
+	parts := strings.Split(session.Target, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("session %s has no <project>.<service>.<stage> target", session.ID)
+	}
+	service := model.Service{ProjectName: parts[0], ServiceName: parts[1], StageName: parts[2]}
+	serviceSession := newServiceSession(session, service)
+	return &serviceSession, nil
+}
+
+// ListServiceSessions returns the running sessions of the service with their forwards
+func (t *TerminalManager) ListServiceSessions(service *model.Service) ([]model.ServiceSession, error) {
+	sessions, err := t.client.ListSessions()
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query daemon: %w", err)
+	}
+
+	var running []model.ServiceSession
+	for _, session := range sessions {
+		if session.Target != service.GetFullName() || !session.Running {
+			continue
+		}
+
+		running = append(running, newServiceSession(session, *service))
+	}
+	return running, nil
+}
+
+// newServiceSession describes a running session of service from the daemon's view of it
+func newServiceSession(session daemon.SessionInfo, service model.Service) model.ServiceSession {
+	serviceSession := model.ServiceSession{
+		ID:        session.ID,
+		Service:   service,
+		StartTime: session.StartTime,
+		Status:    session.Health,
+	}
+	switch {
+	case session.Restarting:
+		serviceSession.Status = "restarting"
+	case serviceSession.Status == "":
+		serviceSession.Status = "running"
+	}
+	for _, forward := range session.Forwards {
+		serviceSession.Forwards = append(serviceSession.Forwards, model.ActiveForward{
+			Name:        forward.Name,
+			Type:        string(forward.Type),
+			Listen:      forward.Listen,
+			Target:      forward.Target,
+			Connections: forward.Connections,
+			Error:       forward.Error,
+		})
+	}
+	return serviceSession
+}
+
+// recording is an asciicast file being written while a terminal is attached
+type recording struct {
+	path     string
+	file     *os.File
+	recorder *terminal.Recorder
+}
+
+// startRecording creates the recording file and writes its header sized to the local terminal
+func (t *TerminalManager) startRecording(sessionID string, service *model.Service, path string) (*recording, error) {
+	if path == "" {
+		path = filepath.Join(daemon.StateDir(), "recordings", sessionID+".cast")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	// The session takes the local terminal's size once attached
+	width, height, err := term.GetSize(int(os.Stdin.Fd()))
+	if err != nil {
+		width, height = 80, 24
 	}
 
-	session, err := t.server.CreateSession(sessionID, shell, []string{})
+	recorder, err := terminal.NewRecorder(file, terminal.CastHeader{
+		Width:  width,
+		Height: height,
+		Title:  service.GetFullName(),
+		Env:    map[string]string{"SHELL": os.Getenv("SHELL"), "TERM": os.Getenv("TERM")},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create terminal session: %w", err)
+		file.Close()
+		return nil, err
 	}
 
-	return session, nil
+	fmt.Printf("🎬 Recording to %s\n", path)
+	return &recording{path: path, file: file, recorder: recorder}, nil
 }
 
-// setupTerminalIO configures terminal input/output handling
-func (t *TerminalManager) setupTerminalIO(sessionID string, session terminal.Session) error {
-	ptyMaster := session.GetPTYMaster()
+// close finishes the recording file
+func (r *recording) close() {
+	if err := r.recorder.Close(); err != nil {
+		fmt.Printf("⚠️  Recording %s may be incomplete: %v\n", r.path, err)
+	}
+	r.file.Close()
+	fmt.Printf("🎬 Recording saved to %s (play it with: hs replay %s)\n", r.path, r.path)
+}
+
+// createSession asks the daemon to start a session running the service commands
+func (t *TerminalManager) createSession(sessionID string, service *model.Service) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/bash"
+	}
+
+	// The session runs inside the daemon, so hand over our environment and directory
+	dir, _ := os.Getwd()
+
+	config := terminal.SessionConfig{
+		ID:              sessionID,
+		Target:          service.GetFullName(),
+		Shell:           shell,
+		Env:             os.Environ(),
+		Dir:             dir,
+		Commands:        service.Commands,
+		ResizePolicy:    terminal.ResizePolicy(service.ResizePolicy),
+		StopGracePeriod: service.StopGracePeriod,
+	}
+	if service.Log != nil {
+		config.Log = &terminal.LogConfig{
+			MaxSize:     service.Log.MaxSize,
+			MaxAge:      service.Log.MaxAge,
+			MaxFiles:    service.Log.MaxFiles,
+			MaxSessions: service.Log.MaxSessions,
+		}
+	}
+
+	if service.Dispatch != nil {
+		config.Dispatch = &terminal.DispatchConfig{
+			PromptPatterns: service.Dispatch.PromptPatterns,
+			QuietPeriod:    service.Dispatch.QuietPeriod,
+			StepTimeout:    service.Dispatch.StepTimeout,
+		}
+	}
 
-	// Set terminal size
-	if size, err := pty.GetsizeFull(os.Stdin); err == nil {
-		if err := t.server.ResizeSession(sessionID, size.Rows, size.Cols); err != nil {
-			fmt.Printf("Warning: failed to set PTY size: %v\n", err)
+	if service.Restart != nil {
+		config.Restart = &terminal.RestartConfig{
+			Policy:      terminal.RestartPolicy(service.Restart.Policy),
+			MaxAttempts: service.Restart.MaxAttempts,
+			Backoff:     service.Restart.Backoff,
+			MaxBackoff:  service.Restart.MaxBackoff,
 		}
 	}
 
-	// Handle window size changes
-	go func() {
-		sigwinch := make(chan os.Signal, 1)
-		signal.Notify(sigwinch, syscall.SIGWINCH)
-		for range sigwinch {
-			if size, err := pty.GetsizeFull(os.Stdin); err == nil {
-				_ = t.server.ResizeSession(sessionID, size.Rows, size.Cols)
-			}
+	if service.SSH != nil {
+		ssh := newTerminalSSH(*service.SSH)
+		config.SSH = &ssh
+	}
+
+	for _, forward := range service.Forwards {
+		config.Forwards = append(config.Forwards, terminal.Forward{
+			Name:   forward.Name,
+			Type:   terminal.ForwardType(forward.GetType()),
+			Local:  forward.Local,
+			Remote: forward.Remote,
+		})
+	}
+
+	if service.Health != nil {
+		config.Health = &terminal.HealthConfig{
+			Interval:         service.Health.Interval,
+			Timeout:          service.Health.Timeout,
+			FailureThreshold: service.Health.FailureThreshold,
+		}
+		for _, probe := range service.Health.Probes {
+			config.Health.Probes = append(config.Health.Probes, newTerminalProbe(probe))
 		}
-	}()
+	}
 
-	// Copy stdin to ptyMaster (user input -> shell)
-	go func() {
-		_, _ = io.Copy(ptyMaster, os.Stdin)
-	}()
+	for _, step := range service.Steps {
+		config.Steps = append(config.Steps, newTerminalStep(step))
+	}
 
-	// Copy ptyMaster to stdout (shell output -> terminal)
-	go func() {
-		_, _ = io.Copy(os.Stdout, ptyMaster)
-	}()
+	for _, rule := range service.Expect {
+		config.Expect = append(config.Expect, terminal.ExpectRule{
+			Pattern:         rule.Pattern,
+			Response:        rule.Response,
+			ResponseEnv:     rule.ResponseEnv,
+			ResponseCommand: rule.ResponseCommand,
+			MaxResponses:    rule.MaxResponses,
+		})
+	}
+
+	_, err := t.client.CreateSession(config)
+	if err != nil {
+		return fmt.Errorf("failed to create terminal session: %w", err)
+	}
 
 	return nil
 }
 
-// executeCommands sends commands to the terminal session
-func (t *TerminalManager) executeCommands(session terminal.Session, commands []string) {
-	time.Sleep(500 * time.Millisecond) // Wait for shell prompt
+// newTerminalStep maps a service step onto the terminal's step types
+func newTerminalStep(step model.Step) terminal.Step {
+	switch step.GetKind() {
+	case "send":
+		return terminal.Step{Type: terminal.StepSend, Text: step.Send, Keys: step.Keys}
+	case "wait_for":
+		return terminal.Step{Type: terminal.StepWaitFor, Text: step.WaitFor, Timeout: step.Timeout}
+	case "sleep":
+		return terminal.Step{Type: terminal.StepSleep, Duration: step.Sleep}
+	case "checkpoint":
+		return terminal.Step{Type: terminal.StepCheckpoint, Text: step.Checkpoint}
+	default:
+		return terminal.Step{Type: terminal.StepRun, Text: step.Run, Timeout: step.Timeout}
+	}
+}
 
-	for _, command := range commands {
-		commandWithNewline := command + "\n"
-		if err := session.WriteInput([]byte(commandWithNewline)); err != nil {
-			fmt.Printf("Warning: failed to send command '%s': %v\n", command, err)
-		}
-		time.Sleep(200 * time.Millisecond) // Small delay between commands
+// newTerminalProbe maps a service probe onto the terminal's probe types
+func newTerminalProbe(probe model.Probe) terminal.Probe {
+	switch probe.GetKind() {
+	case "tcp":
+		return terminal.Probe{Type: terminal.ProbeTCP, Target: probe.TCP}
+	case "command":
+		return terminal.Probe{Type: terminal.ProbeCommand, Target: probe.Command}
+	default:
+		return terminal.Probe{Type: terminal.ProbeOutput, Target: probe.Output}
 	}
 }
 
-// Shutdown gracefully shuts down the terminal manager
+// newTerminalSSH maps a service's ssh settings, jump hosts included, onto the terminal's
+func newTerminalSSH(ssh model.SSHOptions) terminal.SSHConfig {
+	config := terminal.SSHConfig{
+		Host:         ssh.Host,
+		Port:         ssh.Port,
+		User:         ssh.User,
+		IdentityFile: ssh.IdentityFile,
+		Options:      ssh.Options,
+	}
+	for _, jump := range ssh.Via {
+		config.Via = append(config.Via, newTerminalSSH(jump))
+	}
+	return config
+}
+
+// Shutdown releases the terminal manager; sessions stay with the daemon
 func (t *TerminalManager) Shutdown() error {
-	return t.server.Shutdown()
+	return nil
 }
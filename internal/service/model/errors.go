@@ -4,9 +4,21 @@ import "errors"
 
 // Domain errors
 var (
-	ErrEmptyProjectName = errors.New("project name cannot be empty")
-	ErrEmptyServiceName = errors.New("service name cannot be empty")
-	ErrEmptyStageName   = errors.New("stage name cannot be empty")
-	ErrNoCommands       = errors.New("service must have at least one command")
-	ErrServiceNotFound  = errors.New("service not found")
+	ErrEmptyProjectName       = errors.New("project name cannot be empty")
+	ErrEmptyServiceName       = errors.New("service name cannot be empty")
+	ErrEmptyStageName         = errors.New("stage name cannot be empty")
+	ErrNoCommands             = errors.New("service must have at least one command or step")
+	ErrCommandsAndSteps       = errors.New("service cannot have both commands and steps")
+	ErrInvalidStep            = errors.New("invalid step")
+	ErrInvalidHealthOptions   = errors.New("invalid health settings")
+	ErrInvalidRestartOptions  = errors.New("invalid restart settings")
+	ErrInvalidSSHOptions      = errors.New("invalid ssh settings")
+	ErrInvalidForward         = errors.New("invalid forward")
+	ErrServiceNotFound        = errors.New("service not found")
+	ErrInvalidResizePolicy    = errors.New("resize_policy must be smallest, largest or latest")
+	ErrInvalidStopGracePeriod = errors.New("stop_grace_period cannot be negative")
+	ErrInvalidLogOptions      = errors.New("log max_size_mb, max_age, max_files and max_sessions cannot be negative")
+	ErrInvalidDispatchOptions = errors.New("invalid dispatch settings")
+	ErrInvalidExpectRule      = errors.New("invalid expect rule")
+	ErrInvalidEnvFormat       = errors.New("env format must be export, dotenv, json or fish")
 )
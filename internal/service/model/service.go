@@ -1,6 +1,14 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Service represents a service configuration
 type Service struct {
@@ -8,6 +16,329 @@ type Service struct {
 	ServiceName string
 	StageName   string
 	Commands    []string
+	// Steps replace Commands with a richer startup; GetSteps covers both
+	Steps        []Step
+	ResizePolicy string
+	// StopGracePeriod is how long the session gets after SIGTERM before SIGKILL; zero uses the daemon's
+	StopGracePeriod time.Duration
+	// Log enables the session's output log when set
+	Log *LogOptions
+	// Dispatch controls when each command is sent; nil uses the defaults
+	Dispatch *DispatchOptions
+	// Expect answers interactive prompts in the session's output
+	Expect []ExpectRule
+	// Health probes decide when the session is ready and whether it stays healthy; nil means none
+	Health *HealthOptions
+	// Restart starts the session again when its process ends; nil never restarts it
+	Restart *RestartOptions
+	// SSH runs the session on a remote host instead of a local shell; nil runs it locally
+	SSH *SSHOptions
+	// Forwards tunnel ports through the SSH connection
+	Forwards []Forward
+	// ConfigError is why a listed stage cannot be started; only listings carry invalid stages
+	ConfigError error
+}
+
+// ForwardAutoPort as a forward's local port picks any free port, which the stage can use as
+// ${forward.<name>.port}
+const ForwardAutoPort = "auto"
+
+// Forward is a port forward: local (the default), remote or dynamic
+type Forward struct {
+	Name   string
+	Type   string
+	Local  string
+	Remote string
+}
+
+// GetType returns the forward's type, local when none is set
+func (f Forward) GetType() string {
+	if f.Type == "" {
+		return "local"
+	}
+	return f.Type
+}
+
+// String describes the forward for listings
+func (f Forward) String() string {
+	switch f.GetType() {
+	case "remote":
+		return fmt.Sprintf("%s remote %s → %s", f.Name, f.Remote, f.Local)
+	case "dynamic":
+		return fmt.Sprintf("%s dynamic %s (SOCKS)", f.Name, f.Local)
+	default:
+		return fmt.Sprintf("%s local %s → %s", f.Name, f.Local, f.Remote)
+	}
+}
+
+// validate checks the forward's type and that each address it needs ends in a port
+func (f Forward) validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("%w: every forward needs a name", ErrInvalidForward)
+	}
+
+	var addresses []string
+	switch f.GetType() {
+	case "local", "remote":
+		addresses = []string{f.Local, f.Remote}
+	case "dynamic":
+		addresses = []string{f.Local}
+	default:
+		return fmt.Errorf("%w: %s: type must be local, remote or dynamic, not %q", ErrInvalidForward, f.Name, f.Type)
+	}
+	for i, address := range addresses {
+		if address == "" {
+			return fmt.Errorf("%w: %s: a %s forward needs local and remote addresses", ErrInvalidForward, f.Name, f.GetType())
+		}
+		port := address[strings.LastIndex(address, ":")+1:]
+		// Only a listener on this machine can take any free port
+		if port == ForwardAutoPort {
+			if f.GetType() == "remote" || i > 0 {
+				return fmt.Errorf("%w: %s: only a local or dynamic forward's local port can be %s", ErrInvalidForward, f.Name, ForwardAutoPort)
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("%w: %s: %q does not end in a port", ErrInvalidForward, f.Name, address)
+		}
+	}
+	if f.GetType() == "local" && !strings.Contains(f.Remote, ":") {
+		return fmt.Errorf("%w: %s: remote %q needs a host", ErrInvalidForward, f.Name, f.Remote)
+	}
+	return nil
+}
+
+// SSHOptions is the remote host an SSH service logs in to; zero values use the defaults
+type SSHOptions struct {
+	Host         string
+	Port         int
+	User         string
+	IdentityFile string
+	// Options are OpenSSH client options, matched case-insensitively
+	Options map[string]string
+	// Via lists the jump hosts to tunnel through, in order
+	Via []SSHOptions
+}
+
+// SSHOptionNames are the OpenSSH client options an SSH service may set, in lower case
+var SSHOptionNames = []string{
+	"stricthostkeychecking",
+	"userknownhostsfile",
+	"connecttimeout",
+	"serveraliveinterval",
+	"serveralivecountmax",
+}
+
+// String describes the remote target as [user@]host[:port], followed by its jump hosts
+func (o SSHOptions) String() string {
+	target := o.Host
+	if o.User != "" {
+		target = o.User + "@" + target
+	}
+	if o.Port != 0 {
+		target = fmt.Sprintf("%s:%d", target, o.Port)
+	}
+	if len(o.Via) == 0 {
+		return target
+	}
+
+	jumps := make([]string, len(o.Via))
+	for i, jump := range o.Via {
+		jumps[i] = jump.String()
+	}
+	return fmt.Sprintf("%s via %s", target, strings.Join(jumps, ", "))
+}
+
+// validate checks a host's settings; hop names it in errors
+func (o SSHOptions) validate(hop string) error {
+	if o.Host == "" {
+		return fmt.Errorf("%w: %s needs a host", ErrInvalidSSHOptions, hop)
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("%w: %s port %d is out of range", ErrInvalidSSHOptions, hop, o.Port)
+	}
+	for name := range o.Options {
+		if !slices.Contains(SSHOptionNames, strings.ToLower(name)) {
+			return fmt.Errorf("%w: %s has unsupported option %q", ErrInvalidSSHOptions, hop, name)
+		}
+	}
+	return nil
+}
+
+// RestartOptions holds a service's restart policy; zero values use the defaults
+type RestartOptions struct {
+	// Policy is never, on-failure or always
+	Policy string
+	// MaxAttempts is how many restarts in a row are tried before giving up; zero never gives up
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// String describes the restart policy for listings
+func (r RestartOptions) String() string {
+	if r.MaxAttempts > 0 {
+		return fmt.Sprintf("%s (at most %d attempts in a row)", r.Policy, r.MaxAttempts)
+	}
+	return r.Policy
+}
+
+// HealthOptions holds a service's probes and how they are run; zero values use the defaults
+type HealthOptions struct {
+	Probes           []Probe
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+}
+
+// Probe is one health check: exactly one of TCP, Command and Output is set
+type Probe struct {
+	TCP     string
+	Command string
+	Output  string
+}
+
+// GetKind returns the probe's type, or "" when it sets none or more than one
+func (p Probe) GetKind() string {
+	var kinds []string
+	if p.TCP != "" {
+		kinds = append(kinds, "tcp")
+	}
+	if p.Command != "" {
+		kinds = append(kinds, "command")
+	}
+	if p.Output != "" {
+		kinds = append(kinds, "output")
+	}
+	if len(kinds) != 1 {
+		return ""
+	}
+	return kinds[0]
+}
+
+// String describes the probe for listings
+func (p Probe) String() string {
+	switch p.GetKind() {
+	case "tcp":
+		return fmt.Sprintf("tcp %s", p.TCP)
+	case "command":
+		return fmt.Sprintf("command '%s'", p.Command)
+	case "output":
+		return fmt.Sprintf("output '%s'", p.Output)
+	default:
+		return "invalid probe"
+	}
+}
+
+// Step is one startup action: exactly one of Run, Send and/or Keys, WaitFor, Sleep and Checkpoint is set
+type Step struct {
+	Run        string
+	Send       string
+	Keys       string
+	WaitFor    string
+	Sleep      time.Duration
+	Checkpoint string
+	// Timeout overrides the dispatch step timeout for run and wait_for steps
+	Timeout time.Duration
+}
+
+// GetKind returns the step's action, or "" when it sets none or more than one
+func (s Step) GetKind() string {
+	var kinds []string
+	if s.Run != "" {
+		kinds = append(kinds, "run")
+	}
+	if s.Send != "" || s.Keys != "" {
+		kinds = append(kinds, "send")
+	}
+	if s.WaitFor != "" {
+		kinds = append(kinds, "wait_for")
+	}
+	if s.Sleep != 0 {
+		kinds = append(kinds, "sleep")
+	}
+	if s.Checkpoint != "" {
+		kinds = append(kinds, "checkpoint")
+	}
+	if len(kinds) != 1 {
+		return ""
+	}
+	return kinds[0]
+}
+
+// String describes the step for listings; a run step is just its command
+func (s Step) String() string {
+	switch s.GetKind() {
+	case "run":
+		return s.Run
+	case "send":
+		switch {
+		case s.Keys == "":
+			return fmt.Sprintf("send '%s'", s.Send)
+		case s.Send == "":
+			return fmt.Sprintf("send %s", s.Keys)
+		default:
+			return fmt.Sprintf("send '%s' + %s", s.Send, s.Keys)
+		}
+	case "wait_for":
+		return fmt.Sprintf("wait_for '%s'", s.WaitFor)
+	case "sleep":
+		return fmt.Sprintf("sleep %s", s.Sleep)
+	case "checkpoint":
+		return fmt.Sprintf("checkpoint '%s'", s.Checkpoint)
+	default:
+		return "invalid step"
+	}
+}
+
+// ExpectRule pairs a prompt pattern with where its response comes from
+type ExpectRule struct {
+	Pattern         string
+	Response        string
+	ResponseEnv     string
+	ResponseCommand string
+	// MaxResponses limits how often the rule answers; zero uses the default and negative never limits it
+	MaxResponses int
+}
+
+// GetSource describes where the response comes from without revealing it
+func (r ExpectRule) GetSource() string {
+	switch {
+	case r.ResponseEnv != "":
+		return "$" + r.ResponseEnv
+	case r.ResponseCommand != "":
+		return "command"
+	default:
+		return "literal"
+	}
+}
+
+// DispatchOptions holds how commands wait for the shell; zero values use the defaults
+type DispatchOptions struct {
+	// PromptPatterns are regular expressions that match the shell's prompt
+	PromptPatterns []string
+	// QuietPeriod sends the next command once output has stopped this long; negative disables it
+	QuietPeriod time.Duration
+	// StepTimeout fails the session when the shell is not ready for a command in time
+	StepTimeout time.Duration
+}
+
+// LogOptions holds rotation settings for a session's output log; zero values use the defaults
+type LogOptions struct {
+	MaxSize     int64
+	MaxAge      time.Duration
+	MaxFiles    int
+	MaxSessions int
+}
+
+// StartOptions holds options for starting a service session
+type StartOptions struct {
+	// DetachKeys overrides the key sequence that detaches the terminal
+	DetachKeys string
+	// Record writes what the terminal shows to an asciicast v2 file
+	Record bool
+	// RecordFile is where the recording goes; empty uses ~/.hama-shell/recordings/<session-id>.cast
+	RecordFile string
 }
 
 // ServiceSession represents an active service session
@@ -15,6 +346,88 @@ type ServiceSession struct {
 	ID        string
 	Service   Service
 	StartTime time.Time
+	// Status is starting, ready, unhealthy, restarting or running
+	Status string
+	// Forwards are the session's port forwards as the daemon runs them
+	Forwards []ActiveForward
+}
+
+// ActiveForward is a port forward of a running session
+type ActiveForward struct {
+	Name   string
+	Type   string
+	Listen string
+	// Target is where connections go; empty for a dynamic forward
+	Target      string
+	Connections int
+	// Error is why the last connection could not be tunnelled
+	Error string
+}
+
+// String describes the forward and its open connections
+func (f ActiveForward) String() string {
+	route := fmt.Sprintf("%s → %s", f.Listen, f.Target)
+	if f.Target == "" {
+		route = fmt.Sprintf("%s (SOCKS)", f.Listen)
+	}
+	return fmt.Sprintf("%s %s %s, %d open", f.Name, f.Type, route, f.Connections)
+}
+
+// EnvFormats are the output formats of hs env; the first is the default
+var EnvFormats = []string{"export", "dotenv", "json", "fish"}
+
+// EnvVar is a variable describing a running session
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// Env returns the variables scripts need to reach the session: its ID and target, and where
+// each of its local and dynamic forwards listens, e.g. HS_FORWARD_DB_PORT. Remote forwards are
+// left out as they listen on the SSH server.
+func (s ServiceSession) Env() []EnvVar {
+	vars := []EnvVar{
+		{"HS_SESSION_ID", s.ID},
+		{"HS_TARGET", s.Service.GetFullName()},
+		{"HS_PROJECT", s.Service.ProjectName},
+		{"HS_SERVICE", s.Service.ServiceName},
+		{"HS_STAGE", s.Service.StageName},
+		{"HS_STATUS", s.Status},
+	}
+	// The session's own forwards are used, as the configuration may have changed since it started
+	seen := make(map[string]bool)
+	for _, forward := range s.Forwards {
+		name := envName(forward.Name)
+		if forward.Type == "remote" || seen[name] {
+			continue
+		}
+		host, port, err := net.SplitHostPort(forward.Listen)
+		if err != nil {
+			continue
+		}
+		seen[name] = true
+		prefix := "HS_FORWARD_" + name
+		vars = append(vars,
+			EnvVar{prefix + "_HOST", host},
+			EnvVar{prefix + "_PORT", port},
+			EnvVar{prefix + "_ADDR", forward.Listen},
+		)
+	}
+	return vars
+}
+
+// envName turns a forward name into the upper case letters, digits and underscores a variable name allows
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
 }
 
 // GetFullName returns project.service.stage format
@@ -22,6 +435,18 @@ func (s Service) GetFullName() string {
 	return s.ProjectName + "." + s.ServiceName + "." + s.StageName
 }
 
+// GetSteps returns the service's startup steps, treating plain commands as run steps
+func (s Service) GetSteps() []Step {
+	if len(s.Steps) > 0 {
+		return s.Steps
+	}
+	steps := make([]Step, 0, len(s.Commands))
+	for _, command := range s.Commands {
+		steps = append(steps, Step{Run: command})
+	}
+	return steps
+}
+
 // Validate checks if service configuration is valid
 func (s Service) Validate() error {
 	if s.ProjectName == "" {
@@ -33,8 +458,120 @@ func (s Service) Validate() error {
 	if s.StageName == "" {
 		return ErrEmptyStageName
 	}
-	if len(s.Commands) == 0 {
+	if len(s.Commands) > 0 && len(s.Steps) > 0 {
+		return ErrCommandsAndSteps
+	}
+	if len(s.Commands) == 0 && len(s.Steps) == 0 && s.SSH == nil {
 		return ErrNoCommands
 	}
+	for i, step := range s.Steps {
+		switch step.GetKind() {
+		case "":
+			return fmt.Errorf("%w: step %d needs exactly one of run, send, wait_for, sleep or checkpoint", ErrInvalidStep, i+1)
+		case "wait_for":
+			if _, err := regexp.Compile(step.WaitFor); err != nil {
+				return fmt.Errorf("%w: step %d: wait_for %q: %v", ErrInvalidStep, i+1, step.WaitFor, err)
+			}
+		case "sleep":
+			if step.Sleep < 0 {
+				return fmt.Errorf("%w: step %d: sleep cannot be negative", ErrInvalidStep, i+1)
+			}
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("%w: step %d: timeout cannot be negative", ErrInvalidStep, i+1)
+		}
+	}
+	switch s.ResizePolicy {
+	case "", "smallest", "largest", "latest":
+	default:
+		return ErrInvalidResizePolicy
+	}
+	if s.StopGracePeriod < 0 {
+		return ErrInvalidStopGracePeriod
+	}
+	if s.Log != nil && (s.Log.MaxSize < 0 || s.Log.MaxAge < 0 || s.Log.MaxFiles < 0 || s.Log.MaxSessions < 0) {
+		return ErrInvalidLogOptions
+	}
+	for _, rule := range s.Expect {
+		sources := 0
+		for _, source := range []string{rule.Response, rule.ResponseEnv, rule.ResponseCommand} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("%w: pattern %q needs exactly one of response, response_env or response_command", ErrInvalidExpectRule, rule.Pattern)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("%w: pattern %q: %v", ErrInvalidExpectRule, rule.Pattern, err)
+		}
+	}
+	if s.Restart != nil {
+		switch s.Restart.Policy {
+		case "never", "on-failure", "always":
+		default:
+			return fmt.Errorf("%w: restart must be never, on-failure or always, not %q", ErrInvalidRestartOptions, s.Restart.Policy)
+		}
+		if s.Restart.MaxAttempts < 0 || s.Restart.Backoff < 0 || s.Restart.MaxBackoff < 0 {
+			return fmt.Errorf("%w: restart_max_attempts, restart_backoff and restart_max_backoff cannot be negative", ErrInvalidRestartOptions)
+		}
+	}
+	if s.SSH != nil {
+		if err := s.SSH.validate("the target"); err != nil {
+			return err
+		}
+		for i, jump := range s.SSH.Via {
+			hop := fmt.Sprintf("jump host %d", i+1)
+			if len(jump.Via) > 0 {
+				return fmt.Errorf("%w: %s cannot have jump hosts of its own", ErrInvalidSSHOptions, hop)
+			}
+			if err := jump.validate(hop); err != nil {
+				return err
+			}
+		}
+	}
+	if len(s.Forwards) > 0 && s.SSH == nil {
+		return fmt.Errorf("%w: forwards need an ssh stage", ErrInvalidForward)
+	}
+	names := make(map[string]string)
+	for _, forward := range s.Forwards {
+		if err := forward.validate(); err != nil {
+			return err
+		}
+		// Names must stay apart as hs env variables, e.g. HS_FORWARD_DB_1_PORT
+		variable := envName(forward.Name)
+		if other, taken := names[variable]; taken {
+			if other == forward.Name {
+				return fmt.Errorf("%w: %s is declared twice", ErrInvalidForward, forward.Name)
+			}
+			return fmt.Errorf("%w: %s and %s would both be HS_FORWARD_%s in hs env", ErrInvalidForward, other, forward.Name, variable)
+		}
+		names[variable] = forward.Name
+	}
+	if s.Health != nil {
+		if s.Health.Interval < 0 || s.Health.Timeout < 0 || s.Health.FailureThreshold < 0 {
+			return fmt.Errorf("%w: interval, timeout and failure_threshold cannot be negative", ErrInvalidHealthOptions)
+		}
+		for i, probe := range s.Health.Probes {
+			switch probe.GetKind() {
+			case "":
+				return fmt.Errorf("%w: probe %d needs exactly one of tcp, command or output", ErrInvalidHealthOptions, i+1)
+			case "output":
+				if _, err := regexp.Compile(probe.Output); err != nil {
+					return fmt.Errorf("%w: output probe %q: %v", ErrInvalidHealthOptions, probe.Output, err)
+				}
+			}
+		}
+	}
+	if s.Dispatch != nil {
+		if s.Dispatch.StepTimeout < 0 {
+			return ErrInvalidDispatchOptions
+		}
+		for _, pattern := range s.Dispatch.PromptPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%w: prompt %q: %v", ErrInvalidDispatchOptions, pattern, err)
+			}
+		}
+	}
 	return nil
 }
@@ -1,11 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"hama-shell/internal/core/terminal"
 	"hama-shell/internal/session/infra"
 	"hama-shell/internal/session/model"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 // SessionAPI provides high-level session operations
@@ -38,27 +42,51 @@ func (api *SessionAPI) ListSessions(showAll bool, statusFilter string) error {
 			fmt.Printf("(Filtered by status: %s)\n", statusFilter)
 		}
 		if !showAll {
-			fmt.Println("Use --all flag to show stopped sessions")
+			fmt.Println("Use --all flag to show stopped and failed sessions")
 		}
 		return nil
 	}
 
 	// Create tabwriter for aligned output
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	fmt.Fprintln(w, "SESSION ID\tSTATUS\tSTART TIME\tCOMMAND")
-	fmt.Fprintln(w, "----------\t------\t----------\t-------")
+	fmt.Fprintln(w, "SESSION ID\tTARGET\tSTATUS\tSTART TIME\tEXIT\tRESTARTS\tCHECKPOINT\tCLIENTS\tCOMMAND")
+	fmt.Fprintln(w, "----------\t------\t------\t----------\t----\t--------\t----------\t-------\t-------")
 
 	for _, session := range sessions {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s\n",
 			session.ID,
+			session.Target,
 			session.Status,
 			session.StartTime.Format("2006-01-02 15:04:05"),
+			session.GetExitSummary(),
+			session.Restarts,
+			session.GetCheckpoint(),
+			session.Clients,
 			session.Command,
 		)
 	}
 
 	w.Flush()
 
+	// Explain failures the exit column cannot and show the forwards of running sessions
+	for _, session := range sessions {
+		if session.Error != "" {
+			fmt.Printf("⚠️  %s: %s\n", session.ID, session.Error)
+		}
+		if session.HealthError != "" {
+			fmt.Printf("🩺 %s: %s\n", session.ID, session.HealthError)
+		}
+		if !session.IsRunning() {
+			continue
+		}
+		for _, forward := range session.Forwards {
+			fmt.Printf("🔀 %s: %s\n", session.ID, forward)
+			if forward.Error != "" {
+				fmt.Printf("   ⚠️  %s\n", forward.Error)
+			}
+		}
+	}
+
 	// Show session count
 	fmt.Printf("\nTotal sessions: %d\n", len(sessions))
 
@@ -68,3 +96,224 @@ func (api *SessionAPI) ListSessions(showAll bool, statusFilter string) error {
 
 	return nil
 }
+
+// AttachSession attaches the current terminal to a running session
+func (api *SessionAPI) AttachSession(sessionID string, opts model.AttachOptions) error {
+	if opts.ReadOnly {
+		fmt.Printf("👀 Watching session %s (read-only)...\n", sessionID)
+	} else {
+		fmt.Printf("🔗 Attaching to session %s...\n", sessionID)
+	}
+
+	result, err := api.sessionMgr.AttachSession(sessionID, opts)
+	if err != nil {
+		return err
+	}
+
+	if result.Detached {
+		fmt.Printf("\n🔌 Detached from session %s\n", sessionID)
+		return nil
+	}
+
+	fmt.Printf("\n✅ Session %s ended\n", sessionID)
+	return nil
+}
+
+// PeekSession prints a snapshot of a session's screen without attaching to it
+func (api *SessionAPI) PeekSession(sessionID string, ansi bool) error {
+	screen, err := api.sessionMgr.PeekSession(sessionID, ansi)
+	if err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("%dx%d, cursor at %d,%d", screen.Cols, screen.Rows, screen.CursorRow+1, screen.CursorCol+1)
+	if screen.AltScreen {
+		details += ", alternate screen"
+	}
+	if screen.Title != "" {
+		details += fmt.Sprintf(", title %q", screen.Title)
+	}
+	fmt.Printf("📸 Session %s (%s)\n", sessionID, details)
+	fmt.Print(screen.Content)
+	return nil
+}
+
+// SendInput types text and named keys into a running session
+func (api *SessionAPI) SendInput(sessionID string, opts model.SendOptions) error {
+	n, err := api.sessionMgr.SendInput(sessionID, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("⌨️  Sent %d bytes to session %s\n", n, sessionID)
+	return nil
+}
+
+// BroadcastSessions types into every selected session at once until the user detaches
+func (api *SessionAPI) BroadcastSessions(opts model.BroadcastOptions) error {
+	sessions, err := api.sessionMgr.SelectSessions(opts.Select)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📡 Broadcasting to %d sessions:\n", len(sessions))
+	for _, session := range sessions {
+		fmt.Printf("   • %s (%s)\n", session.Target, session.ID)
+	}
+	fmt.Println()
+
+	result, err := api.sessionMgr.BroadcastSessions(sessions, opts.DetachKeys)
+	if err != nil {
+		return err
+	}
+
+	if result.Detached {
+		fmt.Printf("🔌 Stopped broadcasting; the sessions keep running\n")
+		return nil
+	}
+	fmt.Printf("✅ All sessions ended\n")
+	return nil
+}
+
+// ShowEvents prints session lifecycle events as text or JSON lines
+func (api *SessionAPI) ShowEvents(opts model.EventOptions) error {
+	var handle func(*terminal.Event) error
+	switch opts.Output {
+	case "", "text":
+		handle = printEvent
+	case "jsonl":
+		encoder := json.NewEncoder(os.Stdout)
+		handle = func(event *terminal.Event) error {
+			return encoder.Encode(event)
+		}
+	default:
+		return fmt.Errorf("unknown output format %q (use text or jsonl)", opts.Output)
+	}
+
+	return api.sessionMgr.StreamEvents(opts, handle)
+}
+
+// printEvent prints one event as a human-readable line
+func printEvent(event *terminal.Event) error {
+	var detail string
+	switch event.Type {
+	case terminal.EventStarted:
+		detail = fmt.Sprintf("pid %d", event.PID)
+	case terminal.EventResized:
+		detail = fmt.Sprintf("%dx%d", event.Cols, event.Rows)
+	case terminal.EventAttached, terminal.EventDetached:
+		detail = event.ClientID
+		if event.ReadOnly {
+			detail += " (read-only)"
+		}
+	case terminal.EventHealth:
+		detail = string(event.Health)
+		if event.Error != "" {
+			detail += ": " + event.Error
+		}
+	case terminal.EventCheckpoint:
+		detail = fmt.Sprintf("'%s'", event.Checkpoint)
+	case terminal.EventResponded:
+		detail = fmt.Sprintf("expect '%s'", event.Rule)
+	case terminal.EventRestarting:
+		detail = fmt.Sprintf("restart %d in %s", event.Restarts, event.Delay)
+		if event.Exit != nil {
+			detail += fmt.Sprintf(" after status %d (%s)", event.Exit.Code, event.Exit.Reason)
+		}
+	case terminal.EventExited:
+		if event.Exit != nil {
+			detail = fmt.Sprintf("status %d (%s)", event.Exit.Code, event.Exit.Reason)
+		}
+	}
+
+	line := fmt.Sprintf("%s  %-10s  %s  %s", event.Time.Format("2006-01-02 15:04:05"), event.Type, event.SessionID, detail)
+	_, err := fmt.Println(strings.TrimSpace(line))
+	return err
+}
+
+// ShowLogs prints a session's output log; since is a duration like 10m or a timestamp
+func (api *SessionAPI) ShowLogs(sessionID string, follow bool, since string, timestamps bool) error {
+	opts := model.LogOptions{Follow: follow, Timestamps: timestamps}
+	if since != "" {
+		sinceTime, err := parseSince(since)
+		if err != nil {
+			return err
+		}
+		opts.Since = sinceTime
+	}
+
+	return api.sessionMgr.ReadLogs(sessionID, opts, os.Stdout)
+}
+
+// parseSince accepts a duration before now or an absolute local time
+func parseSince(since string) (time.Time, error) {
+	if d, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 10m or a time like 2006-01-02 15:04:05", since)
+}
+
+// ReplayRecording plays an asciicast recording in the terminal
+func (api *SessionAPI) ReplayRecording(path string, speed float64, idleLimit time.Duration) error {
+	cast, err := api.sessionMgr.LoadRecording(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("▶️  Replaying %s (%s, %dx%d) - space: pause, ←/→: seek, +/-: speed, q: quit\n",
+		path, cast.Duration().Round(time.Second), cast.Header.Width, cast.Header.Height)
+
+	if err := api.sessionMgr.ReplayRecording(cast, speed, idleLimit); err != nil {
+		return err
+	}
+
+	fmt.Printf("\r\n⏹️  Replay finished\n")
+	return nil
+}
+
+// StopSessions stops a session by ID, or every running session of a target
+func (api *SessionAPI) StopSessions(ref string, grace time.Duration) error {
+	fmt.Printf("🛑 Stopping %s...\n", ref)
+
+	results, err := api.sessionMgr.StopSessions(ref, grace)
+	printStopResults(results)
+	return err
+}
+
+// KillSessions stops every running session matching the filter
+func (api *SessionAPI) KillSessions(filter model.KillFilter, grace time.Duration) error {
+	results, err := api.sessionMgr.KillSessions(filter, grace)
+	if err == nil && len(results) == 0 {
+		fmt.Println("No running sessions matched.")
+		return nil
+	}
+
+	printStopResults(results)
+	return err
+}
+
+// printStopResults reports how each stopped session ended
+func printStopResults(results []model.StopResult) {
+	for _, result := range results {
+		session := result.Session
+		elapsed := result.Elapsed.Round(time.Millisecond)
+
+		switch result.Step {
+		case "already-ended":
+			if session.EndReason == "" {
+				fmt.Printf("ℹ️  Session %s had already ended\n", session.ID)
+				break
+			}
+			fmt.Printf("ℹ️  Session %s had already ended: %s\n", session.ID, session.GetExitSummary())
+		case "kill":
+			fmt.Printf("💀 Session %s did not exit in its grace period and was killed with SIGKILL after %s\n", session.ID, elapsed)
+		default:
+			fmt.Printf("✅ Session %s exited after SIGHUP/SIGTERM in %s: %s\n", session.ID, elapsed, session.GetExitSummary())
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package infra
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"hama-shell/internal/core/daemon"
+	"hama-shell/internal/core/terminal"
+	"hama-shell/internal/session/model"
+)
+
+// logPollInterval is how often a followed log is checked for new output
+const logPollInterval = 250 * time.Millisecond
+
+// ReadLogs writes a session's output log to w, oldest rotated file first.
+// When following it keeps writing new output until the session ends.
+func (sm *SessionManager) ReadLogs(sessionID string, opts model.LogOptions, w io.Writer) error {
+	files, err := terminal.LogFiles(daemon.SessionLogDir(), sessionID)
+	if err != nil {
+		return err
+	}
+
+	printer := &logPrinter{w: w, opts: opts}
+	for _, path := range files[:len(files)-1] {
+		if err := printer.copyFile(path); err != nil {
+			return err
+		}
+	}
+
+	live := files[len(files)-1]
+	if !opts.Follow {
+		if err := printer.copyFile(live); err != nil {
+			return err
+		}
+		return printer.flush()
+	}
+	return sm.followLog(sessionID, live, printer)
+}
+
+// followLog prints the live log as it grows, switching files when it is rotated,
+// and returns once the session has ended and its last output is printed
+func (sm *SessionManager) followLog(sessionID, path string, printer *logPrinter) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer func() { file.Close() }()
+
+	ended := false
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := printer.feed(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if err != io.EOF {
+			return fmt.Errorf("failed to read log: %w", err)
+		}
+
+		// The old file is drained; carry on with the one that replaced it
+		if rotated(file, path) {
+			file.Close()
+			if file, err = os.Open(path); err != nil {
+				return fmt.Errorf("failed to open log: %w", err)
+			}
+			continue
+		}
+
+		// Read once more after noticing the end so nothing written just before it is lost
+		if ended {
+			return printer.flush()
+		}
+		ended = !sm.isRunning(sessionID)
+		if !ended {
+			time.Sleep(logPollInterval)
+		}
+	}
+}
+
+// isRunning asks the daemon whether a session is still running
+func (sm *SessionManager) isRunning(sessionID string) bool {
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		return false
+	}
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			return session.Running
+		}
+	}
+	return false
+}
+
+// rotated returns true if path no longer refers to the open file
+func rotated(file *os.File, path string) bool {
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	open, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(current, open)
+}
+
+// logPrinter strips or reformats the timestamps of a session log and applies --since.
+// It accepts the log in arbitrary chunks, as a followed log arrives.
+type logPrinter struct {
+	w       io.Writer
+	opts    model.LogOptions
+	pending []byte
+	midLine bool
+	skip    bool
+}
+
+// copyFile feeds a whole log file through the printer
+func (p *logPrinter) copyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read log: %w", err)
+	}
+	return p.feed(data)
+}
+
+// feed prints every complete part of data, keeping an incomplete timestamp for the next call
+func (p *logPrinter) feed(data []byte) error {
+	p.pending = append(p.pending, data...)
+
+	for len(p.pending) > 0 {
+		if !p.midLine {
+			if len(p.pending) < terminal.LogPrefixLen && !bytes.ContainsRune(p.pending, '\n') {
+				return nil
+			}
+			p.skip = false
+			if ts, ok := terminal.ParseLogPrefix(p.pending); ok {
+				p.pending = p.pending[terminal.LogPrefixLen:]
+				p.skip = !p.opts.Since.IsZero() && ts.Before(p.opts.Since)
+				if p.opts.Timestamps && !p.skip {
+					if _, err := fmt.Fprintf(p.w, "%s ", ts.Local().Format("2006-01-02 15:04:05.000")); err != nil {
+						return err
+					}
+				}
+			}
+			p.midLine = true
+		}
+
+		line := p.pending
+		if i := bytes.IndexByte(p.pending, '\n'); i >= 0 {
+			line = p.pending[:i+1]
+			p.midLine = false
+		}
+		if !p.skip {
+			if _, err := p.w.Write(line); err != nil {
+				return err
+			}
+		}
+		p.pending = p.pending[len(line):]
+	}
+	return nil
+}
+
+// flush prints whatever is left once the log has ended
+func (p *logPrinter) flush() error {
+	if len(p.pending) == 0 || p.skip {
+		return nil
+	}
+	_, err := p.w.Write(p.pending)
+	p.pending = nil
+	return err
+}
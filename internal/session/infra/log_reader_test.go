@@ -0,0 +1,68 @@
+package infra
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"hama-shell/internal/session/model"
+)
+
+func TestLogPrinter(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 1, 2, 15, minute, 0, 0, time.UTC) }
+	stamp := func(minute int) string { return at(minute).Format("2006-01-02T15:04:05.000Z") + " " }
+	local := func(minute int) string { return at(minute).Local().Format("2006-01-02 15:04:05.000") + " " }
+
+	log := stamp(1) + "first\n" +
+		stamp(2) + "second\n" +
+		stamp(3) + "third\n" +
+		stamp(4) + "unfinished"
+
+	tests := []struct {
+		name string
+		opts model.LogOptions
+		want string
+	}{
+		{
+			name: "timestamps stripped",
+			want: "first\nsecond\nthird\nunfinished",
+		},
+		{
+			name: "since skips older lines",
+			opts: model.LogOptions{Since: at(2)},
+			want: "second\nthird\nunfinished",
+		},
+		{
+			name: "since after the last line",
+			opts: model.LogOptions{Since: at(5)},
+			want: "",
+		},
+		{
+			name: "timestamps in local time",
+			opts: model.LogOptions{Since: at(3), Timestamps: true},
+			want: local(3) + "third\n" + local(4) + "unfinished",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A followed log arrives in arbitrary chunks, splitting timestamps and lines
+			for _, size := range []int{len(log), 1, 7, 30} {
+				var out bytes.Buffer
+				printer := &logPrinter{w: &out, opts: tt.opts}
+				for rest := []byte(log); len(rest) > 0; {
+					n := min(size, len(rest))
+					if err := printer.feed(rest[:n]); err != nil {
+						t.Fatalf("feed: %v", err)
+					}
+					rest = rest[n:]
+				}
+				if err := printer.flush(); err != nil {
+					t.Fatalf("flush: %v", err)
+				}
+				if out.String() != tt.want {
+					t.Errorf("chunks of %d: printed %q, want %q", size, out.String(), tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,55 +1,460 @@
 package infra
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	config "hama-shell/internal/configuration/infra"
+	configModel "hama-shell/internal/configuration/model"
+	"hama-shell/internal/core/daemon"
 	"hama-shell/internal/core/terminal"
 	"hama-shell/internal/session/model"
-	"time"
 )
 
 // SessionManager handles session operations
 type SessionManager struct {
-	server terminal.Server
+	client daemon.Client
+	config *configModel.Config
 }
 
 // NewSessionManager creates a new SessionManager instance
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
-		server: terminal.NewTerminalServer(),
+		client: daemon.NewClient(),
+		config: config.GetInstance().GetConfig(),
 	}
 }
 
 // ListSessions returns list of sessions based on filter
 func (sm *SessionManager) ListSessions(filter model.SessionFilter) ([]model.SessionInfo, error) {
-	// Get all sessions from terminal server
-	sessions := sm.server.ListSessions()
+	// Get all sessions from the daemon; no daemon means no sessions
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query daemon: %w", err)
+	}
 
 	var result []model.SessionInfo
 	for _, session := range sessions {
-		info := session.GetInfo()
+		sessionInfo := newSessionInfo(session)
 
-		sessionInfo := model.SessionInfo{
-			ID:        info["id"].(string),
-			StartTime: info["start_time"].(time.Time),
+		// Apply filter
+		if filter.Status != "" && !sessionInfo.MatchesStatus(filter.Status) {
+			continue
 		}
 
-		// Set status
-		if running, ok := info["running"].(bool); ok && running {
-			sessionInfo.Status = "running"
-		} else {
-			sessionInfo.Status = "stopped"
+		if !filter.ShowAll && !sessionInfo.IsRunning() {
+			continue
 		}
 
-		// Apply filter
-		if filter.Status != "" && sessionInfo.Status != filter.Status {
+		result = append(result, sessionInfo)
+	}
+
+	return result, nil
+}
+
+// AttachSession attaches the current terminal to a running session until it ends or the user detaches
+func (sm *SessionManager) AttachSession(sessionID string, opts model.AttachOptions) (*daemon.AttachResult, error) {
+	keys, err := daemon.ParseDetachKeys(opts.DetachKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := sm.client.Attach(sessionID, daemon.AttachOptions{ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to session %s: %w", sessionID, err)
+	}
+	defer stream.Close()
+
+	return daemon.AttachTerminal(stream, daemon.AttachConfig{
+		DetachKeys: keys,
+		ReadOnly:   opts.ReadOnly,
+	})
+}
+
+// PeekSession returns what a session's terminal currently shows
+func (sm *SessionManager) PeekSession(sessionID string, ansi bool) (*terminal.ScreenSnapshot, error) {
+	screen, err := sm.client.Peek(sessionID, ansi)
+	if err != nil {
+		return nil, fmt.Errorf("failed to peek at session %s: %w", sessionID, err)
+	}
+	return screen, nil
+}
+
+// SendInput types text and named keys into a running session and returns how many bytes were sent
+func (sm *SessionManager) SendInput(sessionID string, opts model.SendOptions) (int, error) {
+	data := []byte(opts.Text)
+	if opts.Keys != "" {
+		keys, err := terminal.ParseKeySequence(opts.Keys)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --keys: %w", err)
+		}
+		data = append(data, keys...)
+	}
+	if len(data) == 0 {
+		return 0, fmt.Errorf("nothing to send: give text or --keys")
+	}
+
+	if err := sm.client.SendInput(sessionID, data); err != nil {
+		return 0, fmt.Errorf("failed to send to session %s: %w", sessionID, err)
+	}
+	return len(data), nil
+}
+
+// SelectSessions returns the running sessions whose target matches a glob such as
+// "myapp.*.prod", or the session whose ID equals the selector
+func (sm *SessionManager) SelectSessions(selector string) ([]model.SessionInfo, error) {
+	if _, err := path.Match(selector, ""); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daemon: %w", err)
+	}
+
+	var selected []model.SessionInfo
+	for _, session := range sessions {
+		if !session.Running {
+			continue
+		}
+		if matched, _ := path.Match(selector, session.Target); matched || session.ID == selector {
+			selected = append(selected, newSessionInfo(session))
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no running session matches %q", selector)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Target != selected[j].Target {
+			return selected[i].Target < selected[j].Target
+		}
+		return selected[i].ID < selected[j].ID
+	})
+	return selected, nil
+}
+
+// BroadcastSessions sends everything typed on the current terminal to every session and shows
+// their output interleaved, each line prefixed with its target
+func (sm *SessionManager) BroadcastSessions(sessions []model.SessionInfo, detachKeys string) (*daemon.BroadcastResult, error) {
+	keys, err := daemon.ParseDetachKeys(detachKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions sharing a target are told apart by their IDs
+	targetCount := make(map[string]int)
+	for _, session := range sessions {
+		targetCount[session.Target]++
+	}
+
+	targets := make([]daemon.BroadcastTarget, 0, len(sessions))
+	defer func() {
+		for _, target := range targets {
+			target.Stream.Close()
+			target.Input.Close()
+		}
+	}()
+
+	for _, session := range sessions {
+		stream, err := sm.client.Attach(session.ID, daemon.AttachOptions{ReadOnly: true, SkipScrollback: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to attach to session %s: %w", session.ID, err)
+		}
+
+		name := session.Target
+		if name == "" || targetCount[name] > 1 {
+			name = session.ID
+		}
+
+		input, err := sm.client.OpenInput(session.ID)
+		if err != nil {
+			stream.Close()
+			return nil, fmt.Errorf("failed to open input to session %s: %w", session.ID, err)
+		}
+
+		targets = append(targets, daemon.BroadcastTarget{
+			Name:   name,
+			Stream: stream,
+			Prompt: sm.currentLine(session.ID),
+			Input:  input,
+		})
+	}
+
+	return daemon.BroadcastTerminal(targets, daemon.BroadcastConfig{DetachKeys: keys})
+}
+
+// currentLine returns the text on the line where a session's cursor is
+func (sm *SessionManager) currentLine(sessionID string) string {
+	screen, err := sm.client.Peek(sessionID, false)
+	if err != nil {
+		return ""
+	}
+
+	line := ""
+	if lines := strings.Split(screen.Content, "\n"); screen.CursorRow < len(lines) {
+		line = lines[screen.CursorRow]
+	}
+
+	// Snapshots trim trailing spaces, but a prompt usually ends with one before the cursor
+	if pad := screen.CursorCol - utf8.RuneCountInString(line); pad > 0 {
+		line += strings.Repeat(" ", pad)
+	}
+	return line
+}
+
+// StreamEvents passes the daemon's recent lifecycle events, and new ones when following, to handle
+func (sm *SessionManager) StreamEvents(opts model.EventOptions, handle func(*terminal.Event) error) error {
+	stream, err := sm.client.Events(opts.Follow)
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) && !opts.Follow {
+			return nil
+		}
+		return fmt.Errorf("failed to open event stream: %w", err)
+	}
+	defer stream.Close()
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) && !opts.Follow {
+				return nil
+			}
+			return fmt.Errorf("event stream closed: %w", err)
+		}
+
+		if opts.Session != "" && event.SessionID != opts.Session && event.Target != opts.Session {
 			continue
 		}
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadRecording reads an asciicast v2 recording from disk
+func (sm *SessionManager) LoadRecording(path string) (*terminal.Cast, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	return terminal.ReadCast(file)
+}
+
+// ReplayRecording plays a recording on the local terminal
+func (sm *SessionManager) ReplayRecording(cast *terminal.Cast, speed float64, idleLimit time.Duration) error {
+	return daemon.ReplayTerminal(cast, daemon.ReplayConfig{Speed: speed, IdleLimit: idleLimit})
+}
+
+// StopSessions stops the session with the given ID, or every running session of a
+// project.service.stage target, waiting up to grace (zero uses each session's) before SIGKILL
+func (sm *SessionManager) StopSessions(ref string, grace time.Duration) ([]model.StopResult, error) {
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []daemon.SessionInfo
+	for _, session := range sessions {
+		if session.ID == ref {
+			matched = []daemon.SessionInfo{session}
+			break
+		}
+		if session.Target == ref && session.Running {
+			matched = append(matched, session)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no running session matches %q", ref)
+	}
 
-		if !filter.ShowAll && sessionInfo.Status == "stopped" {
+	return sm.stop(matched, grace)
+}
+
+// KillSessions stops every running session whose target matches the filter in the
+// project/service/stage hierarchy of the configuration
+func (sm *SessionManager) KillSessions(filter model.KillFilter, grace time.Duration) ([]model.StopResult, error) {
+	targets := make(map[string]bool)
+	if !filter.All {
+		var err error
+		if targets, err = sm.matchTargets(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		if errors.Is(err, daemon.ErrNotRunning) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query daemon: %w", err)
+	}
+
+	var matched []daemon.SessionInfo
+	for _, session := range sessions {
+		if session.Running && (filter.All || targets[session.Target]) {
+			matched = append(matched, session)
+		}
+	}
+
+	return sm.stop(matched, grace)
+}
+
+// matchTargets returns the project.service.stage targets in the configuration that match the filter
+func (sm *SessionManager) matchTargets(filter model.KillFilter) (map[string]bool, error) {
+	if filter.ProjectName == "" && filter.ServiceName == "" && filter.StageName == "" {
+		return nil, fmt.Errorf("select sessions with --project, --service or --stage, or use --all")
+	}
+
+	targets := make(map[string]bool)
+	for projectName, project := range sm.config.Projects {
+		if filter.ProjectName != "" && projectName != filter.ProjectName {
 			continue
 		}
+		for serviceName, service := range project.Services {
+			if filter.ServiceName != "" && serviceName != filter.ServiceName {
+				continue
+			}
+			for stageName := range service.Stages {
+				if filter.StageName != "" && stageName != filter.StageName {
+					continue
+				}
+				targets[projectName+"."+serviceName+"."+stageName] = true
+			}
+		}
+	}
 
-		result = append(result, sessionInfo)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no configured service matches the filter")
 	}
+	return targets, nil
+}
 
-	return result, nil
+// stop stops the given sessions in parallel so each gets its full grace period
+func (sm *SessionManager) stop(sessions []daemon.SessionInfo, grace time.Duration) ([]model.StopResult, error) {
+	results := make([]*model.StopResult, len(sessions))
+	errs := make([]error, len(sessions))
+
+	var wg sync.WaitGroup
+	for i, session := range sessions {
+		wg.Add(1)
+		go func(i int, session daemon.SessionInfo) {
+			defer wg.Done()
+
+			stop, err := sm.client.StopSession(session.ID, grace)
+			if err != nil {
+				// The session may have ended since it was listed, and the daemon forgotten it or exited
+				ended, stillRunning := sm.endedSession(session.ID)
+				if stillRunning && !errors.Is(err, daemon.ErrNotRunning) {
+					errs[i] = fmt.Errorf("failed to stop session %s: %w", session.ID, err)
+					return
+				}
+				stop = &terminal.StopResult{SessionID: session.ID, Step: terminal.StopStepAlreadyEnded}
+				if ended != nil {
+					stop.Exit = ended.Exit
+				}
+			}
+
+			session.Running = false
+			session.Exit = stop.Exit
+			info := newSessionInfo(session)
+			if session.Exit == nil {
+				// How it ended is unknown, only that it has
+				info.Status = "stopped"
+			}
+			results[i] = &model.StopResult{
+				Session: info,
+				Step:    string(stop.Step),
+				Elapsed: stop.Elapsed,
+			}
+		}(i, session)
+	}
+	wg.Wait()
+
+	var stopped []model.StopResult
+	for _, result := range results {
+		if result != nil {
+			stopped = append(stopped, *result)
+		}
+	}
+	return stopped, errors.Join(errs...)
+}
+
+// endedSession looks a session up again after stopping it failed. It returns the session if the
+// daemon still lists it as ended, and whether it is still running; without a daemon it is not.
+func (sm *SessionManager) endedSession(sessionID string) (*daemon.SessionInfo, bool) {
+	sessions, err := sm.client.ListSessions()
+	if err != nil {
+		return nil, !errors.Is(err, daemon.ErrNotRunning)
+	}
+	for _, session := range sessions {
+		if session.ID == sessionID {
+			if session.Running {
+				return nil, true
+			}
+			return &session, false
+		}
+	}
+	return nil, false
+}
+
+// newSessionInfo converts the daemon's view of a session into the session model
+func newSessionInfo(session daemon.SessionInfo) model.SessionInfo {
+	sessionInfo := model.SessionInfo{
+		ID:         session.ID,
+		Target:     session.Target,
+		StartTime:  session.StartTime,
+		Command:    session.Command,
+		Clients:    session.Clients,
+		Checkpoint: session.Checkpoint,
+		Restarts:   session.Restarts,
+	}
+	for _, forward := range session.Forwards {
+		sessionInfo.Forwards = append(sessionInfo.Forwards, model.ForwardInfo{
+			Name:        forward.Name,
+			Type:        string(forward.Type),
+			Listen:      forward.Listen,
+			Target:      forward.Target,
+			Connections: forward.Connections,
+			Error:       forward.Error,
+		})
+	}
+
+	// Set status
+	switch {
+	case session.Exit == nil && session.Restarting:
+		sessionInfo.Status = "restarting"
+	case session.Exit == nil && session.Health != "":
+		sessionInfo.Status = session.Health
+		sessionInfo.HealthError = session.HealthError
+	case session.Exit == nil:
+		sessionInfo.Status = "running"
+	case session.Exit.Failed():
+		sessionInfo.Status = "failed"
+	default:
+		sessionInfo.Status = "stopped"
+	}
+	if session.Exit != nil {
+		sessionInfo.EndTime = session.Exit.EndTime
+		sessionInfo.ExitCode = session.Exit.Code
+		sessionInfo.Signal = session.Exit.Signal
+		sessionInfo.EndReason = string(session.Exit.Reason)
+		sessionInfo.StopStep = string(session.Exit.Stop)
+		sessionInfo.Error = session.Exit.Error
+	}
+
+	return sessionInfo
 }
@@ -0,0 +1,114 @@
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"hama-shell/internal/core/daemon"
+	"hama-shell/internal/core/terminal"
+)
+
+// stopClient is a daemon whose stop requests fail with stopErr for some sessions, and which
+// lists sessions as they are once those failed
+type stopClient struct {
+	daemon.Client
+	stopErr  map[string]error
+	sessions []daemon.SessionInfo
+	listErr  error
+}
+
+func (c *stopClient) StopSession(sessionID string, grace time.Duration) (*terminal.StopResult, error) {
+	if err := c.stopErr[sessionID]; err != nil {
+		return nil, err
+	}
+	return &terminal.StopResult{
+		SessionID: sessionID,
+		Step:      terminal.StopStepTerminate,
+		Exit:      &terminal.ExitStatus{Code: 129, Reason: terminal.EndReasonExited, Stop: terminal.StopStepTerminate},
+	}, nil
+}
+
+func (c *stopClient) ListSessions() ([]daemon.SessionInfo, error) {
+	return c.sessions, c.listErr
+}
+
+func TestStopSessionsThatEndedMeanwhile(t *testing.T) {
+	notRunning := fmt.Errorf("%w: connection refused", daemon.ErrNotRunning)
+	exited := &terminal.ExitStatus{Code: 0, Reason: terminal.EndReasonExited}
+
+	tests := []struct {
+		name     string
+		client   *stopClient
+		wantStep string
+		wantExit string
+		wantErr  string
+	}{
+		{
+			name:     "stopped",
+			client:   &stopClient{},
+			wantStep: string(terminal.StopStepTerminate),
+			wantExit: "129 (exited: terminate)",
+		},
+		{
+			name: "ended and still listed",
+			client: &stopClient{
+				stopErr:  map[string]error{"s1": errors.New("session s1 not found")},
+				sessions: []daemon.SessionInfo{{ID: "s1", Exit: exited}},
+			},
+			wantStep: string(terminal.StopStepAlreadyEnded),
+			wantExit: "0 (exited)",
+		},
+		{
+			name: "ended and forgotten",
+			client: &stopClient{
+				stopErr:  map[string]error{"s1": errors.New("session s1 not found")},
+				sessions: []daemon.SessionInfo{{ID: "s2", Running: true}},
+			},
+			wantStep: string(terminal.StopStepAlreadyEnded),
+			wantExit: "-",
+		},
+		{
+			name: "daemon exited",
+			client: &stopClient{
+				stopErr: map[string]error{"s1": notRunning},
+				listErr: notRunning,
+			},
+			wantStep: string(terminal.StopStepAlreadyEnded),
+			wantExit: "-",
+		},
+		{
+			name: "still running",
+			client: &stopClient{
+				stopErr:  map[string]error{"s1": errors.New("timed out")},
+				sessions: []daemon.SessionInfo{{ID: "s1", Running: true}},
+			},
+			wantErr: "failed to stop session s1: timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := &SessionManager{client: tt.client}
+			results, err := sm.stop([]daemon.SessionInfo{{ID: "s1", Running: true}}, 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || len(results) != 0 {
+					t.Fatalf("results = %v, error = %v; want no results and %q", results, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("results = %v, want one", results)
+			}
+			result := results[0]
+			if result.Step != tt.wantStep || result.Session.IsRunning() || result.Session.GetExitSummary() != tt.wantExit {
+				t.Errorf("step = %q, running = %v, exit = %q; want %q, false and %q",
+					result.Step, result.Session.IsRunning(), result.Session.GetExitSummary(), tt.wantStep, tt.wantExit)
+			}
+		})
+	}
+}
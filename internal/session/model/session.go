@@ -1,13 +1,95 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // SessionInfo represents information about a session
 type SessionInfo struct {
 	ID        string
+	Target    string
 	Status    string
 	StartTime time.Time
 	Command   string
+	Clients   int
+	EndTime   time.Time
+	ExitCode  int
+	Signal    string
+	EndReason string
+	// StopStep is the step of a graceful stop that ended the session: terminate or kill
+	StopStep string
+	// Error explains a failed session, such as a startup step that timed out
+	Error string
+	// Checkpoint is the last checkpoint step the session's startup passed
+	Checkpoint string
+	// HealthError is the probe that made the session unhealthy
+	HealthError string
+	// Restarts is how often the session's process has been restarted
+	Restarts int
+	// Forwards are the session's port forwards
+	Forwards []ForwardInfo
+}
+
+// ForwardInfo describes a port forward of a running session
+type ForwardInfo struct {
+	Name   string
+	Type   string
+	Listen string
+	// Target is where connections go; empty for a dynamic forward
+	Target      string
+	Connections int
+	// Error is why the last connection could not be tunnelled
+	Error string
+}
+
+// String describes the forward and its open connections
+func (f ForwardInfo) String() string {
+	route := fmt.Sprintf("%s → %s", f.Listen, f.Target)
+	if f.Target == "" {
+		route = fmt.Sprintf("%s (SOCKS)", f.Listen)
+	}
+	return fmt.Sprintf("%s %s %s, %d open", f.Name, f.Type, route, f.Connections)
+}
+
+// IsRunning reports whether the session has not ended, whatever its health
+func (s SessionInfo) IsRunning() bool {
+	switch s.Status {
+	case "running", "starting", "ready", "unhealthy", "restarting":
+		return true
+	}
+	return false
+}
+
+// MatchesStatus reports whether the session has the status; "running" matches every running session
+func (s SessionInfo) MatchesStatus(status string) bool {
+	if status == "running" {
+		return s.IsRunning()
+	}
+	return s.Status == status
+}
+
+// GetCheckpoint returns the session's last checkpoint, or "-" before it has passed one
+func (s SessionInfo) GetCheckpoint() string {
+	if s.Checkpoint == "" {
+		return "-"
+	}
+	return s.Checkpoint
+}
+
+// GetExitSummary returns a short description of how the session ended, or "-" while it runs
+func (s SessionInfo) GetExitSummary() string {
+	if s.EndReason == "" {
+		return "-"
+	}
+	reason := s.EndReason
+	if s.StopStep != "" {
+		reason += ": " + s.StopStep
+	}
+	if s.Signal != "" {
+		return fmt.Sprintf("%s (%s)", s.Signal, reason)
+	}
+	return fmt.Sprintf("%d (%s)", s.ExitCode, reason)
 }
 
 // SessionFilter represents filtering options for sessions
@@ -15,3 +97,61 @@ type SessionFilter struct {
 	ShowAll bool
 	Status  string
 }
+
+// AttachOptions represents options for attaching a terminal to a session
+type AttachOptions struct {
+	DetachKeys string
+	ReadOnly   bool
+}
+
+// BroadcastOptions represents options for typing into several sessions at once
+type BroadcastOptions struct {
+	// Select is a glob over project.service.stage targets such as "myapp.*.prod", or a session ID
+	Select     string
+	DetachKeys string
+}
+
+// StopResult describes how a stopped session ended
+type StopResult struct {
+	Session SessionInfo
+	// Step is the step of the graceful stop that ended the session: terminate, kill or already-ended
+	Step    string
+	Elapsed time.Duration
+}
+
+// KillFilter selects the sessions stopped by a bulk kill; empty names match everything
+type KillFilter struct {
+	ProjectName string
+	ServiceName string
+	StageName   string
+	// All stops every running session, including ones whose target is no longer configured
+	All bool
+}
+
+// EventOptions represents options for watching session lifecycle events
+type EventOptions struct {
+	// Follow keeps streaming new events after the recent history
+	Follow bool
+	// Output is text or jsonl
+	Output string
+	// Session limits events to a session ID or project.service.stage target
+	Session string
+}
+
+// LogOptions represents options for reading a session's output log
+type LogOptions struct {
+	// Follow keeps printing new output until the session ends
+	Follow bool
+	// Since skips lines written before this time when set
+	Since time.Time
+	// Timestamps prefixes every line with the time it was written
+	Timestamps bool
+}
+
+// SendOptions represents input to type into a session
+type SendOptions struct {
+	// Text is sent literally
+	Text string
+	// Keys is a comma-separated list of named keys such as "C-c,Enter", sent after Text
+	Keys string
+}